@@ -0,0 +1,359 @@
+package aws_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chadsmith12/dotsec/aws"
+	"github.com/chadsmith12/dotsec/secrets"
+)
+
+// fakeLocalStack serves the Secrets Manager and SSM actions dotsec uses, the way LocalStack does on a single endpoint.
+type fakeLocalStack struct {
+	mu         sync.Mutex
+	secrets    map[string]string
+	parameters map[string]string
+	// secrets scheduled for deletion, which are kept until the recovery window ends
+	deleted map[string]bool
+}
+
+func newFakeLocalStack(t *testing.T) (*fakeLocalStack, string) {
+	t.Helper()
+	fake := &fakeLocalStack{secrets: map[string]string{}, parameters: map[string]string{}, deleted: map[string]bool{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	return fake, server.URL
+}
+
+func (fake *fakeLocalStack) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=test/") {
+		writeJson(w, http.StatusForbidden, map[string]string{"__type": "UnrecognizedClientException", "message": "The security token included in the request is invalid."})
+		return
+	}
+
+	var request map[string]any
+	json.NewDecoder(r.Body).Decode(&request)
+
+	switch r.Header.Get("X-Amz-Target") {
+	case "secretsmanager.ListSecrets":
+		prefix := ""
+		if filters, ok := request["Filters"].([]any); ok {
+			prefix = filters[0].(map[string]any)["Values"].([]any)[0].(string)
+		}
+		list := make([]map[string]string, 0)
+		for _, name := range sortedKeys(fake.secrets) {
+			if strings.HasPrefix(name, prefix) && !fake.deleted[name] {
+				list = append(list, map[string]string{"ARN": "arn:aws:secretsmanager:us-east-1:000000000000:secret:" + name, "Name": name})
+			}
+		}
+		writeJson(w, http.StatusOK, map[string]any{"SecretList": list})
+	case "secretsmanager.GetSecretValue":
+		if strings.HasSuffix(request["SecretId"].(string), "DENIED") {
+			writeJson(w, http.StatusBadRequest, map[string]string{"__type": "AccessDeniedException", "message": "not authorized to perform secretsmanager:GetSecretValue"})
+			return
+		}
+		value, found := fake.secrets[request["SecretId"].(string)]
+		if !found {
+			writeJson(w, http.StatusBadRequest, map[string]string{"__type": "ResourceNotFoundException", "message": "Secrets Manager can't find the specified secret."})
+			return
+		}
+		writeJson(w, http.StatusOK, map[string]string{"SecretString": value})
+	case "secretsmanager.CreateSecret":
+		name := request["Name"].(string)
+		if fake.deleted[name] {
+			writeJson(w, http.StatusBadRequest, map[string]string{"__type": "InvalidRequestException", "message": "You can't create this secret because a secret with this name is already scheduled for deletion."})
+			return
+		}
+		if _, found := fake.secrets[name]; found {
+			writeJson(w, http.StatusBadRequest, map[string]string{"__type": "ResourceExistsException", "message": "the secret already exists"})
+			return
+		}
+		fake.secrets[name] = request["SecretString"].(string)
+		writeJson(w, http.StatusOK, map[string]string{"Name": name})
+	case "secretsmanager.PutSecretValue":
+		if fake.deleted[request["SecretId"].(string)] {
+			writeJson(w, http.StatusBadRequest, map[string]string{"__type": "InvalidRequestException", "message": "You can't perform this operation on the secret because it was marked for deletion."})
+			return
+		}
+		fake.secrets[request["SecretId"].(string)] = request["SecretString"].(string)
+		writeJson(w, http.StatusOK, map[string]string{})
+	case "secretsmanager.DeleteSecret":
+		fake.deleted[request["SecretId"].(string)] = true
+		writeJson(w, http.StatusOK, map[string]string{})
+	case "secretsmanager.DescribeSecret":
+		name := request["SecretId"].(string)
+		if _, found := fake.secrets[name]; !found {
+			writeJson(w, http.StatusBadRequest, map[string]string{"__type": "ResourceNotFoundException", "message": "Secrets Manager can't find the specified secret."})
+			return
+		}
+		description := map[string]any{"Name": name}
+		if fake.deleted[name] {
+			description["DeletedDate"] = float64(time.Now().Unix())
+		}
+		writeJson(w, http.StatusOK, description)
+	case "secretsmanager.RestoreSecret":
+		delete(fake.deleted, request["SecretId"].(string))
+		writeJson(w, http.StatusOK, map[string]string{"Name": request["SecretId"].(string)})
+	case "AmazonSSM.GetParametersByPath":
+		path, recursive := request["Path"].(string), request["Recursive"].(bool)
+		parameters := make([]map[string]string, 0)
+		for _, name := range sortedKeys(fake.parameters) {
+			rest, found := strings.CutPrefix(name, path)
+			if found && (recursive || !strings.Contains(rest, "/")) {
+				parameters = append(parameters, map[string]string{"Name": name, "Value": fake.parameters[name]})
+			}
+		}
+		writeJson(w, http.StatusOK, map[string]any{"Parameters": parameters})
+	case "AmazonSSM.PutParameter":
+		name := request["Name"].(string)
+		if _, found := fake.parameters[name]; found && request["Overwrite"] != true {
+			writeJson(w, http.StatusBadRequest, map[string]string{"__type": "ParameterAlreadyExists", "message": "The parameter already exists."})
+			return
+		}
+		fake.parameters[name] = request["Value"].(string)
+		writeJson(w, http.StatusOK, map[string]int{"Version": 1})
+	case "AmazonSSM.DeleteParameter":
+		delete(fake.parameters, request["Name"].(string))
+		writeJson(w, http.StatusOK, map[string]string{})
+	default:
+		writeJson(w, http.StatusBadRequest, map[string]string{"__type": "InvalidAction"})
+	}
+}
+
+func writeJson(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Sets the environment so the SDK only finds the test credentials, the same ones LocalStack accepts.
+func testEnvironment(t *testing.T, accessKey string) {
+	t.Helper()
+	t.Setenv("AWS_ACCESS_KEY_ID", accessKey)
+	t.Setenv("AWS_SECRET_ACCESS_KEY", accessKey)
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+}
+
+func testConfig(t *testing.T, endpoint string) aws.Config {
+	t.Helper()
+	testEnvironment(t, "test")
+	return aws.Config{Region: "us-east-1", Endpoint: endpoint}
+}
+
+// Runs the tests against a real LocalStack when LOCALSTACK_ENDPOINT is set, otherwise against the fake.
+func localStackEndpoint(t *testing.T) string {
+	t.Helper()
+	if endpoint := os.Getenv("LOCALSTACK_ENDPOINT"); endpoint != "" {
+		return endpoint
+	}
+
+	_, endpoint := newFakeLocalStack(t)
+	return endpoint
+}
+
+func stores(t *testing.T, endpoint string) map[string]secrets.SecretStore {
+	t.Helper()
+	secretsManager, err := aws.NewSecretsManager(context.Background(), testConfig(t, endpoint))
+	if err != nil {
+		t.Fatalf("NewSecretsManager failed: %v", err)
+	}
+	parameterStore, err := aws.NewParameterStore(context.Background(), testConfig(t, endpoint))
+	if err != nil {
+		t.Fatalf("NewParameterStore failed: %v", err)
+	}
+
+	return map[string]secrets.SecretStore{"secretsmanager": secretsManager, "ssm": parameterStore}
+}
+
+func TestPushThenPull_UsesPrefixAsFolder(t *testing.T) {
+	for _, name := range []string{"secretsmanager", "ssm"} {
+		t.Run(name, func(t *testing.T) {
+			store := stores(t, localStackEndpoint(t))[name]
+			// deleted secrets are kept for a recovery window, so every run gets its own prefix
+			prefix := fmt.Sprintf("/dotsec-test/%s/%d/", name, time.Now().UnixNano())
+
+			folder, err := store.GetFolder(strings.TrimSuffix(prefix, "/"))
+			if err != nil {
+				t.Fatalf("GetFolder of a new prefix failed: %v", err)
+			}
+			if err := store.CreateSecret(folder, secrets.SecretData{Key: "API_KEY", Value: "old"}); err != nil {
+				t.Fatalf("CreateSecret failed: %v", err)
+			}
+			if err := store.CreateSecret(folder, secrets.SecretData{Key: "nested/IGNORED", Value: "value"}); err != nil {
+				t.Fatalf("CreateSecret failed: %v", err)
+			}
+
+			refs, err := store.ListSecrets(folder)
+			if err != nil {
+				t.Fatalf("ListSecrets failed: %v", err)
+			}
+			apiKey, found := secrets.FindSecretRef(refs, "API_KEY")
+			if !found || len(refs) != 1 {
+				t.Fatalf("ListSecrets() = %v, expected only API_KEY", refs)
+			}
+			if err := store.UpdateSecret(folder, apiKey, secrets.SecretData{Key: "API_KEY", Value: "new"}); err != nil {
+				t.Fatalf("UpdateSecret failed: %v", err)
+			}
+
+			actual, err := store.GetSecrets(folder)
+			if err != nil {
+				t.Fatalf("GetSecrets failed: %v", err)
+			}
+			expected := []secrets.SecretData{{Key: "API_KEY", Value: "new"}}
+			if !reflect.DeepEqual(actual, expected) {
+				t.Errorf("GetSecrets() = %v, expected %v", actual, expected)
+			}
+
+			if err := store.DeleteSecret(folder, apiKey); err != nil {
+				t.Fatalf("DeleteSecret failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestGetFolder_EmptyPrefix(t *testing.T) {
+	_, endpoint := newFakeLocalStack(t)
+	for name, store := range stores(t, endpoint) {
+		folder, err := store.GetFolder("/missing/")
+		if err != nil || folder.ID != "/missing/" {
+			t.Fatalf("%s GetFolder() = %v, %v, expected an empty prefix to be a folder so it can be pushed to", name, folder, err)
+		}
+		if secretsData, err := store.GetSecrets(folder); err != nil || len(secretsData) != 0 {
+			t.Errorf("%s GetSecrets() = %v, %v, expected the folder to be empty", name, secretsData, err)
+		}
+	}
+}
+
+func TestListFolders(t *testing.T) {
+	fake, endpoint := newFakeLocalStack(t)
+	fake.parameters["/myapp/dev/API_KEY"] = "a"
+	fake.parameters["/myapp/dev/DB"] = "b"
+	fake.parameters["/myapp/prod/API_KEY"] = "c"
+	store, _ := aws.NewParameterStore(context.Background(), testConfig(t, endpoint))
+
+	folders, err := store.ListFolders()
+	if err != nil {
+		t.Fatalf("ListFolders failed: %v", err)
+	}
+	expected := []secrets.Folder{{ID: "/myapp/dev/", Name: "/myapp/dev/"}, {ID: "/myapp/prod/", Name: "/myapp/prod/"}}
+	if !reflect.DeepEqual(folders, expected) {
+		t.Errorf("ListFolders() = %v, expected %v", folders, expected)
+	}
+}
+
+func TestNewClient_RequiresRegionAndCredentials(t *testing.T) {
+	testEnvironment(t, "test")
+	if _, err := aws.NewSecretsManager(context.Background(), aws.Config{}); !errors.Is(err, aws.NoRegionErr) {
+		t.Errorf("error = %v, expected %v", err, aws.NoRegionErr)
+	}
+	testEnvironment(t, "")
+	if _, err := aws.NewParameterStore(context.Background(), aws.Config{Region: "us-east-1"}); !errors.Is(err, aws.NoCredentialsErr) {
+		t.Errorf("error = %v, expected %v", err, aws.NoCredentialsErr)
+	}
+}
+
+func TestNewClient_LoadsProfileFromSharedFiles(t *testing.T) {
+	fake, endpoint := newFakeLocalStack(t)
+	fake.secrets["/myapp/dev/API_KEY"] = "from-profile"
+	testEnvironment(t, "")
+	os.WriteFile(os.Getenv("AWS_CONFIG_FILE"), []byte("[profile team]\nregion = us-west-2\n"), 0600)
+	os.WriteFile(os.Getenv("AWS_SHARED_CREDENTIALS_FILE"), []byte("[team]\naws_access_key_id = test\naws_secret_access_key = test\n"), 0600)
+
+	store, err := aws.NewSecretsManager(context.Background(), aws.Config{Profile: "team", Endpoint: endpoint})
+	if err != nil {
+		t.Fatalf("NewSecretsManager failed: %v", err)
+	}
+	actual, err := secrets.GetSecretsByFolder(store, "/myapp/dev")
+	if err != nil {
+		t.Fatalf("GetSecretsByFolder failed: %v", err)
+	}
+	expected := []secrets.SecretData{{Key: "API_KEY", Value: "from-profile"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("GetSecretsByFolder() = %v, expected %v", actual, expected)
+	}
+}
+
+func TestGetSecrets_ReturnsTheSecretsThatRead(t *testing.T) {
+	fake, endpoint := newFakeLocalStack(t)
+	fake.secrets["/myapp/dev/API_KEY"] = "secret123"
+	fake.secrets["/myapp/dev/DENIED"] = "hidden"
+	store, _ := aws.NewSecretsManager(context.Background(), testConfig(t, endpoint))
+
+	actual, err := secrets.GetSecretsByFolder(store, "/myapp/dev")
+	var failures secrets.SecretErrors
+	if !errors.As(err, &failures) || len(failures) != 1 || failures[0].Key != "DENIED" || failures[0].Operation != "read" {
+		t.Fatalf("GetSecretsByFolder() error = %v, expected a read failure for DENIED", err)
+	}
+	expected := []secrets.SecretData{{Key: "API_KEY", Value: "secret123"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("GetSecretsByFolder() = %v, expected %v", actual, expected)
+	}
+}
+
+func TestCreateSecret_RestoresSecretScheduledForDeletion(t *testing.T) {
+	fake, endpoint := newFakeLocalStack(t)
+	fake.secrets["/myapp/dev/API_KEY"] = "old"
+	fake.deleted["/myapp/dev/API_KEY"] = true
+	store, _ := aws.NewSecretsManager(context.Background(), testConfig(t, endpoint))
+
+	folder, _ := store.GetFolder("/myapp/dev")
+	if err := store.CreateSecret(folder, secrets.SecretData{Key: "API_KEY", Value: "new"}); err != nil {
+		t.Fatalf("CreateSecret of a pruned key failed: %v", err)
+	}
+
+	actual, err := store.GetSecrets(folder)
+	if err != nil {
+		t.Fatalf("GetSecrets failed: %v", err)
+	}
+	expected := []secrets.SecretData{{Key: "API_KEY", Value: "new"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("GetSecrets() = %v, expected %v", actual, expected)
+	}
+}
+
+func TestParameterStore_FolderWithoutLeadingSlash(t *testing.T) {
+	fake, endpoint := newFakeLocalStack(t)
+	fake.parameters["/myapp/dev/API_KEY"] = "secret123"
+	store, _ := aws.NewParameterStore(context.Background(), testConfig(t, endpoint))
+
+	actual, err := secrets.GetSecretsByFolder(store, "myapp/dev")
+	if err != nil {
+		t.Fatalf("GetSecretsByFolder failed: %v", err)
+	}
+	expected := []secrets.SecretData{{Key: "API_KEY", Value: "secret123"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("GetSecretsByFolder() = %v, expected %v", actual, expected)
+	}
+}
@@ -0,0 +1,90 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/chadsmith12/dotsec/secrets"
+)
+
+var (
+	InvalidFolderErr = secrets.ErrFolderNotFound
+	NoCredentialsErr = errors.New("no aws credentials found - set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY or configure a profile")
+	NoRegionErr      = errors.New("no aws region found - set awsRegion, AWS_REGION or a region for the profile")
+)
+
+// Where requests for a service are sent. Region and Profile override the ones from the environment and shared config
+// files, and Endpoint is only needed for LocalStack or other local stand ins.
+type Config struct {
+	Region   string
+	Profile  string
+	Endpoint string
+}
+
+// Loads the SDK config the same way the AWS CLI does, from the environment, the shared config and credentials files,
+// SSO, and the container or instance roles. The credentials are retrieved right away so a missing login fails early.
+func loadConfig(ctx context.Context, config Config) (awssdk.Config, error) {
+	options := make([]func(*awsconfig.LoadOptions) error, 0)
+	if config.Region != "" {
+		options = append(options, awsconfig.WithRegion(config.Region))
+	}
+	if config.Profile != "" {
+		options = append(options, awsconfig.WithSharedConfigProfile(config.Profile))
+	}
+
+	sdkConfig, err := awsconfig.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return awssdk.Config{}, fmt.Errorf("loading aws config: %w", err)
+	}
+	if sdkConfig.Region == "" {
+		return awssdk.Config{}, NoRegionErr
+	}
+	if sdkConfig.Credentials == nil {
+		return awssdk.Config{}, NoCredentialsErr
+	}
+	if _, err := sdkConfig.Credentials.Retrieve(ctx); err != nil {
+		return awssdk.Config{}, fmt.Errorf("%w: %w", NoCredentialsErr, err)
+	}
+
+	return sdkConfig, nil
+}
+
+// The endpoint to send requests to, or nil for the default endpoint of the region.
+func baseEndpoint(config Config) *string {
+	if config.Endpoint == "" {
+		return nil
+	}
+
+	return awssdk.String(strings.TrimRight(config.Endpoint, "/"))
+}
+
+// Folders are name prefixes such as /myapp/dev/, so the name always ends with a slash.
+func folderPrefix(name string) string {
+	return strings.TrimSuffix(name, "/") + "/"
+}
+
+// The parent prefix of every name, which is what we use as the folders.
+func parentFolders(names []string) []secrets.Folder {
+	seen := map[string]bool{}
+	folders := make([]secrets.Folder, 0)
+	for _, name := range names {
+		index := strings.LastIndex(name, "/")
+		if index < 0 {
+			continue
+		}
+		prefix := name[:index+1]
+		if seen[prefix] {
+			continue
+		}
+		seen[prefix] = true
+		folders = append(folders, secrets.Folder{ID: prefix, Name: prefix})
+	}
+	sort.Slice(folders, func(i, j int) bool { return folders[i].ID < folders[j].ID })
+
+	return folders
+}
@@ -0,0 +1,168 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/chadsmith12/dotsec/secrets"
+)
+
+// SecretsManager reads and writes AWS Secrets Manager secrets. A name prefix such as /myapp/dev/ is the folder,
+// and the rest of each secrets name is its key. Only the string value of a secret is used.
+type SecretsManager struct {
+	client  *secretsmanager.Client
+	context context.Context
+}
+
+// Initializes a Secrets Manager client for the region in config.
+func NewSecretsManager(ctx context.Context, config Config) (*SecretsManager, error) {
+	sdkConfig, err := loadConfig(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	client := secretsmanager.NewFromConfig(sdkConfig, func(options *secretsmanager.Options) {
+		options.BaseEndpoint = baseEndpoint(config)
+	})
+
+	return &SecretsManager{client: client, context: ctx}, nil
+}
+
+func (store *SecretsManager) ListFolders() ([]secrets.Folder, error) {
+	list, err := store.list("")
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(list))
+	for _, secret := range list {
+		names = append(names, awssdk.ToString(secret.Name))
+	}
+
+	return parentFolders(names), nil
+}
+
+// A prefix isn't a resource in Secrets Manager, so any prefix is a folder, and one without secrets is empty.
+// Pushing to a new prefix creates it along with its first secret.
+func (store *SecretsManager) GetFolder(name string) (secrets.Folder, error) {
+	return secrets.Folder{ID: folderPrefix(name), Name: name}, nil
+}
+
+// Lists the secrets directly under the prefix, skipping any under a nested prefix.
+func (store *SecretsManager) ListSecrets(folder secrets.Folder) ([]secrets.SecretRef, error) {
+	list, err := store.list(folder.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]secrets.SecretRef, 0, len(list))
+	for _, secret := range list {
+		name := awssdk.ToString(secret.Name)
+		key := strings.TrimPrefix(name, folder.ID)
+		if !strings.HasPrefix(name, folder.ID) || key == "" || strings.Contains(key, "/") {
+			continue
+		}
+		refs = append(refs, secrets.SecretRef{ID: name, Key: key})
+	}
+
+	return refs, nil
+}
+
+func (store *SecretsManager) GetSecrets(folder secrets.Folder) ([]secrets.SecretData, error) {
+	refs, err := store.ListSecrets(folder)
+	if err != nil {
+		return []secrets.SecretData{}, err
+	}
+
+	secretData := make([]secrets.SecretData, 0, len(refs))
+	var errs secrets.SecretErrors
+	for _, ref := range refs {
+		value, err := store.client.GetSecretValue(store.context, &secretsmanager.GetSecretValueInput{SecretId: awssdk.String(ref.ID)})
+		if err != nil {
+			errs = append(errs, secrets.SecretError{Key: ref.Key, Operation: "read", Err: err})
+			continue
+		}
+		secretData = append(secretData, secrets.SecretData{Key: ref.Key, Value: awssdk.ToString(value.SecretString)})
+	}
+
+	return secretData, errs.Err()
+}
+
+// Creates the secret. A secret that was deleted but is still in its recovery window keeps the name,
+// so it is restored and given the new value instead.
+func (store *SecretsManager) CreateSecret(folder secrets.Folder, secret secrets.SecretData) error {
+	name := folder.ID + secret.Key
+	_, err := store.client.CreateSecret(store.context, &secretsmanager.CreateSecretInput{
+		Name:         awssdk.String(name),
+		SecretString: awssdk.String(secret.Value),
+	})
+
+	var invalidRequest *types.InvalidRequestException
+	if !errors.As(err, &invalidRequest) {
+		return err
+	}
+	scheduled, describeErr := store.scheduledForDeletion(name)
+	if describeErr != nil || !scheduled {
+		return err
+	}
+
+	if _, err := store.client.RestoreSecret(store.context, &secretsmanager.RestoreSecretInput{SecretId: awssdk.String(name)}); err != nil {
+		return fmt.Errorf("restoring deleted secret %s: %w", name, err)
+	}
+	_, err = store.client.PutSecretValue(store.context, &secretsmanager.PutSecretValueInput{
+		SecretId:     awssdk.String(name),
+		SecretString: awssdk.String(secret.Value),
+	})
+
+	return err
+}
+
+// Puts a new version of the secret, which becomes the AWSCURRENT version.
+func (store *SecretsManager) UpdateSecret(folder secrets.Folder, ref secrets.SecretRef, secret secrets.SecretData) error {
+	_, err := store.client.PutSecretValue(store.context, &secretsmanager.PutSecretValueInput{
+		SecretId:     awssdk.String(ref.ID),
+		SecretString: awssdk.String(secret.Value),
+	})
+
+	return err
+}
+
+// Schedules the secret for deletion with the default recovery window, so it can still be restored.
+func (store *SecretsManager) DeleteSecret(folder secrets.Folder, ref secrets.SecretRef) error {
+	_, err := store.client.DeleteSecret(store.context, &secretsmanager.DeleteSecretInput{SecretId: awssdk.String(ref.ID)})
+	return err
+}
+
+func (store *SecretsManager) scheduledForDeletion(name string) (bool, error) {
+	description, err := store.client.DescribeSecret(store.context, &secretsmanager.DescribeSecretInput{SecretId: awssdk.String(name)})
+	if err != nil {
+		return false, err
+	}
+
+	return description.DeletedDate != nil, nil
+}
+
+// Lists every secret whose name starts with the prefix, following the pages of results.
+func (store *SecretsManager) list(prefix string) ([]types.SecretListEntry, error) {
+	input := &secretsmanager.ListSecretsInput{}
+	if prefix != "" {
+		input.Filters = []types.Filter{{Key: types.FilterNameStringTypeName, Values: []string{prefix}}}
+	}
+
+	list := make([]types.SecretListEntry, 0)
+	pages := secretsmanager.NewListSecretsPaginator(store.client, input)
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(store.context)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, page.SecretList...)
+	}
+
+	return list, nil
+}
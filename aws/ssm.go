@@ -0,0 +1,131 @@
+package aws
+
+import (
+	"context"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/chadsmith12/dotsec/secrets"
+)
+
+// ParameterStore reads and writes SSM Parameter Store parameters. A path such as /myapp/dev/ is the folder,
+// and the last part of each parameters name is its key. New parameters are created as SecureStrings.
+type ParameterStore struct {
+	client  *ssm.Client
+	context context.Context
+}
+
+// Initializes a Parameter Store client for the region in config.
+func NewParameterStore(ctx context.Context, config Config) (*ParameterStore, error) {
+	sdkConfig, err := loadConfig(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	client := ssm.NewFromConfig(sdkConfig, func(options *ssm.Options) {
+		options.BaseEndpoint = baseEndpoint(config)
+	})
+
+	return &ParameterStore{client: client, context: ctx}, nil
+}
+
+func (store *ParameterStore) ListFolders() ([]secrets.Folder, error) {
+	parameters, err := store.parameters("/", true)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(parameters))
+	for _, parameter := range parameters {
+		names = append(names, awssdk.ToString(parameter.Name))
+	}
+
+	return parentFolders(names), nil
+}
+
+// A path isn't a resource in Parameter Store, so any path is a folder, and one without parameters is empty.
+// Pushing to a new path creates it along with its first parameter. Parameter paths always start with a slash,
+// so myapp/dev is the same folder as /myapp/dev.
+func (store *ParameterStore) GetFolder(name string) (secrets.Folder, error) {
+	return secrets.Folder{ID: "/" + strings.TrimPrefix(folderPrefix(name), "/"), Name: name}, nil
+}
+
+func (store *ParameterStore) ListSecrets(folder secrets.Folder) ([]secrets.SecretRef, error) {
+	parameters, err := store.parameters(folder.ID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]secrets.SecretRef, 0, len(parameters))
+	for _, parameter := range parameters {
+		name := awssdk.ToString(parameter.Name)
+		refs = append(refs, secrets.SecretRef{ID: name, Key: strings.TrimPrefix(name, folder.ID)})
+	}
+
+	return refs, nil
+}
+
+// Gets every parameter directly under the path, decrypting SecureStrings.
+func (store *ParameterStore) GetSecrets(folder secrets.Folder) ([]secrets.SecretData, error) {
+	parameters, err := store.parameters(folder.ID, false)
+	if err != nil {
+		return []secrets.SecretData{}, err
+	}
+
+	secretData := make([]secrets.SecretData, 0, len(parameters))
+	for _, parameter := range parameters {
+		key := strings.TrimPrefix(awssdk.ToString(parameter.Name), folder.ID)
+		secretData = append(secretData, secrets.SecretData{Key: key, Value: awssdk.ToString(parameter.Value)})
+	}
+
+	return secretData, nil
+}
+
+func (store *ParameterStore) CreateSecret(folder secrets.Folder, secret secrets.SecretData) error {
+	_, err := store.client.PutParameter(store.context, &ssm.PutParameterInput{
+		Name:  awssdk.String(folder.ID + secret.Key),
+		Value: awssdk.String(secret.Value),
+		Type:  types.ParameterTypeSecureString,
+	})
+
+	return err
+}
+
+// Overwrites the value of the parameter, keeping its type.
+func (store *ParameterStore) UpdateSecret(folder secrets.Folder, ref secrets.SecretRef, secret secrets.SecretData) error {
+	_, err := store.client.PutParameter(store.context, &ssm.PutParameterInput{
+		Name:      awssdk.String(ref.ID),
+		Value:     awssdk.String(secret.Value),
+		Overwrite: awssdk.Bool(true),
+	})
+
+	return err
+}
+
+func (store *ParameterStore) DeleteSecret(folder secrets.Folder, ref secrets.SecretRef) error {
+	_, err := store.client.DeleteParameter(store.context, &ssm.DeleteParameterInput{Name: awssdk.String(ref.ID)})
+	return err
+}
+
+// Gets the parameters under the path, following the pages of results.
+func (store *ParameterStore) parameters(path string, recursive bool) ([]types.Parameter, error) {
+	input := &ssm.GetParametersByPathInput{
+		Path:           awssdk.String(path),
+		Recursive:      awssdk.Bool(recursive),
+		WithDecryption: awssdk.Bool(true),
+	}
+
+	parameters := make([]types.Parameter, 0)
+	pages := ssm.NewGetParametersByPathPaginator(store.client, input)
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(store.context)
+		if err != nil {
+			return nil, err
+		}
+		parameters = append(parameters, page.Parameters...)
+	}
+
+	return parameters, nil
+}
@@ -0,0 +1,369 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/chadsmith12/dotsec/secrets"
+)
+
+const (
+	vaultScope = "https://vault.azure.net/.default"
+	// Key Vault names can't contain a colon, so the .NET configuration provider uses -- as the section separator
+	nameSeparator = "--"
+	// how long to wait for a deleted secret to be recovered before setting its value
+	recoverTimeout = 30 * time.Second
+	// the first wait between checks on a recovering secret, it doubles up to a few seconds
+	recoverPollInterval = 250 * time.Millisecond
+)
+
+var (
+	InvalidFolderErr = secrets.ErrFolderNotFound
+	NotLoggedInErr   = errors.New("not logged in to azure")
+	validName        = regexp.MustCompile(`^[0-9a-zA-Z-]{1,127}$`)
+)
+
+// KeyVaultApi talks to Azure Key Vault with the Azure SDK. A key vault is the folder, and each enabled secret in it is a SecretData.
+// Secret names are translated between the Key Vault convention of Section--Key and the .NET configuration key Section:Key.
+type KeyVaultApi struct {
+	endpoint   string
+	credential azcore.TokenCredential
+	httpClient *http.Client
+	context    context.Context
+	mu         sync.Mutex
+	clients    map[string]*azsecrets.Client
+}
+
+// An access token that was handed to dotsec, which can't be refreshed.
+type staticToken struct {
+	token string
+}
+
+func (credential staticToken) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: credential.token, ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+// Initializes a new Key Vault Api. When endpoint is set, every folder is read from that vault instead of
+// https://<folder>.vault.azure.net, which is how dotsec is pointed at a local emulator.
+// httpClient is used for Key Vault and the logins, and the SDK's default client is used when it is nil.
+// The client needs to be logged in with one of the login methods before it is used.
+func NewClient(ctx context.Context, endpoint string, httpClient *http.Client) (*KeyVaultApi, error) {
+	if endpoint != "" {
+		if _, err := url.Parse(endpoint); err != nil {
+			return nil, fmt.Errorf("invalid key vault endpoint: %w", err)
+		}
+	}
+
+	return &KeyVaultApi{
+		endpoint:   strings.TrimRight(endpoint, "/"),
+		httpClient: httpClient,
+		context:    ctx,
+		clients:    map[string]*azsecrets.Client{},
+	}, nil
+}
+
+// Uses an existing access token for the https://vault.azure.net resource.
+func (client *KeyVaultApi) LoginWithToken(token string) {
+	client.login(staticToken{token: token})
+}
+
+// Logs in as a service principal with the client credentials flow. authorityHost defaults to the
+// Azure public cloud, or AZURE_AUTHORITY_HOST when it is set.
+func (client *KeyVaultApi) LoginWithClientSecret(authorityHost, tenantId, clientId, clientSecret string) error {
+	options := &azidentity.ClientSecretCredentialOptions{ClientOptions: client.clientOptions()}
+	if authorityHost != "" {
+		options.Cloud = cloud.Configuration{ActiveDirectoryAuthorityHost: authorityHost}
+		// a custom host is a private cloud or a stand in that the public instance discovery doesn't know about
+		options.DisableInstanceDiscovery = true
+	}
+
+	credential, err := azidentity.NewClientSecretCredential(tenantId, clientId, clientSecret, options)
+	if err != nil {
+		return fmt.Errorf("logging in with client secret: %w", err)
+	}
+	if err := client.checkLogin(credential); err != nil {
+		return fmt.Errorf("logging in with client secret: %w", err)
+	}
+	client.login(credential)
+
+	return nil
+}
+
+// Uses the account logged in with az login.
+func (client *KeyVaultApi) LoginWithAzureCli() error {
+	credential, err := azidentity.NewAzureCLICredential(nil)
+	if err != nil {
+		return fmt.Errorf("logging in with the azure cli: %w", err)
+	}
+	if err := client.checkLogin(credential); err != nil {
+		return fmt.Errorf("logging in with the azure cli: %w", err)
+	}
+	client.login(credential)
+
+	return nil
+}
+
+// Only the vault at the endpoint override is known, since listing vaults needs the Azure management api.
+func (client *KeyVaultApi) ListFolders() ([]secrets.Folder, error) {
+	if client.endpoint == "" {
+		return []secrets.Folder{}, nil
+	}
+
+	return []secrets.Folder{{ID: client.endpoint, Name: client.endpoint}}, nil
+}
+
+// Finds the key vault by its name, such as myapp-dev, or its full url.
+func (client *KeyVaultApi) GetFolder(name string) (secrets.Folder, error) {
+	folder := secrets.Folder{ID: client.vaultUrl(name), Name: name}
+	if _, err := client.listSecrets(folder, true); err != nil {
+		if isStatus(err, http.StatusNotFound) {
+			return secrets.Folder{}, InvalidFolderErr
+		}
+		return secrets.Folder{}, err
+	}
+
+	return folder, nil
+}
+
+// Lists the enabled secrets in the vault, skipping the secrets Key Vault manages for certificates.
+func (client *KeyVaultApi) ListSecrets(folder secrets.Folder) ([]secrets.SecretRef, error) {
+	items, err := client.listSecrets(folder, false)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]secrets.SecretRef, 0, len(items))
+	for _, item := range items {
+		if item.ID == nil || (item.Managed != nil && *item.Managed) || item.Attributes == nil || item.Attributes.Enabled == nil || !*item.Attributes.Enabled {
+			continue
+		}
+		name := item.ID.Name()
+		refs = append(refs, secrets.SecretRef{ID: name, Key: ToConfigKey(name)})
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Key < refs[j].Key })
+
+	return refs, nil
+}
+
+// Gets the current version of every secret in the vault.
+func (client *KeyVaultApi) GetSecrets(folder secrets.Folder) ([]secrets.SecretData, error) {
+	refs, err := client.ListSecrets(folder)
+	if err != nil {
+		return []secrets.SecretData{}, err
+	}
+	vault, err := client.vault(folder)
+	if err != nil {
+		return []secrets.SecretData{}, err
+	}
+
+	secretData := make([]secrets.SecretData, 0, len(refs))
+	var errs secrets.SecretErrors
+	for _, ref := range refs {
+		secret, err := vault.GetSecret(client.context, ref.ID, "", nil)
+		if err != nil {
+			errs = append(errs, secrets.SecretError{Key: ref.Key, Operation: "read", Err: fmt.Errorf("getting %s: %w", ref.ID, err)})
+			continue
+		}
+		value := ""
+		if secret.Value != nil {
+			value = *secret.Value
+		}
+		secretData = append(secretData, secrets.SecretData{Key: ref.Key, Value: value})
+	}
+
+	return secretData, errs.Err()
+}
+
+// Sets the secret, recovering it first when a secret with the same name was deleted but not purged.
+// Recovery finishes in the background, so the secret is set once it can be read again.
+func (client *KeyVaultApi) CreateSecret(folder secrets.Folder, secret secrets.SecretData) error {
+	name, err := ToSecretName(secret.Key)
+	if err != nil {
+		return err
+	}
+
+	err = client.setSecret(folder, name, secret.Value)
+	if !isStatus(err, http.StatusConflict) {
+		return err
+	}
+
+	vault, err := client.vault(folder)
+	if err != nil {
+		return err
+	}
+	if _, err := vault.RecoverDeletedSecret(client.context, name, nil); err != nil {
+		return fmt.Errorf("recovering deleted secret %s: %w", name, err)
+	}
+	if err := client.waitForRecovery(vault, name); err != nil {
+		return fmt.Errorf("recovering deleted secret %s: %w", name, err)
+	}
+
+	return client.setSecret(folder, name, secret.Value)
+}
+
+// Adds a new version of the secret with the value.
+func (client *KeyVaultApi) UpdateSecret(folder secrets.Folder, ref secrets.SecretRef, secret secrets.SecretData) error {
+	return client.setSecret(folder, ref.ID, secret.Value)
+}
+
+// Deletes the secret. With soft delete enabled it can be recovered until it is purged.
+func (client *KeyVaultApi) DeleteSecret(folder secrets.Folder, ref secrets.SecretRef) error {
+	vault, err := client.vault(folder)
+	if err != nil {
+		return err
+	}
+
+	_, err = vault.DeleteSecret(client.context, ref.ID, nil)
+	return err
+}
+
+// Converts a Key Vault secret name into a .NET configuration key, so ConnectionStrings--Default becomes ConnectionStrings:Default.
+func ToConfigKey(name string) string {
+	return strings.ReplaceAll(name, nameSeparator, ":")
+}
+
+// Converts a .NET configuration key into a Key Vault secret name, so ConnectionStrings:Default becomes ConnectionStrings--Default.
+// Returns an error when the key has characters Key Vault does not allow.
+func ToSecretName(key string) (string, error) {
+	name := strings.ReplaceAll(key, ":", nameSeparator)
+	if !validName.MatchString(name) {
+		return "", fmt.Errorf("%s cannot be stored in key vault: names can only contain letters, numbers and dashes", key)
+	}
+
+	return name, nil
+}
+
+func (client *KeyVaultApi) vaultUrl(name string) string {
+	if strings.HasPrefix(name, "https://") || strings.HasPrefix(name, "http://") {
+		return strings.TrimRight(name, "/")
+	}
+	if client.endpoint != "" {
+		return client.endpoint
+	}
+
+	return fmt.Sprintf("https://%s.vault.azure.net", name)
+}
+
+func (client *KeyVaultApi) login(credential azcore.TokenCredential) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.credential = credential
+	client.clients = map[string]*azsecrets.Client{}
+}
+
+// Gets a token right away so a wrong secret or a missing az login fails before anything is read.
+func (client *KeyVaultApi) checkLogin(credential azcore.TokenCredential) error {
+	_, err := credential.GetToken(client.context, policy.TokenRequestOptions{Scopes: []string{vaultScope}})
+	return err
+}
+
+func (client *KeyVaultApi) clientOptions() azcore.ClientOptions {
+	options := azcore.ClientOptions{}
+	if client.httpClient != nil {
+		options.Transport = client.httpClient
+	}
+
+	return options
+}
+
+// The SDK client for the vault of the folder, which is created the first time the vault is used.
+func (client *KeyVaultApi) vault(folder secrets.Folder) (*azsecrets.Client, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if client.credential == nil {
+		return nil, NotLoggedInErr
+	}
+	if vault, found := client.clients[folder.ID]; found {
+		return vault, nil
+	}
+
+	options := &azsecrets.ClientOptions{
+		ClientOptions: client.clientOptions(),
+		// an emulator isn't hosted on vault.azure.net, so the resource it asks for can't match its own host
+		DisableChallengeResourceVerification: client.endpoint != "",
+	}
+	vault, err := azsecrets.NewClient(folder.ID, client.credential, options)
+	if err != nil {
+		return nil, fmt.Errorf("creating key vault client: %w", err)
+	}
+	client.clients[folder.ID] = vault
+
+	return vault, nil
+}
+
+func (client *KeyVaultApi) setSecret(folder secrets.Folder, name, value string) error {
+	vault, err := client.vault(folder)
+	if err != nil {
+		return err
+	}
+
+	_, err = vault.SetSecret(client.context, name, azsecrets.SetSecretParameters{Value: &value}, nil)
+	return err
+}
+
+// Waits until the recovered secret can be read, since setting it while it is still being recovered is another conflict.
+func (client *KeyVaultApi) waitForRecovery(vault *azsecrets.Client, name string) error {
+	ctx, cancel := context.WithTimeout(client.context, recoverTimeout)
+	defer cancel()
+
+	interval := recoverPollInterval
+	for {
+		_, err := vault.GetSecret(ctx, name, "", nil)
+		if err == nil {
+			return nil
+		}
+		if !isStatus(err, http.StatusNotFound) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("secret is still being recovered: %w", ctx.Err())
+		case <-time.After(interval):
+		}
+		interval = min(interval*2, 4*time.Second)
+	}
+}
+
+// Lists the secrets in the vault, following the next links. When firstPage is set only the first page is read.
+func (client *KeyVaultApi) listSecrets(folder secrets.Folder, firstPage bool) ([]*azsecrets.SecretProperties, error) {
+	vault, err := client.vault(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*azsecrets.SecretProperties, 0)
+	pager := vault.NewListSecretPropertiesPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(client.context)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page.Value...)
+
+		if firstPage {
+			break
+		}
+	}
+
+	return items, nil
+}
+
+func isStatus(err error, status int) bool {
+	var responseErr *azcore.ResponseError
+	return errors.As(err, &responseErr) && responseErr.StatusCode == status
+}
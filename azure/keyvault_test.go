@@ -0,0 +1,324 @@
+package azure_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/chadsmith12/dotsec/azure"
+	"github.com/chadsmith12/dotsec/secrets"
+)
+
+const accessToken = "token"
+
+type fakeSecret struct {
+	value   string
+	enabled bool
+	managed bool
+	deleted bool
+	// the number of reads that still can't find the secret after it is recovered
+	recovering int
+	// reading the value is denied by an access policy
+	forbidden bool
+}
+
+// fakeKeyVault is a stand in for a Key Vault emulator with soft delete enabled.
+type fakeKeyVault struct {
+	mu         sync.Mutex
+	url        string
+	httpClient *http.Client
+	secrets    map[string]*fakeSecret
+	order      []string
+	// set when a secret was written while it was still being recovered
+	conflicts int
+}
+
+// The SDK only sends tokens over https, so the fake is served with the test certificate that httpClient trusts.
+func newFakeKeyVault(t *testing.T) (*fakeKeyVault, string) {
+	t.Helper()
+	fake := &fakeKeyVault{secrets: map[string]*fakeSecret{}}
+	server := httptest.NewTLSServer(fake)
+	t.Cleanup(server.Close)
+	fake.url = server.URL
+	fake.httpClient = server.Client()
+
+	return fake, server.URL
+}
+
+func (fake *fakeKeyVault) add(name, value string) *fakeSecret {
+	secret := &fakeSecret{value: value, enabled: true}
+	fake.secrets[name] = secret
+	fake.order = append(fake.order, name)
+
+	return secret
+}
+
+func (fake *fakeKeyVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if r.URL.Query().Get("api-version") == "" {
+		writeError(w, http.StatusBadRequest, "BadParameter", "The api-version is missing.")
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+accessToken {
+		// the SDK sends its first request without a token to find out which tenant and resource to ask for
+		w.Header().Set("WWW-Authenticate", `Bearer authorization="https://login.microsoftonline.com/tenant", resource="https://vault.azure.net"`)
+		writeError(w, http.StatusUnauthorized, "Unauthorized", "AKV10000: Request is missing a Bearer or PoP token.")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == http.MethodGet && len(parts) == 1 && parts[0] == "secrets":
+		// one secret per page so the next links are followed
+		start := 0
+		if skip := r.URL.Query().Get("skip"); skip != "" {
+			start, _ = strconv.Atoi(skip)
+		}
+		page := map[string]any{"value": []any{}}
+		visible := make([]string, 0)
+		for _, name := range fake.order {
+			if !fake.secrets[name].deleted {
+				visible = append(visible, name)
+			}
+		}
+		if start < len(visible) {
+			secret := fake.secrets[visible[start]]
+			page["value"] = []any{map[string]any{
+				"id":         fake.url + "/secrets/" + visible[start],
+				"managed":    secret.managed,
+				"attributes": map[string]any{"enabled": secret.enabled},
+			}}
+		}
+		if start+1 < len(visible) {
+			page["nextLink"] = fake.url + "/secrets?api-version=7.4&skip=" + strconv.Itoa(start+1)
+		}
+		writeJson(w, http.StatusOK, page)
+	case len(parts) == 2 && parts[0] == "secrets":
+		secret, found := fake.secrets[parts[1]]
+		switch r.Method {
+		case http.MethodGet:
+			if found && secret.recovering > 0 {
+				secret.recovering--
+			}
+			if !found || secret.deleted || secret.recovering > 0 {
+				writeError(w, http.StatusNotFound, "SecretNotFound", "A secret with (name/id) "+parts[1]+" was not found in this key vault.")
+				return
+			}
+			if secret.forbidden {
+				writeError(w, http.StatusForbidden, "Forbidden", "The user does not have secrets get permission on key vault.")
+				return
+			}
+			writeJson(w, http.StatusOK, map[string]any{"value": secret.value, "id": fake.url + "/secrets/" + parts[1] + "/version"})
+		case http.MethodPut:
+			if found && secret.deleted {
+				writeError(w, http.StatusConflict, "Conflict", "Secret "+parts[1]+" is currently in a deleted but recoverable state.")
+				return
+			}
+			if found && secret.recovering > 0 {
+				fake.conflicts++
+				writeError(w, http.StatusConflict, "ObjectIsBeingRecovered", "Secret "+parts[1]+" is currently being recovered.")
+				return
+			}
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			if !found {
+				secret = fake.add(parts[1], "")
+			}
+			secret.value = body["value"]
+			writeJson(w, http.StatusOK, map[string]any{"value": secret.value})
+		case http.MethodDelete:
+			secret.deleted = true
+			writeJson(w, http.StatusOK, map[string]any{})
+		}
+	case r.Method == http.MethodPost && len(parts) == 3 && parts[0] == "deletedsecrets" && parts[2] == "recover":
+		secret := fake.secrets[parts[1]]
+		secret.deleted = false
+		secret.recovering = 2
+		writeJson(w, http.StatusOK, map[string]any{})
+	default:
+		writeError(w, http.StatusNotFound, "NotFound", "")
+	}
+}
+
+func writeJson(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJson(w, status, map[string]any{"error": map[string]string{"code": code, "message": message}})
+}
+
+func newLoggedInClient(t *testing.T, fake *fakeKeyVault) *azure.KeyVaultApi {
+	t.Helper()
+	client, err := azure.NewClient(context.Background(), fake.url, fake.httpClient)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	client.LoginWithToken(accessToken)
+
+	return client
+}
+
+func TestSecretNameTranslation(t *testing.T) {
+	if key := azure.ToConfigKey("ConnectionStrings--Default"); key != "ConnectionStrings:Default" {
+		t.Errorf("ToConfigKey() = %s, expected ConnectionStrings:Default", key)
+	}
+
+	name, err := azure.ToSecretName("Logging:LogLevel:Default")
+	if err != nil || name != "Logging--LogLevel--Default" {
+		t.Errorf("ToSecretName() = %s, %v, expected Logging--LogLevel--Default", name, err)
+	}
+	if _, err := azure.ToSecretName("API_KEY"); err == nil {
+		t.Error("ToSecretName should fail for keys with characters key vault does not allow")
+	}
+}
+
+func TestGetSecrets_TranslatesNames(t *testing.T) {
+	fake, _ := newFakeKeyVault(t)
+	fake.add("ConnectionStrings--Default", "Server=localhost")
+	fake.add("ApiKey", "secret123")
+	fake.add("Disabled", "old").enabled = false
+	fake.add("my-certificate", "cert").managed = true
+	client := newLoggedInClient(t, fake)
+
+	actual, err := secrets.GetSecretsByFolder(client, "myapp-dev")
+	if err != nil {
+		t.Fatalf("GetSecretsByFolder failed: %v", err)
+	}
+
+	expected := []secrets.SecretData{{Key: "ApiKey", Value: "secret123"}, {Key: "ConnectionStrings:Default", Value: "Server=localhost"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("GetSecretsByFolder() = %v, expected %v", actual, expected)
+	}
+}
+
+func TestPush_SetsAndDeletesSecrets(t *testing.T) {
+	fake, endpoint := newFakeKeyVault(t)
+	fake.add("ApiKey", "old")
+	fake.add("Stale", "value")
+	fake.add("Recovered", "deleted").deleted = true
+	client := newLoggedInClient(t, fake)
+
+	folder, err := client.GetFolder(endpoint)
+	if err != nil {
+		t.Fatalf("GetFolder failed: %v", err)
+	}
+	refs, err := client.ListSecrets(folder)
+	if err != nil {
+		t.Fatalf("ListSecrets failed: %v", err)
+	}
+	apiKey, _ := secrets.FindSecretRef(refs, "ApiKey")
+	stale, _ := secrets.FindSecretRef(refs, "Stale")
+
+	if err := client.UpdateSecret(folder, apiKey, secrets.SecretData{Key: "ApiKey", Value: "new"}); err != nil {
+		t.Fatalf("UpdateSecret failed: %v", err)
+	}
+	if err := client.CreateSecret(folder, secrets.SecretData{Key: "Database:Password", Value: "hunter2"}); err != nil {
+		t.Fatalf("CreateSecret failed: %v", err)
+	}
+	if err := client.CreateSecret(folder, secrets.SecretData{Key: "Recovered", Value: "again"}); err != nil {
+		t.Fatalf("CreateSecret should recover soft deleted secrets: %v", err)
+	}
+	if err := client.DeleteSecret(folder, stale); err != nil {
+		t.Fatalf("DeleteSecret failed: %v", err)
+	}
+
+	actual, err := client.GetSecrets(folder)
+	if err != nil {
+		t.Fatalf("GetSecrets failed: %v", err)
+	}
+	expected := []secrets.SecretData{
+		{Key: "ApiKey", Value: "new"},
+		{Key: "Database:Password", Value: "hunter2"},
+		{Key: "Recovered", Value: "again"},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("GetSecrets() = %v, expected %v", actual, expected)
+	}
+	if fake.conflicts != 0 {
+		t.Errorf("the recovered secret should only be set once recovery finished, got %d conflicts", fake.conflicts)
+	}
+}
+
+func TestLoginWithClientSecret(t *testing.T) {
+	var authority *httptest.Server
+	authority = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tenant/v2.0/.well-known/openid-configuration":
+			writeJson(w, http.StatusOK, map[string]string{
+				"authorization_endpoint": authority.URL + "/tenant/oauth2/v2.0/authorize",
+				"token_endpoint":         authority.URL + "/tenant/oauth2/v2.0/token",
+				"issuer":                 authority.URL + "/tenant/v2.0",
+			})
+		case "/tenant/oauth2/v2.0/token":
+			r.ParseForm()
+			if r.Form.Get("client_secret") != "secret" || !strings.Contains(r.Form.Get("scope"), "https://vault.azure.net/.default") {
+				writeJson(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client", "error_description": "AADSTS7000215: Invalid client secret provided."})
+				return
+			}
+			writeJson(w, http.StatusOK, map[string]any{"access_token": accessToken, "token_type": "Bearer", "expires_in": 3600})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(authority.Close)
+	fake, endpoint := newFakeKeyVault(t)
+
+	client, _ := azure.NewClient(context.Background(), endpoint, fake.httpClient)
+	if err := client.LoginWithClientSecret(authority.URL, "tenant", "client", "wrong"); err == nil {
+		t.Error("LoginWithClientSecret should fail with the wrong secret")
+	}
+	if err := client.LoginWithClientSecret(authority.URL, "tenant", "client", "secret"); err != nil {
+		t.Fatalf("LoginWithClientSecret failed: %v", err)
+	}
+	if _, err := client.GetFolder("myapp-dev"); err != nil {
+		t.Errorf("GetFolder failed after logging in: %v", err)
+	}
+}
+
+func TestGetFolder_NotLoggedIn(t *testing.T) {
+	fake, endpoint := newFakeKeyVault(t)
+	client, _ := azure.NewClient(context.Background(), endpoint, fake.httpClient)
+
+	if _, err := client.GetFolder("myapp-dev"); !errors.Is(err, azure.NotLoggedInErr) {
+		t.Errorf("GetFolder() error = %v, expected NotLoggedInErr", err)
+	}
+}
+
+func TestGetFolder_Unauthorized(t *testing.T) {
+	fake, endpoint := newFakeKeyVault(t)
+	client, _ := azure.NewClient(context.Background(), endpoint, fake.httpClient)
+	client.LoginWithToken("expired")
+
+	if _, err := client.GetFolder("myapp-dev"); err == nil || errors.Is(err, azure.InvalidFolderErr) {
+		t.Errorf("GetFolder() error = %v, expected an unauthorized error", err)
+	}
+}
+
+func TestGetSecrets_ReturnsTheSecretsThatRead(t *testing.T) {
+	fake, _ := newFakeKeyVault(t)
+	fake.add("ApiKey", "secret123")
+	fake.add("BROKEN", "hidden").forbidden = true
+	client := newLoggedInClient(t, fake)
+
+	actual, err := secrets.GetSecretsByFolder(client, "myapp-dev")
+	var failures secrets.SecretErrors
+	if !errors.As(err, &failures) || len(failures) != 1 || failures[0].Key != "BROKEN" || failures[0].Operation != "read" {
+		t.Fatalf("GetSecretsByFolder() error = %v, expected a read failure for BROKEN", err)
+	}
+	expected := []secrets.SecretData{{Key: "ApiKey", Value: "secret123"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("GetSecretsByFolder() = %v, expected %v", actual, expected)
+	}
+}
@@ -0,0 +1,310 @@
+package bitwarden
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/chadsmith12/dotsec/secrets"
+)
+
+const (
+	loginItemType = 1
+	sessionEnv    = "BW_SESSION"
+	passwordEnv   = "BW_PASSWORD"
+)
+
+var (
+	InvalidFolderErr   = secrets.ErrFolderNotFound
+	UnauthenticatedErr = errors.New("not logged in to Bitwarden - run bw login first")
+)
+
+// BitwardenCli talks to Bitwarden or Vaultwarden by shelling out to the bw CLI.
+// A Bitwarden folder is used as the folder, and the name and password of each login item in it are the secrets.
+type BitwardenCli struct {
+	binary  string
+	session string
+}
+
+type bwFolder struct {
+	Id   *string `json:"id"`
+	Name string  `json:"name"`
+}
+
+type bwItem struct {
+	Id    string `json:"id"`
+	Type  int    `json:"type"`
+	Name  string `json:"name"`
+	Login *struct {
+		Password *string `json:"password"`
+	} `json:"login"`
+}
+
+// Initializes a BitwardenCli that runs the bw binary, which is looked up on the PATH when it is not a path.
+func NewClient(binary string) *BitwardenCli {
+	if binary == "" {
+		binary = "bw"
+	}
+
+	return &BitwardenCli{binary: binary}
+}
+
+// Uses an existing session key, such as one exported in BW_SESSION, instead of unlocking the vault.
+func (client *BitwardenCli) UseSession(session string) {
+	client.session = session
+}
+
+// Gets the status of the vault, which is unauthenticated, locked or unlocked.
+func (client *BitwardenCli) Status() (string, error) {
+	output, err := client.run("status")
+	if err != nil {
+		return "", err
+	}
+
+	var status struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(output, &status); err != nil {
+		return "", fmt.Errorf("parsing bw status: %w", err)
+	}
+
+	return status.Status, nil
+}
+
+// Unlocks the vault with the master password if it is locked, keeping the session key for later commands.
+func (client *BitwardenCli) Unlock(password string) error {
+	status, err := client.Status()
+	if err != nil {
+		return err
+	}
+
+	switch status {
+	case "unlocked":
+		return nil
+	case "unauthenticated":
+		return UnauthenticatedErr
+	}
+
+	cmd := client.command("unlock", "--passwordenv", passwordEnv, "--raw")
+	cmd.Env = append(cmd.Env, passwordEnv+"="+password)
+	session, err := runCmd(cmd)
+	if err != nil {
+		return err
+	}
+
+	client.session = strings.TrimSpace(string(session))
+	return nil
+}
+
+// Pulls the latest changes from the server so we don't read a stale local copy of the vault.
+func (client *BitwardenCli) Sync() error {
+	_, err := client.run("sync")
+	return err
+}
+
+func (client *BitwardenCli) ListFolders() ([]secrets.Folder, error) {
+	var bwFolders []bwFolder
+	if err := client.runJson(&bwFolders, "list", "folders"); err != nil {
+		return nil, err
+	}
+
+	folders := make([]secrets.Folder, 0, len(bwFolders))
+	for _, folder := range bwFolders {
+		// items without a folder are listed as a folder without an id
+		if folder.Id == nil {
+			continue
+		}
+		folders = append(folders, secrets.Folder{ID: *folder.Id, Name: folder.Name})
+	}
+
+	return folders, nil
+}
+
+// Finds the folder by its name. Nested folders are named with their full path, such as Team/Dev.
+func (client *BitwardenCli) GetFolder(name string) (secrets.Folder, error) {
+	folders, err := client.ListFolders()
+	if err != nil {
+		return secrets.Folder{}, err
+	}
+
+	for _, folder := range folders {
+		if folder.Name == name {
+			return folder, nil
+		}
+	}
+
+	return secrets.Folder{}, InvalidFolderErr
+}
+
+func (client *BitwardenCli) ListSecrets(folder secrets.Folder) ([]secrets.SecretRef, error) {
+	items, err := client.loginItems(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]secrets.SecretRef, 0, len(items))
+	for _, item := range items {
+		refs = append(refs, secrets.SecretRef{ID: item.Id, Key: item.Name})
+	}
+
+	return refs, nil
+}
+
+func (client *BitwardenCli) GetSecrets(folder secrets.Folder) ([]secrets.SecretData, error) {
+	items, err := client.loginItems(folder)
+	if err != nil {
+		return []secrets.SecretData{}, err
+	}
+
+	secretData := make([]secrets.SecretData, 0, len(items))
+	for _, item := range items {
+		value := ""
+		if item.Login.Password != nil {
+			value = *item.Login.Password
+		}
+		secretData = append(secretData, secrets.SecretData{Key: item.Name, Value: value})
+	}
+
+	return secretData, nil
+}
+
+// Creates a login item in the folder with the secrets key as its name and the value as its password.
+func (client *BitwardenCli) CreateSecret(folder secrets.Folder, secret secrets.SecretData) error {
+	item := map[string]any{
+		"organizationId": nil,
+		"collectionIds":  nil,
+		"folderId":       folder.ID,
+		"type":           loginItemType,
+		"name":           secret.Key,
+		"notes":          nil,
+		"favorite":       false,
+		"fields":         []any{},
+		"login": map[string]any{
+			"uris":     []any{},
+			"username": nil,
+			"password": secret.Value,
+			"totp":     nil,
+		},
+		"reprompt": 0,
+	}
+
+	encoded, err := encodeItem(item)
+	if err != nil {
+		return err
+	}
+	_, err = client.runInput(encoded, "create", "item")
+
+	return err
+}
+
+// Changes the password of the item, keeping every other field as it is.
+func (client *BitwardenCli) UpdateSecret(folder secrets.Folder, ref secrets.SecretRef, secret secrets.SecretData) error {
+	var item map[string]any
+	if err := client.runJson(&item, "get", "item", ref.ID); err != nil {
+		return err
+	}
+
+	login, ok := item["login"].(map[string]any)
+	if !ok {
+		return fmt.Errorf("%s is not a login item", ref.Key)
+	}
+	login["password"] = secret.Value
+
+	encoded, err := encodeItem(item)
+	if err != nil {
+		return err
+	}
+	_, err = client.runInput(encoded, "edit", "item", ref.ID)
+
+	return err
+}
+
+// Moves the item to the trash.
+func (client *BitwardenCli) DeleteSecret(folder secrets.Folder, ref secrets.SecretRef) error {
+	_, err := client.run("delete", "item", ref.ID)
+	return err
+}
+
+func (client *BitwardenCli) loginItems(folder secrets.Folder) ([]bwItem, error) {
+	var items []bwItem
+	if err := client.runJson(&items, "list", "items", "--folderid", folder.ID); err != nil {
+		return nil, err
+	}
+
+	logins := make([]bwItem, 0, len(items))
+	for _, item := range items {
+		if item.Type == loginItemType && item.Login != nil {
+			logins = append(logins, item)
+		}
+	}
+
+	return logins, nil
+}
+
+// bw expects the json for create and edit to be base64 encoded, which is what bw encode does.
+func encodeItem(item map[string]any) (string, error) {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+func (client *BitwardenCli) command(args ...string) *exec.Cmd {
+	cmd := exec.Command(client.binary, append(args, "--nointeraction")...)
+	cmd.Env = os.Environ()
+	if client.session != "" {
+		cmd.Env = append(cmd.Env, sessionEnv+"="+client.session)
+	}
+
+	return cmd
+}
+
+func (client *BitwardenCli) run(args ...string) ([]byte, error) {
+	return runCmd(client.command(args...))
+}
+
+// Runs bw with the input on stdin. bw reads the encoded item from stdin when it isn't an argument,
+// which keeps the secret in it out of the process list.
+func (client *BitwardenCli) runInput(input string, args ...string) ([]byte, error) {
+	cmd := client.command(args...)
+	cmd.Stdin = strings.NewReader(input)
+
+	return runCmd(cmd)
+}
+
+func (client *BitwardenCli) runJson(value any, args ...string) error {
+	output, err := client.run(args...)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(output, value); err != nil {
+		return fmt.Errorf("parsing bw %s output: %w", strings.Join(args[:2], " "), err)
+	}
+
+	return nil
+}
+
+func runCmd(cmd *exec.Cmd) ([]byte, error) {
+	var stdOut bytes.Buffer
+	var errOut bytes.Buffer
+	cmd.Stdout = &stdOut
+	cmd.Stderr = &errOut
+
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(errOut.String())
+		if message == "" {
+			message = strings.TrimSpace(stdOut.String())
+		}
+		return nil, fmt.Errorf("bw %s error: %s: %w", cmd.Args[1], message, err)
+	}
+
+	return stdOut.Bytes(), nil
+}
@@ -0,0 +1,282 @@
+package bitwarden_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chadsmith12/dotsec/bitwarden"
+	"github.com/chadsmith12/dotsec/secrets"
+)
+
+const (
+	fakeBwEnv      = "DOTSEC_FAKE_BW_STATE"
+	masterPassword = "master"
+	sessionKey     = "session-key"
+)
+
+// The vault the fake bw binary reads and writes between runs.
+type fakeVault struct {
+	Authenticated bool             `json:"authenticated"`
+	Folders       []map[string]any `json:"folders"`
+	Items         []map[string]any `json:"items"`
+}
+
+// When the test binary is started with the fake state variable it acts as the bw CLI instead of running the tests.
+func TestMain(m *testing.M) {
+	if statePath := os.Getenv(fakeBwEnv); statePath != "" {
+		os.Exit(fakeBw(statePath, os.Args[1:]))
+	}
+
+	os.Exit(m.Run())
+}
+
+func fakeBw(statePath string, args []string) int {
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var vault fakeVault
+	json.Unmarshal(data, &vault)
+
+	if args[len(args)-1] != "--nointeraction" {
+		fmt.Fprintln(os.Stderr, "expected --nointeraction")
+		return 1
+	}
+	args = args[:len(args)-1]
+	unlocked := os.Getenv("BW_SESSION") == sessionKey
+
+	switch args[0] {
+	case "status":
+		status := "locked"
+		if !vault.Authenticated {
+			status = "unauthenticated"
+		} else if unlocked {
+			status = "unlocked"
+		}
+		return writeJson(map[string]any{"status": status})
+	case "unlock":
+		if os.Getenv("BW_PASSWORD") != masterPassword {
+			fmt.Fprintln(os.Stderr, "Invalid master password.")
+			return 1
+		}
+		fmt.Print(sessionKey)
+		return 0
+	}
+
+	if !unlocked {
+		fmt.Fprintln(os.Stderr, "Vault is locked.")
+		return 1
+	}
+
+	switch strings.Join(args[:min(2, len(args))], " ") {
+	case "sync":
+		return 0
+	case "list folders":
+		return writeJson(append(vault.Folders, map[string]any{"object": "folder", "id": nil, "name": "No Folder"}))
+	case "list items":
+		items := make([]map[string]any, 0)
+		for _, item := range vault.Items {
+			if item["folderId"] == args[3] {
+				items = append(items, item)
+			}
+		}
+		return writeJson(items)
+	case "get item":
+		for _, item := range vault.Items {
+			if item["id"] == args[2] {
+				return writeJson(item)
+			}
+		}
+	case "create item", "edit item":
+		if len(args) != map[string]int{"create": 2, "edit": 3}[args[0]] {
+			fmt.Fprintln(os.Stderr, "expected the encoded item on stdin")
+			return 1
+		}
+		encoded, _ := io.ReadAll(os.Stdin)
+		decoded, err := base64.StdEncoding.DecodeString(string(encoded))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error parsing the encoded request data.")
+			return 1
+		}
+		var item map[string]any
+		json.Unmarshal(decoded, &item)
+		if args[0] == "create" {
+			item["id"] = fmt.Sprintf("item-%d", len(vault.Items)+1)
+			vault.Items = append(vault.Items, item)
+		} else {
+			for i := range vault.Items {
+				if vault.Items[i]["id"] == args[2] {
+					vault.Items[i] = item
+				}
+			}
+		}
+		return saveVault(statePath, vault)
+	case "delete item":
+		for i, item := range vault.Items {
+			if item["id"] == args[2] {
+				vault.Items = append(vault.Items[:i], vault.Items[i+1:]...)
+				return saveVault(statePath, vault)
+			}
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, "Not found.")
+	return 1
+}
+
+func writeJson(value any) int {
+	json.NewEncoder(os.Stdout).Encode(value)
+	return 0
+}
+
+func saveVault(statePath string, vault fakeVault) int {
+	data, _ := json.Marshal(vault)
+	if err := os.WriteFile(statePath, data, 0600); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+func loginItem(id, folderId, name, password string) map[string]any {
+	return map[string]any{
+		"id":       id,
+		"folderId": folderId,
+		"type":     1,
+		"name":     name,
+		"notes":    "kept on update",
+		"login":    map[string]any{"username": "api", "password": password},
+	}
+}
+
+// Writes the vault for the fake bw binary and returns a client that runs the test binary as bw.
+func newFakeClient(t *testing.T, vault fakeVault) (*bitwarden.BitwardenCli, string) {
+	t.Helper()
+	statePath := filepath.Join(t.TempDir(), "vault.json")
+	data, _ := json.Marshal(vault)
+	if err := os.WriteFile(statePath, data, 0600); err != nil {
+		t.Fatalf("Failed to write fake vault: %v", err)
+	}
+	t.Setenv(fakeBwEnv, statePath)
+	t.Setenv("BW_SESSION", "")
+
+	return bitwarden.NewClient(os.Args[0]), statePath
+}
+
+func readVault(t *testing.T, statePath string) fakeVault {
+	t.Helper()
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatalf("Failed to read fake vault: %v", err)
+	}
+	var vault fakeVault
+	json.Unmarshal(data, &vault)
+
+	return vault
+}
+
+func testVault() fakeVault {
+	return fakeVault{
+		Authenticated: true,
+		Folders:       []map[string]any{{"object": "folder", "id": "folder-1", "name": "myapp/dev"}},
+		Items: []map[string]any{
+			loginItem("item-1", "folder-1", "API_KEY", "secret123"),
+			loginItem("item-2", "folder-2", "OTHER", "value"),
+			{"id": "item-3", "folderId": "folder-1", "type": 2, "name": "A secure note"},
+		},
+	}
+}
+
+func TestGetSecrets_ReadsLoginItemsInFolder(t *testing.T) {
+	client, _ := newFakeClient(t, testVault())
+	if err := client.Unlock(masterPassword); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if err := client.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	actual, err := secrets.GetSecretsByFolder(client, "myapp/dev")
+	if err != nil {
+		t.Fatalf("GetSecretsByFolder failed: %v", err)
+	}
+
+	expected := []secrets.SecretData{{Key: "API_KEY", Value: "secret123"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("GetSecretsByFolder() = %v, expected %v", actual, expected)
+	}
+}
+
+func TestUnlock(t *testing.T) {
+	client, _ := newFakeClient(t, testVault())
+	if err := client.Unlock("wrong"); err == nil {
+		t.Error("Unlock should fail with the wrong master password")
+	}
+
+	client, _ = newFakeClient(t, fakeVault{})
+	if err := client.Unlock(masterPassword); !errors.Is(err, bitwarden.UnauthenticatedErr) {
+		t.Errorf("Unlock() error = %v, expected %v", err, bitwarden.UnauthenticatedErr)
+	}
+}
+
+func TestUseSession_SkipsUnlock(t *testing.T) {
+	client, _ := newFakeClient(t, testVault())
+	client.UseSession(sessionKey)
+
+	if err := client.Unlock(""); err != nil {
+		t.Fatalf("Unlock should not need the password with a valid session: %v", err)
+	}
+	if _, err := client.GetFolder("missing"); !errors.Is(err, bitwarden.InvalidFolderErr) {
+		t.Errorf("GetFolder() error = %v, expected %v", err, bitwarden.InvalidFolderErr)
+	}
+}
+
+func TestPush_CreatesUpdatesAndDeletesItems(t *testing.T) {
+	client, statePath := newFakeClient(t, testVault())
+	client.UseSession(sessionKey)
+
+	folder, err := client.GetFolder("myapp/dev")
+	if err != nil {
+		t.Fatalf("GetFolder failed: %v", err)
+	}
+	refs, err := client.ListSecrets(folder)
+	if err != nil {
+		t.Fatalf("ListSecrets failed: %v", err)
+	}
+	apiKey, _ := secrets.FindSecretRef(refs, "API_KEY")
+
+	if err := client.UpdateSecret(folder, apiKey, secrets.SecretData{Key: "API_KEY", Value: "rotated"}); err != nil {
+		t.Fatalf("UpdateSecret failed: %v", err)
+	}
+	if err := client.CreateSecret(folder, secrets.SecretData{Key: "DB_PASSWORD", Value: "hunter2"}); err != nil {
+		t.Fatalf("CreateSecret failed: %v", err)
+	}
+
+	vault := readVault(t, statePath)
+	if vault.Items[0]["notes"] != "kept on update" || vault.Items[0]["login"].(map[string]any)["password"] != "rotated" {
+		t.Errorf("updated item = %v, expected only the password to change", vault.Items[0])
+	}
+	created := vault.Items[len(vault.Items)-1]
+	if created["name"] != "DB_PASSWORD" || created["folderId"] != "folder-1" || created["login"].(map[string]any)["password"] != "hunter2" {
+		t.Errorf("created item = %v", created)
+	}
+
+	if err := client.DeleteSecret(folder, apiKey); err != nil {
+		t.Fatalf("DeleteSecret failed: %v", err)
+	}
+	actual, _ := client.GetSecrets(folder)
+	expected := []secrets.SecretData{{Key: "DB_PASSWORD", Value: "hunter2"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("GetSecrets() = %v, expected %v", actual, expected)
+	}
+}
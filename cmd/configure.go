@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
+	"github.com/chadsmith12/dotsec/config"
 	"github.com/chadsmith12/dotsec/input"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -25,6 +27,43 @@ func init() {
 }
 
 func configureRun(cmd *cobra.Command, args []string) {
+	provider, err := input.PromptUser(fmt.Sprintf("Provider [%s]: ", config.DefaultProvider), false)
+	if err != nil {
+		log.Fatalf("Error getting provider: %v", err)
+	}
+	provider = strings.TrimSpace(provider)
+	if provider == "" {
+		provider = config.DefaultProvider
+	}
+	viper.Set("provider", provider)
+
+	switch provider {
+	case "passbolt":
+		configurePassbolt()
+	case "keepass":
+		configureKeepass()
+	case "vault":
+		configureVault()
+	case "pass", "gopass":
+		configurePass()
+	case "sops":
+		configureSops()
+	case "bitwarden", "vaultwarden", "bw":
+		configureBitwarden()
+	case "1password", "onepassword", "op":
+		configureOnePassword()
+	case "secretsmanager", "aws-secretsmanager", "ssm", "aws-ssm":
+		configureAws()
+	case "azure", "keyvault", "azure-keyvault":
+		configureAzure()
+	case "kubernetes", "k8s":
+		configureKubernetes()
+	}
+
+	saveConfigFile()
+}
+
+func configurePassbolt() {
 	server, err := input.PromptUser("Server (https://passbolt.example.com): ", false)
 	if err != nil {
 		log.Fatalf("Error getting server: %v", err)
@@ -44,8 +83,198 @@ func configureRun(cmd *cobra.Command, args []string) {
 	viper.Set("server", server)
 	viper.Set("privateKey", privateKey)
 	viper.Set("password", password)
+}
 
-	saveConfigFile()
+func configureKeepass() {
+	database, err := input.PromptUser("Path to KeePass Database (.kdbx): ", false)
+	if err != nil {
+		log.Fatalf("Error getting path to the database: %v", err)
+	}
+
+	keyFile, err := input.PromptUser("Path to Key File (leave blank if none): ", false)
+	if err != nil {
+		log.Fatalf("Error getting path to the key file: %v", err)
+	}
+
+	password, err := input.PromptUser("Master Password (leave blank to ask each time): ", true)
+	if err != nil {
+		log.Fatalf("Error getting master password: %v", err)
+	}
+
+	fmt.Println("")
+	viper.Set("keepassDatabase", database)
+	viper.Set("keepassKeyFile", keyFile)
+	viper.Set("password", password)
+}
+
+func configureVault() {
+	address, err := input.PromptUser("Vault Address (https://vault.example.com:8200): ", false)
+	if err != nil {
+		log.Fatalf("Error getting vault address: %v", err)
+	}
+
+	mount, err := input.PromptUser("KV v2 Mount [secret]: ", false)
+	if err != nil {
+		log.Fatalf("Error getting kv mount: %v", err)
+	}
+
+	roleId, err := input.PromptUser("AppRole Role ID (leave blank to use a token): ", false)
+	if err != nil {
+		log.Fatalf("Error getting approle role id: %v", err)
+	}
+
+	viper.Set("vaultAddress", address)
+	viper.Set("vaultMount", strings.TrimSpace(mount))
+	viper.Set("vaultRoleId", roleId)
+	if roleId != "" {
+		secretId, err := input.PromptUser("AppRole Secret ID: ", true)
+		if err != nil {
+			log.Fatalf("Error getting approle secret id: %v", err)
+		}
+		viper.Set("vaultSecretId", secretId)
+	} else {
+		token, err := input.PromptUser("Vault Token (leave blank to use VAULT_TOKEN): ", true)
+		if err != nil {
+			log.Fatalf("Error getting vault token: %v", err)
+		}
+		viper.Set("vaultToken", token)
+	}
+	fmt.Println("")
+}
+
+func configurePass() {
+	store, err := input.PromptUser("Password Store Directory (leave blank for ~/.password-store): ", false)
+	if err != nil {
+		log.Fatalf("Error getting password store directory: %v", err)
+	}
+
+	privateKey, err := input.PromptUser("Path to GPG Private Key: ", false)
+	if err != nil {
+		log.Fatalf("Error getting path to the private key: %v", err)
+	}
+
+	password, err := input.PromptUser("Private Key Passphrase (leave blank to ask each time): ", true)
+	if err != nil {
+		log.Fatalf("Error getting private key passphrase: %v", err)
+	}
+
+	fmt.Println("")
+	viper.Set("passStore", store)
+	viper.Set("privateKey", privateKey)
+	viper.Set("password", password)
+}
+
+func configureSops() {
+	keyFile, err := input.PromptUser("Path to age Key File (leave blank for SOPS_AGE_KEY_FILE or the sops default): ", false)
+	if err != nil {
+		log.Fatalf("Error getting path to the age key file: %v", err)
+	}
+
+	privateKey, err := input.PromptUser("Path to GPG Private Key (leave blank if only using age): ", false)
+	if err != nil {
+		log.Fatalf("Error getting path to the private key: %v", err)
+	}
+
+	viper.Set("sopsAgeKeyFile", keyFile)
+	viper.Set("privateKey", privateKey)
+	if privateKey != "" {
+		password, err := input.PromptUser("Private Key Passphrase (leave blank to ask each time): ", true)
+		if err != nil {
+			log.Fatalf("Error getting private key passphrase: %v", err)
+		}
+		viper.Set("password", password)
+		fmt.Println("")
+	}
+}
+
+func configureBitwarden() {
+	bwPath, err := input.PromptUser("Path to bw CLI (leave blank to use bw from your PATH): ", false)
+	if err != nil {
+		log.Fatalf("Error getting path to the bw cli: %v", err)
+	}
+
+	password, err := input.PromptUser("Master Password (leave blank to ask each time): ", true)
+	if err != nil {
+		log.Fatalf("Error getting master password: %v", err)
+	}
+
+	fmt.Println("")
+	viper.Set("bwPath", bwPath)
+	viper.Set("password", password)
+}
+
+func configureOnePassword() {
+	opPath, err := input.PromptUser("Path to op CLI (leave blank to use op from your PATH): ", false)
+	if err != nil {
+		log.Fatalf("Error getting path to the op cli: %v", err)
+	}
+
+	viper.Set("opPath", opPath)
+}
+
+func configureAws() {
+	region, err := input.PromptUser("AWS Region (leave blank to use AWS_REGION or your profile): ", false)
+	if err != nil {
+		log.Fatalf("Error getting aws region: %v", err)
+	}
+
+	profile, err := input.PromptUser("AWS Profile (leave blank to use AWS_PROFILE or default): ", false)
+	if err != nil {
+		log.Fatalf("Error getting aws profile: %v", err)
+	}
+
+	endpoint, err := input.PromptUser("Endpoint URL (leave blank for AWS, e.g. http://localhost:4566 for LocalStack): ", false)
+	if err != nil {
+		log.Fatalf("Error getting endpoint url: %v", err)
+	}
+
+	viper.Set("awsRegion", region)
+	viper.Set("awsProfile", profile)
+	viper.Set("awsEndpoint", endpoint)
+}
+
+func configureAzure() {
+	tenantId, err := input.PromptUser("Tenant ID (leave blank to use the Azure CLI login): ", false)
+	if err != nil {
+		log.Fatalf("Error getting tenant id: %v", err)
+	}
+
+	viper.Set("azureTenantId", tenantId)
+	if tenantId != "" {
+		clientId, err := input.PromptUser("Client ID: ", false)
+		if err != nil {
+			log.Fatalf("Error getting client id: %v", err)
+		}
+
+		clientSecret, err := input.PromptUser("Client Secret: ", true)
+		if err != nil {
+			log.Fatalf("Error getting client secret: %v", err)
+		}
+		fmt.Println("")
+		viper.Set("azureClientId", clientId)
+		viper.Set("azureClientSecret", clientSecret)
+	}
+
+	endpoint, err := input.PromptUser("Key Vault Endpoint (leave blank for Azure, set for a local emulator): ", false)
+	if err != nil {
+		log.Fatalf("Error getting key vault endpoint: %v", err)
+	}
+	viper.Set("azureEndpoint", endpoint)
+}
+
+func configureKubernetes() {
+	kubeconfig, err := input.PromptUser("Path to kubeconfig (leave blank for KUBECONFIG or ~/.kube/config): ", false)
+	if err != nil {
+		log.Fatalf("Error getting path to the kubeconfig: %v", err)
+	}
+
+	kubeContext, err := input.PromptUser("Context (leave blank for the current context): ", false)
+	if err != nil {
+		log.Fatalf("Error getting kubeconfig context: %v", err)
+	}
+
+	viper.Set("kubeconfig", kubeconfig)
+	viper.Set("kubeContext", kubeContext)
 }
 
 func saveConfigFile() {
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/chadsmith12/dotsec/cmdcontext"
+	"github.com/chadsmith12/dotsec/colors"
+	"github.com/chadsmith12/dotsec/config"
+	"github.com/chadsmith12/dotsec/secrets"
+	"github.com/spf13/cobra"
+)
+
+const maskedValue = "********"
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [foldername]",
+	Short: "Shows what would change in your secret manager if you pushed",
+	Long: `Compares the secrets in the folder with your local secrets and lists the keys that were added, removed or changed locally.
+		Values are masked unless --show-values is set. Use --output json to get the changes as json for scripts.
+		With --recursive the subfolders of the folder are compared too, with their keys prefixed the same as pull --recursive.`,
+	Example: "dotsec diff FolderName --project ./api --show-values",
+	Run:     diffRun,
+}
+
+type targetDiff struct {
+	Folder  string           `json:"folder"`
+	Path    string           `json:"path"`
+	Changes []secrets.Change `json:"changes"`
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().StringP("project", "p", "", "The path to the dotnet project or solution to compare. Default to the current directory. Only valid with --type dotnet.")
+	diffCmd.Flags().StringP("file", "f", ".env", "The env file you want to compare. Default to .env in the current directory. Only valid with --type env.")
+	diffCmd.Flags().String("type", "", "The type of secrets file you want to use. dotnet to use dotnet user-secrets or env to use a .env file.")
+	diffCmd.Flags().Bool("show-values", false, "Show the secret values instead of masking them.")
+	diffCmd.Flags().StringP("output", "o", "text", "The output format, text or json.")
+	diffCmd.Flags().Bool("recursive", false, "Compare the subfolders of the folder too, prefixing their keys with the subfolder name the same as pull --recursive.")
+}
+
+func diffRun(cmd *cobra.Command, args []string) {
+	folderName := ""
+	if len(args) > 0 {
+		folderName = args[0]
+	}
+	showValues, _ := cmd.Flags().GetBool("show-values")
+	output, _ := cmd.Flags().GetString("output")
+	recursive, _ := cmd.Flags().GetBool("recursive")
+	if output != "text" && output != "json" {
+		fmt.Fprintf(os.Stderr, "Unsupported output format: %s\n", output)
+		os.Exit(1)
+	}
+
+	projectConfig, err := config.LoadProjectConfig(cmd, folderName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Config error: %v\n", err)
+		os.Exit(1)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cmdContext, err := cmdcontext.NewCommandContext(cmd, projectConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create command context: %v\n", err)
+		os.Exit(1)
+	}
+
+	store, err := cmdContext.SecretStore(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to get %s client: %v\n", cmdContext.Provider(), err)
+		os.Exit(1)
+	}
+
+	targets, err := cmdContext.Targets()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to find projects: %v\n", err)
+		os.Exit(1)
+	}
+
+	diffs := make([]targetDiff, 0, len(targets))
+	for _, target := range targets {
+		changes, err := diffTarget(cmdContext, store, target, recursive)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		diffs = append(diffs, targetDiff{Folder: target.Folder, Path: target.Path, Changes: changes})
+	}
+
+	if output == "json" {
+		printDiffJson(os.Stdout, diffs, showValues)
+		return
+	}
+	for _, diff := range diffs {
+		printDiff(os.Stdout, diff, showValues, len(diffs) > 1)
+	}
+}
+
+// Compares the secrets in the targets folder, and its subfolders when recursive is set, with the local secrets,
+// leaving out the keys that are the same.
+func diffTarget(cmdContext *cmdcontext.CommandContext, store secrets.SecretStore, target cmdcontext.Target, recursive bool) ([]secrets.Change, error) {
+	remote, err := remoteSecrets(cmdContext, store, target.Folder, recursive)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve folder: %s - %w", target.Folder, err)
+	}
+
+	fetcher, err := cmdContext.SecretsFetcher(target.Path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get secrets fetcher: %w", err)
+	}
+	local, err := fetcher.FetchSecrets()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("Error - Fetching Secrets: %w", err)
+	}
+
+	changes := make([]secrets.Change, 0)
+	for _, change := range secrets.Diff(remote, local) {
+		if change.Kind != secrets.Unchanged {
+			changes = append(changes, change)
+		}
+	}
+
+	return changes, nil
+}
+
+func printDiff(out io.Writer, diff targetDiff, showValues, withHeader bool) {
+	if withHeader {
+		fmt.Fprintln(out, colors.Cyan(fmt.Sprintf("%s <- %s", diff.Folder, diff.Path)))
+	}
+	if len(diff.Changes) == 0 {
+		fmt.Fprintln(out, "No changes")
+		return
+	}
+
+	for _, change := range diff.Changes {
+		oldValue, newValue := change.Old, change.New
+		if !showValues {
+			oldValue, newValue = maskedValue, maskedValue
+		}
+		switch change.Kind {
+		case secrets.Added:
+			fmt.Fprintln(out, colors.Green(fmt.Sprintf("+ %s = %s", change.Key, newValue)))
+		case secrets.Removed:
+			fmt.Fprintln(out, colors.Red(fmt.Sprintf("- %s = %s", change.Key, oldValue)))
+		case secrets.Changed:
+			fmt.Fprintln(out, colors.Yellow(fmt.Sprintf("~ %s = %s -> %s", change.Key, oldValue, newValue)))
+		}
+	}
+}
+
+// Writes the diffs as json. The values are left out of a copy of the changes unless showValues is set.
+func printDiffJson(out io.Writer, diffs []targetDiff, showValues bool) {
+	if !showValues {
+		masked := make([]targetDiff, 0, len(diffs))
+		for _, diff := range diffs {
+			changes := make([]secrets.Change, 0, len(diff.Changes))
+			for _, change := range diff.Changes {
+				change.Old, change.New = "", ""
+				changes = append(changes, change)
+			}
+			masked = append(masked, targetDiff{Folder: diff.Folder, Path: diff.Path, Changes: changes})
+		}
+		diffs = masked
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(diffs)
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chadsmith12/dotsec/cmdcontext"
+	"github.com/chadsmith12/dotsec/config"
+	"github.com/chadsmith12/dotsec/secrets"
+)
+
+// treeStore keeps secrets in nested folders, using the slash separated path of a folder as its ID.
+type treeStore struct {
+	folders map[string]map[string]string
+}
+
+func (store *treeStore) ListFolders() ([]secrets.Folder, error) {
+	folders := []secrets.Folder{}
+	for id := range store.folders {
+		folders = append(folders, secrets.Folder{ID: id, Name: path.Base(id)})
+	}
+	return folders, nil
+}
+
+func (store *treeStore) GetFolder(name string) (secrets.Folder, error) {
+	if _, found := store.folders[name]; !found {
+		return secrets.Folder{}, secrets.ErrFolderNotFound
+	}
+	return secrets.Folder{ID: name, Name: path.Base(name)}, nil
+}
+
+func (store *treeStore) ListSubfolders(folder secrets.Folder) ([]secrets.Folder, error) {
+	subfolders := []secrets.Folder{}
+	for id := range store.folders {
+		if path.Dir(id) == folder.ID {
+			subfolders = append(subfolders, secrets.Folder{ID: id, Name: path.Base(id)})
+		}
+	}
+	return subfolders, nil
+}
+
+func (store *treeStore) CreateSubfolder(parent secrets.Folder, name string) (secrets.Folder, error) {
+	id := parent.ID + "/" + name
+	store.folders[id] = map[string]string{}
+	return secrets.Folder{ID: id, Name: name}, nil
+}
+
+func (store *treeStore) ListSecrets(folder secrets.Folder) ([]secrets.SecretRef, error) {
+	refs := []secrets.SecretRef{}
+	for key := range store.folders[folder.ID] {
+		refs = append(refs, secrets.SecretRef{ID: key, Key: key})
+	}
+	return refs, nil
+}
+
+func (store *treeStore) GetSecrets(folder secrets.Folder) ([]secrets.SecretData, error) {
+	secretsData := []secrets.SecretData{}
+	for key, value := range store.folders[folder.ID] {
+		secretsData = append(secretsData, secrets.SecretData{Key: key, Value: value})
+	}
+	return secretsData, nil
+}
+
+func (store *treeStore) CreateSecret(folder secrets.Folder, secret secrets.SecretData) error {
+	store.folders[folder.ID][secret.Key] = secret.Value
+	return nil
+}
+
+func (store *treeStore) UpdateSecret(folder secrets.Folder, ref secrets.SecretRef, secret secrets.SecretData) error {
+	store.folders[folder.ID][ref.Key] = secret.Value
+	return nil
+}
+
+func (store *treeStore) DeleteSecret(folder secrets.Folder, ref secrets.SecretRef) error {
+	delete(store.folders[folder.ID], ref.Key)
+	return nil
+}
+
+func newDiffContext(t *testing.T, envFile string) *cmdcontext.CommandContext {
+	t.Helper()
+	cmdContext, err := cmdcontext.NewCommandContext(diffCmd, &config.ProjectConfig{Provider: "memory", Type: "env", Folder: "app", Path: envFile})
+	if err != nil {
+		t.Fatalf("NewCommandContext failed: %v", err)
+	}
+
+	return cmdContext
+}
+
+func TestDiff_MasksValuesUnlessShown(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), ".env")
+	os.WriteFile(envFile, []byte("API_KEY=local-key\nNEW_KEY=new-value\n"), 0600)
+	store := &memoryStore{values: map[string]string{"API_KEY": "remote-key", "OLD_KEY": "old-value"}}
+
+	changes, err := diffTarget(newDiffContext(t, envFile), store, cmdcontext.Target{Folder: "app", Path: envFile}, false)
+	if err != nil {
+		t.Fatalf("diffTarget failed: %v", err)
+	}
+	diffs := []targetDiff{{Folder: "app", Path: envFile, Changes: changes}}
+	values := []string{"local-key", "remote-key", "new-value", "old-value"}
+
+	for _, format := range []string{"text", "json"} {
+		for _, showValues := range []bool{false, true} {
+			var out bytes.Buffer
+			if format == "json" {
+				printDiffJson(&out, diffs, showValues)
+			} else {
+				printDiff(&out, diffs[0], showValues, false)
+			}
+
+			for _, key := range []string{"API_KEY", "NEW_KEY", "OLD_KEY"} {
+				if !strings.Contains(out.String(), key) {
+					t.Errorf("%s output = %s, expected it to list %s", format, out.String(), key)
+				}
+			}
+			for _, value := range values {
+				if strings.Contains(out.String(), value) != showValues {
+					t.Errorf("%s output with show values %t = %s, expected %s to be shown only with --show-values", format, showValues, out.String(), value)
+				}
+			}
+		}
+	}
+}
+
+func TestDiff_Recursive(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), ".env")
+	os.WriteFile(envFile, []byte("API_KEY=abc\nDATABASE_PASSWORD=local\n"), 0600)
+	store := &treeStore{folders: map[string]map[string]string{
+		"app":          {"API_KEY": "abc"},
+		"app/Database": {"Password": "remote"},
+	}}
+	target := cmdcontext.Target{Folder: "app", Path: envFile}
+
+	changes, err := diffTarget(newDiffContext(t, envFile), store, target, true)
+	if err != nil {
+		t.Fatalf("diffTarget failed: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Key != "DATABASE_PASSWORD" || changes[0].Kind != secrets.Changed {
+		t.Errorf("diffTarget() = %v, expected DATABASE_PASSWORD from the Database subfolder to be changed", changes)
+	}
+
+	changes, err = diffTarget(newDiffContext(t, envFile), store, target, false)
+	if err != nil {
+		t.Fatalf("diffTarget failed: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Key != "DATABASE_PASSWORD" || changes[0].Kind != secrets.Added {
+		t.Errorf("diffTarget() = %v, expected DATABASE_PASSWORD to be only local without --recursive", changes)
+	}
+}
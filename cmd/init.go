@@ -9,6 +9,7 @@ import (
 	"github.com/chadsmith12/dotsec/config"
 	"github.com/chadsmith12/dotsec/input"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var initCmd = &cobra.Command{
@@ -25,9 +26,22 @@ func init() {
 }
 
 func initRun(cmd *cobra.Command, args []string) {
+	defaultProvider := viper.GetString("provider")
+	if defaultProvider == "" {
+		defaultProvider = config.DefaultProvider
+	}
+	val, err := input.PromptUser(colors.Yellow(fmt.Sprintf("Provider [%s]: ", defaultProvider)), false)
+	if err != nil {
+		log.Fatalf("Error reading input: %v", err)
+	}
+	provider := strings.TrimSpace(val)
+	if provider == "" {
+		provider = defaultProvider
+	}
+
 	var folder string
 	for folder == "" {
-		val, err := input.PromptUser(colors.Yellow("Passbolt Folder Name: "), false)
+		val, err := input.PromptUser(colors.Yellow("Folder Name: "), false)
 		if err != nil {
 			log.Fatalf("Error reading input: %v", err)
 		}
@@ -57,7 +71,7 @@ func initRun(cmd *cobra.Command, args []string) {
 	if secretType == "dotnet" {
 		defaultPath = "."
 	}
-	val, err := input.PromptUser(colors.Yellow(fmt.Sprintf("Path [%s]: ", defaultPath)), false)
+	val, err = input.PromptUser(colors.Yellow(fmt.Sprintf("Path [%s]: ", defaultPath)), false)
 	if err != nil {
 		log.Fatalf("Error reading input: %v", err)
 	}
@@ -66,14 +80,14 @@ func initRun(cmd *cobra.Command, args []string) {
 		path = defaultPath
 	}
 
-	fmt.Printf(colors.Cyan("\nConfiguration:\n Folder: %s\n Type: %s\n Path: %s\n\n"), folder, secretType, path)
+	fmt.Printf(colors.Cyan("\nConfiguration:\n Provider: %s\n Folder: %s\n Type: %s\n Path: %s\n\n"), provider, folder, secretType, path)
 	confirm, _ := input.PromptUser(colors.Yellow("Save to .dotsecrc? [Y/n]: "), false)
 	if strings.ToLower(strings.TrimSpace(confirm)) == "n" {
 		fmt.Println("Cancelled")
 		return
 	}
 
-	if err := config.WriteProjectConfigWithData(folder, secretType, path); err != nil {
+	if err := config.WriteProjectConfigWithData(provider, folder, secretType, path); err != nil {
 		log.Fatalf("Error saving config: %v", err)
 	}
 
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/chadsmith12/dotsec/colors"
+	"github.com/chadsmith12/dotsec/secrets"
+	"github.com/chadsmith12/dotsec/state"
+	"golang.org/x/term"
+)
+
+// What a sync does with a single key after comparing both sides with the baseline from the last sync.
+const (
+	mergeCreate    = "create"
+	mergeUpdate    = "update"
+	mergeUnchanged = "unchanged"
+	// the destination changed since the last sync and the source didn't, so the destination is newer
+	mergeSkip = "skip"
+	// both sides changed since the last sync
+	mergeConflict = "conflict"
+	// the conflict was resolved by keeping the destination
+	mergeKeep = "keep"
+)
+
+type mergedSecret struct {
+	secret      secrets.SecretData
+	destination string
+	action      string
+}
+
+// Merges the secrets synced from the source into the destination. Keys without a baseline yet are
+// overwritten the same as before dotsec kept one, so the first sync of a project behaves like a plain copy.
+func mergeSecrets(source, destination []secrets.SecretData, syncState *state.SyncState) []mergedSecret {
+	destinationValues := make(map[string]string, len(destination))
+	for _, secret := range destination {
+		destinationValues[secret.Key] = secret.Value
+	}
+
+	merged := make([]mergedSecret, 0, len(source))
+	for _, secret := range source {
+		value, found := destinationValues[secret.Key]
+		action := mergeCreate
+		if found {
+			switch syncState.Compare(secret.Key, secret.Value, value) {
+			case state.Unchanged:
+				action = mergeUnchanged
+			case state.DestinationChanged:
+				action = mergeSkip
+			case state.BothChanged:
+				action = mergeConflict
+			default:
+				action = mergeUpdate
+			}
+		}
+		merged = append(merged, mergedSecret{secret: secret, destination: value, action: action})
+	}
+
+	return merged
+}
+
+// Resolves the conflicts by overwriting the destination with force, or by asking when running in a terminal.
+// Conflicts that aren't resolved are returned as SecretErrors. pushing tells which side is local when asking.
+func resolveConflicts(merged []mergedSecret, folder string, pushing bool, options syncOptions) secrets.SecretErrors {
+	interactive := term.IsTerminal(int(os.Stdin.Fd())) && !options.dryRun
+	var conflicts secrets.SecretErrors
+	for i := range merged {
+		if merged[i].action != mergeConflict {
+			continue
+		}
+		if options.force {
+			merged[i].action = mergeUpdate
+			continue
+		}
+		if !interactive {
+			conflicts = append(conflicts, secrets.SecretError{Key: merged[i].secret.Key, Operation: mergeConflict, Err: fmt.Errorf("changed locally and in %s since the last sync", folder)})
+			continue
+		}
+
+		merged[i].action = askConflict(merged[i].secret.Key, folder, pushing, options)
+	}
+
+	return conflicts
+}
+
+// Asks which side of a conflict to keep. An empty answer skips the key, leaving both sides as they are.
+func askConflict(key, folder string, pushing bool, options syncOptions) string {
+	for {
+		answer, err := options.promptUser(colors.Yellow(fmt.Sprintf("%s was changed locally and in %s since the last sync. Keep [l]ocal, [r]emote or [s]kip? [s]: ", key, folder)))
+		if err != nil {
+			return mergeSkip
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "l", "local":
+			if pushing {
+				return mergeUpdate
+			}
+			return mergeKeep
+		case "r", "remote":
+			if pushing {
+				return mergeKeep
+			}
+			return mergeUpdate
+		case "", "s", "skip":
+			return mergeSkip
+		}
+	}
+}
+
+// Prints the keys that aren't synced because they only changed at the destination since the last sync.
+func printSkipped(merged []mergedSecret, destination string) {
+	for _, secret := range merged {
+		if secret.action == mergeSkip {
+			fmt.Printf("Skipping %s - it changed in %s since the last sync\n", secret.secret.Key, destination)
+		}
+	}
+}
+
+// Plans the operations of the merge for --dry-run. stale are the keys prune would remove.
+func planMerge(merged []mergedSecret, stale []string) []plannedOperation {
+	plan := make([]plannedOperation, 0, len(merged)+len(stale))
+	for _, secret := range merged {
+		plan = append(plan, plannedOperation{operation: secret.action, key: secret.secret.Key})
+	}
+	for _, key := range stale {
+		plan = append(plan, plannedOperation{operation: "prune", key: key})
+	}
+
+	return plan
+}
+
+// Gets the secrets that need to be written to the destination.
+func secretsToWrite(merged []mergedSecret) []secrets.SecretData {
+	toWrite := make([]secrets.SecretData, 0, len(merged))
+	for _, secret := range merged {
+		if secret.action == mergeCreate || secret.action == mergeUpdate {
+			toWrite = append(toWrite, secret.secret)
+		}
+	}
+
+	return toWrite
+}
+
+// Records the baseline of every key that is the same on both sides after the sync. A conflict resolved by keeping
+// the destination records the source value instead, so the next sync in the other direction overwrites the source.
+func updateBaselines(syncState *state.SyncState, merged []mergedSecret, failures secrets.SecretErrors) {
+	for _, secret := range merged {
+		if failures.Has(secret.secret.Key) {
+			continue
+		}
+		switch secret.action {
+		case mergeCreate, mergeUpdate, mergeUnchanged, mergeKeep:
+			syncState.SetBaseline(secret.secret.Key, secret.secret.Value)
+		}
+	}
+}
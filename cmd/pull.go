@@ -2,27 +2,33 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
-	"time"
+	"slices"
+	"strings"
 
 	"github.com/chadsmith12/dotsec/cmdcontext"
+	"github.com/chadsmith12/dotsec/colors"
 	"github.com/chadsmith12/dotsec/config"
+	"github.com/chadsmith12/dotsec/secrets"
+	"github.com/chadsmith12/dotsec/state"
 	"github.com/spf13/cobra"
 )
 
 // pullCmd represents the sync command
 var pullCmd = &cobra.Command{
 	Use:   "pull",
-	Short: "Pulls down the secrets for a folder from passbolt",
+	Short: "Pulls down the secrets for a folder from your secret manager",
 	Long: `Pulls down the secrets from the folder specified and saves them to your projects secrets file. There are two types: dotnet or env.
-		dotnet - Uses dotnet user-secrets to set the secrets in your dotnet projects secrets.json file.
+		dotnet - Sets the secrets in your dotnet projects user-secrets secrets.json file.
 		env - Saves the secrets to the .env file. 
 
 		If you do not specify the --project flag, then it will attempt to use your current working directory.
 		You can specify the project directory for the secrets to try to be set.
+		When --project is a solution, every project in it with user secrets is pulled from the folder mapped to it in .dotsecrc.
 	
-		When using dotnet user-secrets your project will first be initialized to work with user-secrets.
+		When using dotnet user-secrets a UserSecretsId will be added to your project if it does not have one.
 		When using env a file will be created and/or replaced with the secrets downloaded.
 
 		Example: dotsec pull "SecretsFolder" --project ./projects/testProject/
@@ -32,10 +38,15 @@ var pullCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(pullCmd)
-	pullCmd.Flags().StringP("project", "p", "", "The path to the dotnet project to sync the secrets to. Default to the current directory. Only valid with --type dotnet.")
+	pullCmd.Flags().StringP("project", "p", "", "The path to the dotnet project or solution to sync the secrets to. Default to the current directory. Only valid with --type dotnet.")
 	pullCmd.Flags().StringP("file", "f", ".env", "The env file you want to save the secrets to. Default to .env in the current directory. Only valid with --type env.")
 	pullCmd.Flags().String("type", "", "The type of secrets file you want to use. dotnet to use dotnet user-secrets or env to use a .env file.")
-
+	pullCmd.Flags().Bool("continue-on-error", false, "Save the secrets that could be read when some of them fail, instead of saving nothing.")
+	pullCmd.Flags().Bool("prune", false, "Remove the keys dotsec saved before that are no longer in the folder. Keys you added yourself are never removed.")
+	pullCmd.Flags().BoolP("yes", "y", false, "Don't ask before removing the keys removed by --prune.")
+	pullCmd.Flags().Bool("dry-run", false, "Show what would be created, updated, left unchanged or pruned locally without saving anything.")
+	pullCmd.Flags().Bool("recursive", false, "Pull the subfolders of the folder too, prefixing their keys with the subfolder name such as Database:ConnectionString for dotnet or DATABASE_CONNECTIONSTRING for env.")
+	pullCmd.Flags().Bool("force", false, "Overwrite the local secrets that changed both locally and in the folder since the last sync, instead of stopping at the conflict.")
 }
 
 func pullRun(cmd *cobra.Command, args []string) {
@@ -48,35 +59,194 @@ func pullRun(cmd *cobra.Command, args []string) {
 		fmt.Fprintf(os.Stderr, "Config error: %v\n", err)
 		os.Exit(1)
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	deadline := withPromptDeadline(context.Background(), syncTimeout)
+	defer deadline.Stop()
 	cmdContext, err := cmdcontext.NewCommandContext(cmd, projectConfig)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to create command context: %v\n", err)
 		os.Exit(1)
 	}
 
-	client, err := cmdContext.UserClient(ctx)
+	store, err := cmdContext.SecretStore(deadline)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to get Passbolt client: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Failed to get %s client: %v\n", cmdContext.Provider(), err)
 		os.Exit(1)
 	}
 
-	secrets, err := client.GetSecretsByFolder(projectConfig.Folder)
+	targets, err := cmdContext.Targets()
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Failed to retrieve folder: ", err)
+		fmt.Fprintf(os.Stderr, "Failed to find projects: %v\n", err)
 		os.Exit(1)
 	}
 
-	setter, err := cmdContext.SecretsSetter()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to get secrets setter: %v\n", err)
+	options := syncOptionsFromFlags(cmd)
+	report := &failureReport{}
+	for _, target := range targets {
+		if target.Project != "" {
+			fmt.Printf("Pulling %s into %s\n", target.Folder, target.Project)
+		}
+		if err := pullTarget(cmdContext, store, target, options); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			report.add(target.Folder, err)
+		}
+	}
+	if report.failed() {
+		report.print(os.Stderr)
+		if !options.continueOnError {
+			fmt.Fprintln(os.Stderr, "Nothing was saved for the folders that failed. Use --continue-on-error to save the secrets that could be read.")
+		}
 		os.Exit(1)
 	}
+}
 
-	err = setter.SetSecrets(secrets)
+// Pulls the secrets in the targets folder into its project or env file. When continueOnError is set and some secrets
+// fail to read, the rest are still saved and the failures are returned as SecretErrors.
+// With prune, keys that dotsec saved in an earlier pull or push and are no longer in the folder are removed.
+// Keys that changed locally since the last sync are left alone, and keys that changed on both sides are conflicts.
+func pullTarget(cmdContext *cmdcontext.CommandContext, store secrets.SecretStore, target cmdcontext.Target, options syncOptions) error {
+	secretsData, err := remoteSecrets(cmdContext, store, target.Folder, options.recursive)
+	var failures secrets.SecretErrors
+	if err != nil && !(options.continueOnError && errors.As(err, &failures)) {
+		return fmt.Errorf("Failed to retrieve folder: %s - %w", target.Folder, err)
+	}
+
+	fetcher, err := cmdContext.SecretsFetcher(target.Path)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to set secrets: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("Failed to get secrets fetcher: %w", err)
+	}
+	local, err := fetcher.FetchSecrets()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("Error - Fetching Secrets: %w", err)
+	}
+
+	syncState, err := state.Load(target.Path, target.Folder)
+	if err != nil {
+		return fmt.Errorf("Failed to load sync state: %w", err)
+	}
+	merged := mergeSecrets(secretsData, local, syncState)
+	conflicts := resolveConflicts(merged, target.Folder, false, options)
+
+	if options.dryRun {
+		stale := []string{}
+		if options.prune {
+			stale = staleLocalKeys(syncState, remoteKeys(secretsData, failures))
+		}
+		printPlan(os.Stdout, fmt.Sprintf("pulling %s into %s", target.Folder, displayPath(target.Path)), planMerge(merged, stale))
+		return append(failures, conflicts...).Err()
 	}
+	if len(conflicts) > 0 && !options.continueOnError {
+		return conflicts
+	}
+
+	setter, err := cmdContext.SecretsSetter(target.Path)
+	if err != nil {
+		return fmt.Errorf("Failed to get secrets setter: %w", err)
+	}
+
+	printSkipped(merged, "the local secrets")
+	if err := setter.SetSecrets(secretsToWrite(merged)); err != nil {
+		var setFailures secrets.SecretErrors
+		if !errors.As(err, &setFailures) {
+			return fmt.Errorf("Failed to set secrets: %w", err)
+		}
+		failures = append(failures, setFailures...)
+	}
+
+	keys := remoteKeys(secretsData, failures)
+	pruned := false
+	if options.prune {
+		if pruned, err = pruneLocal(setter, syncState, target.Folder, keys, options); err != nil {
+			return err
+		}
+	}
+	failures = append(failures, conflicts...)
+	updateBaselines(syncState, merged, failures)
+	// the keys removed from the folder stay managed until a prune removes them, so a later pull --prune still can
+	if pruned {
+		syncState.SetKeys(keys)
+	} else {
+		syncState.AddKeys(keys)
+	}
+	if err := syncState.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to save sync state: %v\n", err)
+	}
+
+	return failures.Err()
+}
+
+// Reads the secrets in the folder, and in its subfolders with their keys prefixed when recursive is set.
+func remoteSecrets(cmdContext *cmdcontext.CommandContext, store secrets.SecretStore, folderName string, recursive bool) ([]secrets.SecretData, error) {
+	if !recursive {
+		return secrets.GetSecretsByFolder(store, folderName)
+	}
+
+	folder, err := store.GetFolder(folderName)
+	if err != nil {
+		return []secrets.SecretData{}, err
+	}
+	tree, err := secrets.ReadFolderTree(store, folder, cmdContext.KeyFormat(), true)
+	if tree == nil {
+		return []secrets.SecretData{}, err
+	}
+
+	return tree.Secrets, err
+}
+
+// The keys in the folder, including the secrets that failed to read since they are still in the folder.
+func remoteKeys(secretsData []secrets.SecretData, failures secrets.SecretErrors) []string {
+	keys := make([]string, 0, len(secretsData)+len(failures))
+	for _, secret := range secretsData {
+		keys = append(keys, secret.Key)
+	}
+	for _, failure := range failures {
+		keys = append(keys, failure.Key)
+	}
+
+	return keys
+}
+
+// Finds the keys dotsec is managing in the local file that aren't in remoteKeys any more.
+func staleLocalKeys(syncState *state.SyncState, remoteKeys []string) []string {
+	stale := []string{}
+	for _, key := range syncState.Keys {
+		if !slices.Contains(remoteKeys, key) {
+			stale = append(stale, key)
+		}
+	}
+
+	return stale
+}
+
+// Removes the keys dotsec is managing in the local file that aren't in remoteKeys any more.
+// Returns false when the user declined, leaving the stale keys in the local file.
+func pruneLocal(setter secrets.SecretsSetter, syncState *state.SyncState, folder string, remoteKeys []string, options syncOptions) (bool, error) {
+	remover, ok := setter.(secrets.SecretsRemover)
+	if !ok {
+		return false, errors.New("Failed to prune - removing secrets is not supported for this secrets type")
+	}
+	stale := staleLocalKeys(syncState, remoteKeys)
+	if len(stale) == 0 {
+		return true, nil
+	}
+
+	if !options.confirmed {
+		fmt.Printf("These keys were removed from %s and will be removed locally:\n", folder)
+		for _, key := range stale {
+			fmt.Printf("  - %s\n", key)
+		}
+		answer, err := options.promptUser(colors.Yellow(fmt.Sprintf("Remove %d keys? [y/N]: ", len(stale))))
+		if err != nil || !strings.EqualFold(strings.TrimSpace(answer), "y") {
+			fmt.Println("Skipping prune")
+			return false, nil
+		}
+	}
+
+	if err := remover.RemoveSecrets(stale); err != nil {
+		return false, fmt.Errorf("Failed to prune - %w", err)
+	}
+	for _, key := range stale {
+		fmt.Printf("Removed %s\n", key)
+	}
+
+	return true, nil
 }
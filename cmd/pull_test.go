@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chadsmith12/dotsec/cmdcontext"
+	"github.com/chadsmith12/dotsec/config"
+	"github.com/chadsmith12/dotsec/secrets"
+	"github.com/chadsmith12/dotsec/state"
+)
+
+// memoryStore is a SecretStore with a single folder, keeping its secrets in memory.
+type memoryStore struct {
+	values map[string]string
+}
+
+func (store *memoryStore) ListFolders() ([]secrets.Folder, error) {
+	return []secrets.Folder{{ID: "app", Name: "app"}}, nil
+}
+
+func (store *memoryStore) GetFolder(name string) (secrets.Folder, error) {
+	return secrets.Folder{ID: name, Name: name}, nil
+}
+
+func (store *memoryStore) ListSecrets(folder secrets.Folder) ([]secrets.SecretRef, error) {
+	refs := []secrets.SecretRef{}
+	for key := range store.values {
+		refs = append(refs, secrets.SecretRef{ID: key, Key: key})
+	}
+	return refs, nil
+}
+
+func (store *memoryStore) GetSecrets(folder secrets.Folder) ([]secrets.SecretData, error) {
+	secretsData := []secrets.SecretData{}
+	for key, value := range store.values {
+		secretsData = append(secretsData, secrets.SecretData{Key: key, Value: value})
+	}
+	return secretsData, nil
+}
+
+func (store *memoryStore) CreateSecret(folder secrets.Folder, secret secrets.SecretData) error {
+	store.values[secret.Key] = secret.Value
+	return nil
+}
+
+func (store *memoryStore) UpdateSecret(folder secrets.Folder, ref secrets.SecretRef, secret secrets.SecretData) error {
+	store.values[ref.Key] = secret.Value
+	return nil
+}
+
+func (store *memoryStore) DeleteSecret(folder secrets.Folder, ref secrets.SecretRef) error {
+	delete(store.values, ref.Key)
+	return nil
+}
+
+func TestPullTarget_PruneAfterPullWithoutPrune(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	t.Setenv("APPDATA", configDir)
+	envFile := filepath.Join(t.TempDir(), ".env")
+
+	cmdContext, err := cmdcontext.NewCommandContext(pullCmd, &config.ProjectConfig{Provider: "memory", Type: "env", Folder: "app", Path: envFile})
+	if err != nil {
+		t.Fatalf("NewCommandContext failed: %v", err)
+	}
+	store := &memoryStore{values: map[string]string{"API_KEY": "abc", "OLD_KEY": "old"}}
+	target := cmdcontext.Target{Folder: "app", Path: envFile}
+
+	if err := pullTarget(cmdContext, store, target, syncOptions{}); err != nil {
+		t.Fatalf("pull failed: %v", err)
+	}
+	delete(store.values, "OLD_KEY")
+	if err := pullTarget(cmdContext, store, target, syncOptions{}); err != nil {
+		t.Fatalf("pull failed: %v", err)
+	}
+	if data, _ := os.ReadFile(envFile); !strings.Contains(string(data), "OLD_KEY") {
+		t.Fatalf(".env = %s, expected a pull without --prune to keep OLD_KEY", data)
+	}
+
+	if err := pullTarget(cmdContext, store, target, syncOptions{prune: true, confirmed: true}); err != nil {
+		t.Fatalf("pull --prune failed: %v", err)
+	}
+	data, _ := os.ReadFile(envFile)
+	if strings.Contains(string(data), "OLD_KEY") || !strings.Contains(string(data), "API_KEY") {
+		t.Errorf(".env = %s, expected pull --prune to remove OLD_KEY and keep API_KEY", data)
+	}
+}
+
+func TestSync_DryRunWritesNothing(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	t.Setenv("APPDATA", configDir)
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".env")
+	localSecrets := "API_KEY=local\nNEW_KEY=new\n"
+	os.WriteFile(envFile, []byte(localSecrets), 0600)
+
+	projectConfig := &config.ProjectConfig{Provider: "memory", Type: "env", Folder: "app", Path: envFile}
+	store := &memoryStore{values: map[string]string{"API_KEY": "remote", "OLD_KEY": "old"}}
+	options := syncOptions{dryRun: true, prune: true, confirmed: true}
+
+	pushContext, _ := cmdcontext.NewCommandContext(pushCmd, projectConfig)
+	if err := pushTarget(pushContext, store, cmdcontext.Target{Folder: "app", Path: envFile}, options); err != nil {
+		t.Fatalf("push --dry-run failed: %v", err)
+	}
+	expected := map[string]string{"API_KEY": "remote", "OLD_KEY": "old"}
+	if !reflect.DeepEqual(store.values, expected) {
+		t.Errorf("store = %v after push --dry-run, expected it to be unchanged", store.values)
+	}
+
+	pullContext, _ := cmdcontext.NewCommandContext(pullCmd, projectConfig)
+	if err := pullTarget(pullContext, store, cmdcontext.Target{Folder: "app", Path: envFile}, options); err != nil {
+		t.Fatalf("pull --dry-run failed: %v", err)
+	}
+	if data, _ := os.ReadFile(envFile); string(data) != localSecrets {
+		t.Errorf(".env = %q after pull --dry-run, expected it to be unchanged", data)
+	}
+	missingFile := filepath.Join(dir, "missing.env")
+	if err := pullTarget(pullContext, store, cmdcontext.Target{Folder: "app", Path: missingFile}, options); err != nil {
+		t.Fatalf("pull --dry-run failed: %v", err)
+	}
+	if _, err := os.Stat(missingFile); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("pull --dry-run created %s, expected nothing to be written", missingFile)
+	}
+
+	syncState, err := state.Load(envFile, "app")
+	if err != nil || len(syncState.Keys) != 0 {
+		t.Errorf("sync state keys = %v, %v, expected a dry run not to save the sync state", syncState.Keys, err)
+	}
+}
+
+// Answers the prompts of the test with the lines in answers, the way a user would type them.
+func answerPrompts(t *testing.T, answers string) {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create stdin: %v", err)
+	}
+	writer.WriteString(answers)
+	writer.Close()
+
+	stdin := os.Stdin
+	os.Stdin = reader
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		reader.Close()
+	})
+}
+
+func TestPushTarget_PruneDeletesOnlyConfirmedKeys(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	t.Setenv("APPDATA", configDir)
+	envFile := filepath.Join(t.TempDir(), ".env")
+	os.WriteFile(envFile, []byte("API_KEY=abc\nOLD_KEY=old\n"), 0600)
+
+	cmdContext, err := cmdcontext.NewCommandContext(pushCmd, &config.ProjectConfig{Provider: "memory", Type: "env", Folder: "app", Path: envFile})
+	if err != nil {
+		t.Fatalf("NewCommandContext failed: %v", err)
+	}
+	store := &memoryStore{values: map[string]string{}}
+	target := cmdcontext.Target{Folder: "app", Path: envFile}
+	if err := pushTarget(cmdContext, store, target, syncOptions{}); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+
+	store.values["REMOTE_ONLY"] = "added by a teammate"
+	os.WriteFile(envFile, []byte("API_KEY=abc\n"), 0600)
+
+	answerPrompts(t, "n\n")
+	if err := pushTarget(cmdContext, store, target, syncOptions{prune: true}); err != nil {
+		t.Fatalf("push --prune failed: %v", err)
+	}
+	expected := map[string]string{"API_KEY": "abc", "OLD_KEY": "old", "REMOTE_ONLY": "added by a teammate"}
+	if !reflect.DeepEqual(store.values, expected) {
+		t.Errorf("store = %v after declining the prune, expected nothing to be deleted", store.values)
+	}
+
+	answerPrompts(t, "y\n")
+	if err := pushTarget(cmdContext, store, target, syncOptions{prune: true}); err != nil {
+		t.Fatalf("push --prune failed: %v", err)
+	}
+	expected = map[string]string{"API_KEY": "abc", "REMOTE_ONLY": "added by a teammate"}
+	if !reflect.DeepEqual(store.values, expected) {
+		t.Errorf("store = %v after confirming the prune, expected only OLD_KEY to be deleted", store.values)
+	}
+}
@@ -2,15 +2,16 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
-	"time"
+	"strings"
 
 	"github.com/chadsmith12/dotsec/cmdcontext"
+	"github.com/chadsmith12/dotsec/colors"
 	"github.com/chadsmith12/dotsec/config"
-	"github.com/chadsmith12/dotsec/passbolt"
 	"github.com/chadsmith12/dotsec/secrets"
-	"github.com/passbolt/go-passbolt/api"
+	"github.com/chadsmith12/dotsec/state"
 	"github.com/spf13/cobra"
 )
 
@@ -19,20 +20,28 @@ var pushCmd = &cobra.Command{
 	Use:   "push foldername",
 	Short: "Pushes alll the secrets into your file to the secret manager",
 	Long: `Pushes the secrets from the folder specified and saves them to your secret manager folder. There are two types: dotnet or env.
-		dotnet - Uses dotnet user-secrets to set the secrets in your dotnet projects secrets.json file.
+		dotnet - Reads the secrets from your dotnet projects user-secrets secrets.json file.
 		env - Saves the secrets to the .env file.
 
 		If you do not specify the --project flag, then it will attempt to use your current working directory.
-		You can specify the project directory for the secrets to try to be read `,
+		You can specify the project directory for the secrets to try to be read.
+		When --project is a solution, every project in it with user secrets is pushed to the folder mapped to it in .dotsecrc.`,
 	Example: "dotsec push FolderName --project ./api",
 	Run:     pushRun,
 }
 
 func init() {
 	rootCmd.AddCommand(pushCmd)
-	pushCmd.Flags().StringP("project", "p", "", "The path to the dotnet project to sync the secrets to. Default to the current directory. Only valid with --type dotnet.")
+	pushCmd.Flags().StringP("project", "p", "", "The path to the dotnet project or solution to sync the secrets from. Default to the current directory. Only valid with --type dotnet.")
 	pushCmd.Flags().StringP("file", "f", ".env", "The env file you want to save the secrets to. Default to .env in the current directory. Only valid with --type env.")
 	pushCmd.Flags().String("type", "", "The type of secrets file you want to use. dotnet to use dotnet user-secrets or env to use a .env file.")
+	pushCmd.Flags().Bool("continue-on-error", false, "Keep pushing the rest of the secrets when one of them fails, instead of stopping.")
+	pushCmd.Flags().Bool("prune", false, "Delete the secrets in the folder that no longer exist locally. Lists them and asks before deleting.")
+	pushCmd.Flags().BoolP("yes", "y", false, "Don't ask before deleting the secrets removed by --prune.")
+	pushCmd.Flags().Bool("dry-run", false, "Show what would be created, updated, left unchanged or pruned in the folder without changing anything.")
+	pushCmd.Flags().Bool("recursive", false, "Push keys prefixed with a subfolder name, such as Database:ConnectionString, to that subfolder of the folder. Subfolders are created as needed for dotnet keys.")
+	pushCmd.Flags().Bool("create-folder", false, "Create the folder when it doesn't exist, inside of its parent for a path such as Team/Service/Dev, and share it with the shareGroups in your config.")
+	pushCmd.Flags().Bool("force", false, "Overwrite the secrets in the folder that changed both locally and in the folder since the last sync, instead of stopping at the conflict.")
 }
 
 func pushRun(cmd *cobra.Command, args []string) {
@@ -45,8 +54,8 @@ func pushRun(cmd *cobra.Command, args []string) {
 		fmt.Fprintf(os.Stderr, "Config error: %v\n", err)
 		os.Exit(1)
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	deadline := withPromptDeadline(context.Background(), syncTimeout)
+	defer deadline.Stop()
 
 	cmdCtx, err := cmdcontext.NewCommandContext(cmd, projectConfig)
 	if err != nil {
@@ -54,53 +63,277 @@ func pushRun(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	client, err := cmdCtx.UserClient(ctx)
+	store, err := cmdCtx.SecretStore(deadline)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to get Passbolt client: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Failed to get %s client: %v\n", cmdCtx.Provider(), err)
 		os.Exit(1)
 	}
-	folder, err := client.GetFolderWithResources(projectConfig.Folder)
+
+	targets, err := cmdCtx.Targets()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error - Using folder: %s - %v\n", folderName, err)
+		fmt.Fprintf(os.Stderr, "Failed to find projects: %v\n", err)
 		os.Exit(1)
 	}
 
-	fetcher, err := cmdCtx.SecretsFetcher()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to get secrets fetcher: %v\n", err)
+	options := syncOptionsFromFlags(cmd)
+	options.deadline = deadline
+	report := &failureReport{}
+	for _, target := range targets {
+		if target.Project != "" {
+			fmt.Printf("Pushing %s to %s\n", target.Project, target.Folder)
+		}
+		if err := pushTarget(cmdCtx, store, target, options); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			report.add(target.Folder, err)
+		}
+	}
+	if report.failed() {
+		report.print(os.Stderr)
+		if !options.continueOnError {
+			fmt.Fprintln(os.Stderr, "Pushing stopped at the first secret that failed in each folder. Use --continue-on-error to push the rest.")
+		}
 		os.Exit(1)
 	}
+}
+
+// Pushes the secrets from the targets project or env file to its folder. With prune, the secrets in the folder
+// that aren't in the local secrets are deleted after asking, or without asking when confirmed is set.
+// Keys that changed in the folder since the last sync are left alone, and keys that changed on both sides are conflicts.
+func pushTarget(cmdCtx *cmdcontext.CommandContext, store secrets.SecretStore, target cmdcontext.Target, options syncOptions) error {
+	folder, err := store.GetFolder(target.Folder)
+	missing := options.createFolder && errors.Is(err, secrets.ErrFolderNotFound)
+	if errors.Is(err, secrets.ErrFolderNotFound) && !missing {
+		return fmt.Errorf("Error - Using folder: %s - %w. Use --create-folder to create it", target.Folder, err)
+	}
+	if err != nil && !missing {
+		return fmt.Errorf("Error - Using folder: %s - %w", target.Folder, err)
+	}
+
+	fetcher, err := cmdCtx.SecretsFetcher(target.Path)
+	if err != nil {
+		return fmt.Errorf("Failed to get secrets fetcher: %w", err)
+	}
 
 	secretsData, err := fetcher.FetchSecrets()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error - Fetching Secrets: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("Error - Fetching Secrets: %w", err)
+	}
+
+	shareGroups := cmdCtx.ShareGroups()
+	if options.createFolder && (missing || options.recursive) {
+		// every folder the push creates is shared, so check it can be before creating any
+		if err := checkSharing(store, shareGroups); err != nil {
+			return fmt.Errorf("Failed to create folder: %s - %w", target.Folder, err)
+		}
+	}
+	if missing && options.dryRun {
+		plan := planMerge(mergeSecrets(secretsData, nil, &state.SyncState{}), nil)
+		printPlan(os.Stdout, fmt.Sprintf("pushing %s to %s, which will be created", displayPath(target.Path), target.Folder), plan)
+		return nil
 	}
-	pushSecrets(secretsData, client, folder)
+	if missing {
+		if folder, err = createFolder(store, target.Folder, shareGroups); err != nil {
+			return fmt.Errorf("Failed to create folder: %s - %w", target.Folder, err)
+		}
+	}
+
+	tree, err := secrets.ReadFolderTree(store, folder, cmdCtx.KeyFormat(), options.recursive)
+	var readFailures secrets.SecretErrors
+	if err != nil && !(options.continueOnError && errors.As(err, &readFailures)) {
+		return fmt.Errorf("Error - Reading Secrets in folder: %s - %w", folder.Name, err)
+	}
+	if options.createFolder {
+		tree.FolderCreated = func(subfolder secrets.Folder, path []string) {
+			shareFolder(store, subfolder, target.Folder+"/"+strings.Join(path, "/"), shareGroups)
+		}
+	}
+
+	syncState, err := state.Load(target.Path, target.Folder)
+	if err != nil {
+		return fmt.Errorf("Failed to load sync state: %w", err)
+	}
+
+	// the secrets that couldn't be read can't be compared, so they aren't pushed either
+	merged := mergeSecrets(withoutFailed(secretsData, readFailures), tree.Secrets, syncState)
+	conflicts := resolveConflicts(merged, folder.Name, true, options)
+
+	stale := []string{}
+	if options.prune && len(secretsData) == 0 {
+		// an empty or missing secrets file is much more likely to be a mistake than a request to empty the folder
+		fmt.Fprintf(os.Stderr, "Skipping prune - there are no local secrets to push to %s\n", folder.Name)
+	} else if options.prune {
+		var added []string
+		stale, added = splitStaleKeys(tree.StaleKeys(secretsData), syncState)
+		printRemoteAdditions(added, folder.Name)
+	}
+
+	if options.dryRun {
+		printPlan(os.Stdout, fmt.Sprintf("pushing %s to %s", displayPath(target.Path), folder.Name), planMerge(merged, stale))
+		return append(readFailures, conflicts...).Err()
+	}
+	if len(conflicts) > 0 && !options.continueOnError {
+		return conflicts
+	}
+
+	if len(stale) > 0 && !options.confirmed && !confirmPrune(folder, stale, options) {
+		fmt.Println("Skipping prune")
+		stale = []string{}
+	}
+
+	printSkipped(merged, folder.Name)
+	failures := pushSecrets(secretsToWrite(merged), store, tree, options.continueOnError)
+	if len(failures) > 0 && !options.continueOnError {
+		return failures
+	}
+	failures = append(failures, pruneSecrets(store, tree, stale, options.continueOnError)...)
+	if err := secrets.Flush(store); err != nil {
+		return fmt.Errorf("Failed to save secrets in folder: %s - %w", folder.Name, err)
+	}
+	failures = append(append(readFailures, conflicts...), failures...)
+
+	// the pushed keys are in the folder now, so a later pull --prune can remove them if they are deleted from it
+	updateBaselines(syncState, merged, failures)
+	syncState.AddKeys(pushedKeys(secretsData, failures))
+	if err := syncState.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to save sync state: %v\n", err)
+	}
+
+	return failures.Err()
 }
 
-func pushSecrets(secretsData []secrets.SecretData, client *passbolt.PassboltApi, folder api.Folder) {
-	for _, value := range secretsData {
-		if id, ok := containsSecret(folder, value.Key); ok {
-			err := client.UpdateSecret(id, value)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error Updating Secret %s - %v", value.Key, err)
-			}
+// Creates the folder, inside of its parent when it is a path such as Team/Service/Dev, and shares it with the groups.
+// The folder isn't created when it can't be shared, and a failure to share it is only reported so the push can go on.
+func createFolder(store secrets.SecretStore, path string, groups []string) (secrets.Folder, error) {
+	if err := checkSharing(store, groups); err != nil {
+		return secrets.Folder{}, err
+	}
+
+	folder, err := secrets.CreateFolder(store, path)
+	if err != nil {
+		return secrets.Folder{}, err
+	}
+	fmt.Printf("Created folder %s\n", path)
+	shareFolder(store, folder, path, groups)
+
+	return folder, nil
+}
+
+// Returns ErrShareFolderNotSupported when there are groups to share new folders with and the store can't share them.
+func checkSharing(store secrets.SecretStore, groups []string) error {
+	if _, ok := store.(secrets.FolderSharer); len(groups) > 0 && !ok {
+		return secrets.ErrShareFolderNotSupported
+	}
+
+	return nil
+}
+
+// Shares the new folder with the groups. A failure is printed instead of returned, the folder is already created
+// and the secrets can still be pushed to it.
+func shareFolder(store secrets.SecretStore, folder secrets.Folder, path string, groups []string) {
+	if len(groups) == 0 {
+		return
+	}
+
+	if err := store.(secrets.FolderSharer).ShareFolder(folder, groups); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to share %s with %s: %v. Share it before the team uses it\n", path, strings.Join(groups, ", "), err)
+		return
+	}
+	fmt.Printf("Shared %s with %s\n", path, strings.Join(groups, ", "))
+}
+
+func pushedKeys(secretsData []secrets.SecretData, failures secrets.SecretErrors) []string {
+	keys := make([]string, 0, len(secretsData))
+	for _, secret := range secretsData {
+		if !failures.Has(secret.Key) {
+			keys = append(keys, secret.Key)
+		}
+	}
+
+	return keys
+}
+
+// Leaves out the secrets that failed.
+func withoutFailed(secretsData []secrets.SecretData, failures secrets.SecretErrors) []secrets.SecretData {
+	if len(failures) == 0 {
+		return secretsData
+	}
+
+	kept := make([]secrets.SecretData, 0, len(secretsData))
+	for _, secret := range secretsData {
+		if !failures.Has(secret.Key) {
+			kept = append(kept, secret)
+		}
+	}
+
+	return kept
+}
+
+// Splits the keys in the folder that aren't local into the ones that were synced before and have been removed locally
+// since, which prune deletes, and the ones that were added to the folder since the last sync, which are left alone.
+func splitStaleKeys(keys []string, syncState *state.SyncState) ([]string, []string) {
+	stale, added := []string{}, []string{}
+	for _, key := range keys {
+		if syncState.Synced(key) {
+			stale = append(stale, key)
 		} else {
-			err := client.CreateSecretInFolder(folder.ID, value)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error Creating Secret %s -%v", value.Key, err)
+			added = append(added, key)
+		}
+	}
+
+	return stale, added
+}
+
+// Prints the keys prune leaves in the folder because they were added to it since the last sync.
+func printRemoteAdditions(added []string, folder string) {
+	for _, key := range added {
+		fmt.Printf("Keeping %s - it was added to %s since the last sync. Pull it, or delete it from %s\n", key, folder, folder)
+	}
+}
+
+func confirmPrune(folder secrets.Folder, stale []string, options syncOptions) bool {
+	fmt.Printf("These secrets in %s no longer exist locally and will be deleted:\n", folder.Name)
+	for _, key := range stale {
+		fmt.Printf("  - %s\n", key)
+	}
+	answer, err := options.promptUser(colors.Yellow(fmt.Sprintf("Delete %d secrets? [y/N]: ", len(stale))))
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(strings.TrimSpace(answer), "y")
+}
+
+// Deletes the secrets from the folder, stopping at the first one that fails unless continueOnError is set.
+func pruneSecrets(store secrets.SecretStore, tree *secrets.FolderTree, stale []string, continueOnError bool) secrets.SecretErrors {
+	var failures secrets.SecretErrors
+	for _, key := range stale {
+		if err := tree.DeleteSecret(store, key); err != nil {
+			failures = append(failures, secrets.SecretError{Key: key, Operation: "delete", Err: err})
+			if !continueOnError {
+				break
 			}
+			continue
 		}
+		fmt.Printf("Deleted %s\n", key)
 	}
+
+	return failures
 }
 
-func containsSecret(folder api.Folder, key string) (string, bool) {
-	for _, resource := range folder.ChildrenResources {
-		if resource.Name == key {
-			return resource.ID, true
+// Creates or updates every secret in the folder tree, stopping at the first one that fails unless continueOnError is set.
+func pushSecrets(secretsData []secrets.SecretData, store secrets.SecretStore, tree *secrets.FolderTree, continueOnError bool) secrets.SecretErrors {
+	var failures secrets.SecretErrors
+	for _, value := range secretsData {
+		operation, err := tree.WriteSecret(store, value)
+		if err == nil {
+			continue
+		}
+
+		failures = append(failures, secrets.SecretError{Key: value.Key, Operation: operation, Err: err})
+		if !continueOnError {
+			break
 		}
 	}
 
-	return "", false
+	return failures
 }
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chadsmith12/dotsec/cmdcontext"
+	"github.com/chadsmith12/dotsec/config"
+	"github.com/chadsmith12/dotsec/secrets"
+)
+
+// sharingStore creates top level folders and fails to share them when shareErr is set.
+type sharingStore struct {
+	memoryStore
+	created  []string
+	shared   []string
+	shareErr error
+}
+
+func (store *sharingStore) CreateFolder(name string) (secrets.Folder, error) {
+	store.created = append(store.created, name)
+	return secrets.Folder{ID: name, Name: name}, nil
+}
+
+func (store *sharingStore) ShareFolder(folder secrets.Folder, groups []string) error {
+	if store.shareErr != nil {
+		return store.shareErr
+	}
+	store.shared = append(store.shared, folder.Name)
+	return nil
+}
+
+// creatorStore creates folders but can't share them.
+type creatorStore struct {
+	memoryStore
+	created []string
+}
+
+func (store *creatorStore) CreateFolder(name string) (secrets.Folder, error) {
+	store.created = append(store.created, name)
+	return secrets.Folder{ID: name, Name: name}, nil
+}
+
+func TestCreateFolder_SharesWithGroups(t *testing.T) {
+	store := &sharingStore{}
+	folder, err := createFolder(store, "Service", []string{"Developers"})
+	if err != nil || folder.Name != "Service" {
+		t.Fatalf("createFolder() = %v, %v, expected the Service folder", folder, err)
+	}
+	if len(store.shared) != 1 || store.shared[0] != "Service" {
+		t.Errorf("shared = %v, expected Service", store.shared)
+	}
+}
+
+func TestCreateFolder_ShareFailureKeepsFolder(t *testing.T) {
+	store := &sharingStore{shareErr: errors.New("groups not found: Developers")}
+	folder, err := createFolder(store, "Service", []string{"Developers"})
+	if err != nil || folder.Name != "Service" {
+		t.Errorf("createFolder() = %v, %v, expected the created folder so the push can go on", folder, err)
+	}
+}
+
+func TestCreateFolder_ChecksSharingBeforeCreating(t *testing.T) {
+	store := &creatorStore{}
+	if _, err := createFolder(store, "Service", []string{"Developers"}); !errors.Is(err, secrets.ErrShareFolderNotSupported) {
+		t.Errorf("createFolder() error = %v, expected %v", err, secrets.ErrShareFolderNotSupported)
+	}
+	if len(store.created) != 0 {
+		t.Errorf("created = %v, expected nothing to be created when it can't be shared", store.created)
+	}
+
+	if _, err := createFolder(store, "Service", nil); err != nil || len(store.created) != 1 {
+		t.Errorf("createFolder() without groups = %v, created %v, expected the folder", err, store.created)
+	}
+}
+
+func TestPushTarget_PruneKeepsKeysAddedToFolder(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	t.Setenv("APPDATA", configDir)
+	envFile := filepath.Join(t.TempDir(), ".env")
+	os.WriteFile(envFile, []byte("API_KEY=abc\nOLD_KEY=old\n"), 0600)
+
+	cmdContext, err := cmdcontext.NewCommandContext(pushCmd, &config.ProjectConfig{Provider: "memory", Type: "env", Folder: "app", Path: envFile})
+	if err != nil {
+		t.Fatalf("NewCommandContext failed: %v", err)
+	}
+	store := &memoryStore{values: map[string]string{}}
+	target := cmdcontext.Target{Folder: "app", Path: envFile}
+	if err := pushTarget(cmdContext, store, target, syncOptions{}); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+
+	// OLD_KEY is removed locally while a teammate adds TEAM_KEY to the folder
+	os.WriteFile(envFile, []byte("API_KEY=abc\n"), 0600)
+	store.values["TEAM_KEY"] = "team"
+	if err := pushTarget(cmdContext, store, target, syncOptions{prune: true, confirmed: true}); err != nil {
+		t.Fatalf("push --prune failed: %v", err)
+	}
+
+	if _, found := store.values["OLD_KEY"]; found {
+		t.Errorf("values = %v, expected OLD_KEY to be pruned", store.values)
+	}
+	if store.values["TEAM_KEY"] != "team" || store.values["API_KEY"] != "abc" {
+		t.Errorf("values = %v, expected TEAM_KEY to be kept since it was added to the folder", store.values)
+	}
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/chadsmith12/dotsec/config"
+	"github.com/chadsmith12/dotsec/render"
+	"github.com/spf13/cobra"
+)
+
+var renderCmd = &cobra.Command{
+	Use:   "render template [foldername]",
+	Short: "Renders a config file template with the secrets in a folder",
+	Long: `Renders a Go text/template with the secrets in the folder, so any config format such as nginx configs, appsettings.json
+		or docker-compose overrides can be produced from your secret manager.
+		Use {{ secret "KEY" }} for any key, or {{ .KEY }} for keys that are valid template field names.
+		Rendering fails on a key that isn't in the folder, and nothing is written.
+
+		A new output file is created with permissions only you can read. Without --output it is printed instead.`,
+	Example: "dotsec render appsettings.Development.json.tmpl FolderName -o appsettings.Development.json",
+	Args:    cobra.RangeArgs(1, 2),
+	Run:     renderRun,
+}
+
+func init() {
+	rootCmd.AddCommand(renderCmd)
+	renderCmd.Flags().StringP("output", "o", "", "The file to write the rendered template to. Default to printing it.")
+}
+
+func renderRun(cmd *cobra.Command, args []string) {
+	templatePath := args[0]
+	folderName := ""
+	if len(args) > 1 {
+		folderName = args[1]
+	}
+	output, _ := cmd.Flags().GetString("output")
+
+	text, err := os.ReadFile(templatePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read template: %v\n", err)
+		os.Exit(1)
+	}
+
+	projectConfig, err := config.LoadProjectConfig(cmd, folderName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Config error: %v\n", err)
+		os.Exit(1)
+	}
+	if projectConfig.Folder == "" {
+		fmt.Fprintln(os.Stderr, "Config error: folder is required. Provide from argument or the folder in a .dotsecrc file")
+		os.Exit(1)
+	}
+
+	secretsData, err := fetchFolderSecrets(cmd, projectConfig)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	rendered, err := render.Render(templatePath, string(text), secretsData)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to render template: %v\n", err)
+		os.Exit(1)
+	}
+
+	if output == "" {
+		os.Stdout.Write(rendered)
+		return
+	}
+	if err := os.WriteFile(output, rendered, 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write %s: %v\n", output, err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Rendered %s to %s\n", templatePath, output)
+}
@@ -33,10 +33,12 @@ func init() {
 	cobra.OnInitialize(initConfig)
 	rootCmd.Version = "1.1.3"
 	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "Config file for dotsec to read information from.")
+	rootCmd.PersistentFlags().String("provider", "", "The secret manager provider to use (passbolt, keepass, vault, pass, sops, bitwarden, 1password, secretsmanager, ssm, azure, kubernetes or the name of a dotsec-provider-<name> plugin). Default to passbolt.")
 	rootCmd.PersistentFlags().String("server", "", "Passbolt Server to use (https://passbolt.example.com)")
 	rootCmd.PersistentFlags().String("privateKey", "", "Passbolt User Private Key")
 	rootCmd.PersistentFlags().String("password", "", "Passbolt User Password")
 
+	viper.BindPFlag("provider", rootCmd.PersistentFlags().Lookup("provider"))
 	viper.BindPFlag("server", rootCmd.PersistentFlags().Lookup("server"))
 	viper.BindPFlag("privateKey", rootCmd.PersistentFlags().Lookup("privateKey"))
 	viper.BindPFlag("password", rootCmd.PersistentFlags().Lookup("password"))
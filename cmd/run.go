@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/chadsmith12/dotsec/cmdcontext"
+	"github.com/chadsmith12/dotsec/config"
+	"github.com/chadsmith12/dotsec/secrets"
+	"github.com/spf13/cobra"
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run [foldername] -- command [args...]",
+	Short: "Runs a command with the secrets in a folder as environment variables",
+	Long: `Reads the secrets from the folder and runs the command with them added to its environment, without writing them to a file.
+		Secrets replace environment variables with the same name, unless --no-override is set. Use --dotnet-keys to replace the : in nested keys with __,
+		which is how .NET configuration reads nested keys from environment variables.
+
+		SIGTERM and SIGHUP sent to dotsec are forwarded to the command. Ctrl-C reaches the command from the terminal,
+		so dotsec waits for the command to handle it instead of forwarding it again. dotsec exits with the commands exit code.`,
+	Example: "dotsec run FolderName -- dotnet run --project ./api",
+	Args: func(cmd *cobra.Command, args []string) error {
+		dash := cmd.ArgsLenAtDash()
+		if dash == -1 || dash == len(args) {
+			return errors.New("a command to run is required after --")
+		}
+		if dash > 1 {
+			return fmt.Errorf("accepts at most 1 folder before --, received %d", dash)
+		}
+
+		return nil
+	},
+	Run: runRun,
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+	runCmd.Flags().Bool("dotnet-keys", false, "Replace the : in keys with __ so .NET configuration reads them as nested keys.")
+	runCmd.Flags().Bool("no-override", false, "Keep environment variables that are already set instead of replacing them with secrets of the same name.")
+}
+
+func runRun(cmd *cobra.Command, args []string) {
+	dash := cmd.ArgsLenAtDash()
+	folderName := ""
+	if dash == 1 {
+		folderName = args[0]
+	}
+	command := args[dash:]
+	dotnetKeys, _ := cmd.Flags().GetBool("dotnet-keys")
+	noOverride, _ := cmd.Flags().GetBool("no-override")
+
+	projectConfig, err := config.LoadProjectConfig(cmd, folderName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Config error: %v\n", err)
+		os.Exit(1)
+	}
+	if projectConfig.Folder == "" {
+		fmt.Fprintln(os.Stderr, "Config error: folder is required. Provide from argument or the folder in a .dotsecrc file")
+		os.Exit(1)
+	}
+
+	secretsData, err := fetchFolderSecrets(cmd, projectConfig)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	os.Exit(runWithSecrets(command, secretsData, dotnetKeys, !noOverride))
+}
+
+// Reads the secrets in the folder from the project config, for the commands that use them without a local file.
+func fetchFolderSecrets(cmd *cobra.Command, projectConfig *config.ProjectConfig) ([]secrets.SecretData, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cmdContext, err := cmdcontext.NewCommandContext(cmd, projectConfig)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create command context: %w", err)
+	}
+
+	store, err := cmdContext.SecretStore(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get %s client: %w", cmdContext.Provider(), err)
+	}
+
+	secretsData, err := secrets.GetSecretsByFolder(store, projectConfig.Folder)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve folder: %s - %w", projectConfig.Folder, err)
+	}
+
+	return secretsData, nil
+}
+
+// Runs the command with the secrets added to the environment and returns its exit code.
+func runWithSecrets(command []string, secretsData []secrets.SecretData, dotnetKeys, override bool) int {
+	child := exec.Command(command[0], command[1:]...)
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+	child.Env = secretsEnvironment(os.Environ(), secretsData, dotnetKeys, override)
+
+	// dotsec keeps running until the command exits, so signals are passed on instead of stopping dotsec first
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	// Ctrl-C and Ctrl-\ are sent by the terminal to the command as well, so they are caught and never read instead of
+	// being forwarded a second time. Catching them rather than ignoring them keeps the command from inheriting SIG_IGN.
+	terminalSignals := make(chan os.Signal, 1)
+	signal.Notify(terminalSignals, os.Interrupt, syscall.SIGQUIT)
+	defer signal.Stop(terminalSignals)
+
+	if err := child.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to run %s: %v\n", command[0], err)
+		return 127
+	}
+	go func() {
+		for sig := range signals {
+			child.Process.Signal(sig)
+		}
+	}()
+
+	err := child.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal())
+		}
+		return exitErr.ExitCode()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to run %s: %v\n", command[0], err)
+		return 1
+	}
+
+	return 0
+}
+
+// Adds the secrets to the environment. A secret replaces the variable with the same name,
+// unless override is false, in which case the variable that is already set is kept.
+func secretsEnvironment(environment []string, secretsData []secrets.SecretData, dotnetKeys, override bool) []string {
+	keys := make([]string, 0, len(secretsData))
+	values := map[string]string{}
+	for _, secret := range secretsData {
+		key := secret.Key
+		if dotnetKeys {
+			key = strings.ReplaceAll(key, ":", "__")
+		}
+		if _, found := values[key]; !found {
+			keys = append(keys, key)
+		}
+		values[key] = secret.Value
+	}
+
+	merged := make([]string, 0, len(environment)+len(keys))
+	for _, variable := range environment {
+		name, _, _ := strings.Cut(variable, "=")
+		if _, found := values[name]; found {
+			if override {
+				continue
+			}
+			delete(values, name)
+		}
+		merged = append(merged, variable)
+	}
+	for _, key := range keys {
+		if value, found := values[key]; found {
+			merged = append(merged, key+"="+value)
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chadsmith12/dotsec/secrets"
+)
+
+func TestSecretsEnvironment_Override(t *testing.T) {
+	environment := []string{"PATH=/usr/bin", "API_KEY=from-shell"}
+	secretsData := []secrets.SecretData{{Key: "API_KEY", Value: "from-secret"}, {Key: "DB_PASSWORD", Value: "hunter2"}}
+
+	testCases := map[string]struct {
+		override bool
+		expected []string
+	}{
+		"secrets replace variables":   {override: true, expected: []string{"PATH=/usr/bin", "API_KEY=from-secret", "DB_PASSWORD=hunter2"}},
+		"no override keeps variables": {override: false, expected: []string{"PATH=/usr/bin", "API_KEY=from-shell", "DB_PASSWORD=hunter2"}},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			actual := secretsEnvironment(environment, secretsData, false, testCase.override)
+			if !reflect.DeepEqual(actual, testCase.expected) {
+				t.Errorf("secretsEnvironment() = %v, expected %v", actual, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestSecretsEnvironment_DotnetKeys(t *testing.T) {
+	environment := []string{"ConnectionStrings__Default=from-shell"}
+	secretsData := []secrets.SecretData{{Key: "ConnectionStrings:Default", Value: "Server=db"}, {Key: "Logging:LogLevel:Default", Value: "Debug"}}
+
+	actual := secretsEnvironment(environment, secretsData, true, true)
+	expected := []string{"ConnectionStrings__Default=Server=db", "Logging__LogLevel__Default=Debug"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("secretsEnvironment() = %v, expected %v", actual, expected)
+	}
+
+	actual = secretsEnvironment(environment, secretsData, false, true)
+	expected = []string{"ConnectionStrings__Default=from-shell", "ConnectionStrings:Default=Server=db", "Logging:LogLevel:Default=Debug"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("secretsEnvironment() without --dotnet-keys = %v, expected %v", actual, expected)
+	}
+}
+
+func TestRunWithSecrets_PassesEnvironmentAndExitCode(t *testing.T) {
+	t.Setenv("DOTSEC_RUN_TEST", "from-shell")
+	secretsData := []secrets.SecretData{{Key: "DOTSEC_RUN_TEST", Value: "from-secret"}, {Key: "Api:Key", Value: "secret123"}}
+	// the command exits with 0 only when it sees the expected variables, and otherwise with a code that says which check failed
+	check := func(expected string) []string {
+		return []string{"sh", "-c", `test "$DOTSEC_RUN_TEST" = "` + expected + `" || exit 10; test "$Api__Key" = secret123 || exit 11; exit 0`}
+	}
+
+	if code := runWithSecrets(check("from-secret"), secretsData, true, true); code != 0 {
+		t.Errorf("runWithSecrets() = %d, expected the secret to replace the variable and the key to be mapped", code)
+	}
+	if code := runWithSecrets(check("from-shell"), secretsData, true, false); code != 0 {
+		t.Errorf("runWithSecrets() with --no-override = %d, expected the variable to be kept", code)
+	}
+	if code := runWithSecrets([]string{"sh", "-c", "exit 3"}, secretsData, false, true); code != 3 {
+		t.Errorf("runWithSecrets() = %d, expected the command's exit code 3", code)
+	}
+	if code := runWithSecrets([]string{"dotsec-missing-command"}, secretsData, false, true); code != 127 {
+		t.Errorf("runWithSecrets() = %d, expected 127 for a command that can't be started", code)
+	}
+}
@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/chadsmith12/dotsec/colors"
+	"github.com/chadsmith12/dotsec/input"
+	"github.com/chadsmith12/dotsec/secrets"
+	"github.com/spf13/cobra"
+)
+
+// How long pull and push wait on the secret manager, started over after every prompt.
+const syncTimeout = 30 * time.Second
+
+// The flags pull and push share that change how a folder is synced.
+type syncOptions struct {
+	continueOnError bool
+	prune           bool
+	// skips asking before deleting anything removed by prune
+	confirmed bool
+	// compares everything without writing, printing the operations that would have happened
+	dryRun bool
+	// syncs the subfolders of the folder too, prefixing the keys with the names of the subfolders
+	recursive bool
+	// creates the folder when pushing to one that doesn't exist
+	createFolder bool
+	// overwrites keys that changed on both sides since the last sync instead of stopping at the conflict
+	force bool
+	// the timeout of the secret stores context, paused while waiting on the user at a prompt. nil when there isn't one
+	deadline *promptDeadline
+}
+
+func syncOptionsFromFlags(cmd *cobra.Command) syncOptions {
+	continueOnError, _ := cmd.Flags().GetBool("continue-on-error")
+	prune, _ := cmd.Flags().GetBool("prune")
+	confirmed, _ := cmd.Flags().GetBool("yes")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	force, _ := cmd.Flags().GetBool("force")
+	recursive, _ := cmd.Flags().GetBool("recursive")
+	createFolder, _ := cmd.Flags().GetBool("create-folder")
+
+	return syncOptions{
+		continueOnError: continueOnError,
+		prune:           prune,
+		confirmed:       confirmed,
+		dryRun:          dryRun,
+		force:           force,
+		recursive:       recursive,
+		createFolder:    createFolder,
+	}
+}
+
+// Asks the user with the deadline paused, so the time spent answering isn't taken from the requests that come after.
+func (options syncOptions) promptUser(message string) (string, error) {
+	if options.deadline != nil {
+		options.deadline.pause()
+		defer options.deadline.resume()
+	}
+
+	return input.PromptUser(message, false)
+}
+
+// A promptDeadline is a context that times out once the secret manager has been waited on for longer than the timeout.
+// The time spent at a prompt doesn't count, and the timeout starts over after each prompt, so every network phase
+// of a sync gets the whole timeout no matter how long the user takes to answer.
+type promptDeadline struct {
+	context.Context
+	cancel  context.CancelFunc
+	timeout time.Duration
+	timer   *time.Timer
+	expired atomic.Bool
+}
+
+func withPromptDeadline(parent context.Context, timeout time.Duration) *promptDeadline {
+	deadline := &promptDeadline{timeout: timeout}
+	deadline.Context, deadline.cancel = context.WithCancel(parent)
+	deadline.timer = time.AfterFunc(timeout, func() {
+		deadline.expired.Store(true)
+		deadline.cancel()
+	})
+
+	return deadline
+}
+
+// Reports context.DeadlineExceeded once the timeout is up, the same as a context from context.WithTimeout.
+func (deadline *promptDeadline) Err() error {
+	if deadline.expired.Load() {
+		return context.DeadlineExceeded
+	}
+
+	return deadline.Context.Err()
+}
+
+func (deadline *promptDeadline) pause() {
+	deadline.timer.Stop()
+}
+
+func (deadline *promptDeadline) resume() {
+	if deadline.Context.Err() == nil {
+		deadline.timer.Reset(deadline.timeout)
+	}
+}
+
+// Stops the timer and cancels the context.
+func (deadline *promptDeadline) Stop() {
+	deadline.timer.Stop()
+	deadline.cancel()
+}
+
+// A planned operation on a single key, printed instead of running it for --dry-run.
+type plannedOperation struct {
+	operation string
+	key       string
+}
+
+func printPlan(out io.Writer, description string, plan []plannedOperation) {
+	fmt.Fprintln(out, colors.Cyan(fmt.Sprintf("Dry run - %s", description)))
+	if len(plan) == 0 {
+		fmt.Fprintln(out, "No secrets")
+		return
+	}
+
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, operation := range plan {
+		fmt.Fprintf(writer, "  %s\t%s\n", operation.operation, operation.key)
+	}
+	writer.Flush()
+}
+
+// failureReport collects what failed to sync in every folder, so pull and push can print a single summary at the end.
+type failureReport struct {
+	rows []failureRow
+}
+
+type failureRow struct {
+	folder    string
+	key       string
+	operation string
+	err       error
+}
+
+// Adds the secrets that failed in the error, or the error itself when it isn't about single secrets.
+func (report *failureReport) add(folder string, err error) {
+	var secretErrs secrets.SecretErrors
+	if !errors.As(err, &secretErrs) {
+		report.rows = append(report.rows, failureRow{folder: folder, key: "-", operation: "-", err: err})
+		return
+	}
+
+	for _, secretErr := range secretErrs {
+		report.rows = append(report.rows, failureRow{folder: folder, key: secretErr.Key, operation: secretErr.Operation, err: secretErr.Err})
+	}
+}
+
+func (report *failureReport) failed() bool {
+	return len(report.rows) > 0
+}
+
+func (report *failureReport) print(out io.Writer) {
+	fmt.Fprintln(out, colors.Red(fmt.Sprintf("\n%d failed:", len(report.rows))))
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "FOLDER\tKEY\tOPERATION\tERROR")
+	for _, row := range report.rows {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%v\n", row.folder, row.key, row.operation, row.err)
+	}
+	writer.Flush()
+}
+
+// The local path of a target as it is shown to the user, where an empty path is the current directory.
+func displayPath(path string) string {
+	if path == "" {
+		return "."
+	}
+
+	return path
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPromptDeadline_PausedWhilePrompting(t *testing.T) {
+	deadline := withPromptDeadline(context.Background(), 50*time.Millisecond)
+	defer deadline.Stop()
+
+	deadline.pause()
+	time.Sleep(100 * time.Millisecond)
+	if err := deadline.Err(); err != nil {
+		t.Fatalf("Err() = %v while paused, expected the time at a prompt not to count", err)
+	}
+
+	deadline.resume()
+	select {
+	case <-deadline.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the deadline should expire after it is resumed")
+	}
+	if err := deadline.Err(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Err() = %v, expected %v", err, context.DeadlineExceeded)
+	}
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/chadsmith12/dotsec/config"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -20,9 +21,13 @@ func init() {
 }
 
 func runTestCmd(cmd *cobra.Command, args []string) {
+	provider := viper.GetString("provider")
+	if provider == "" {
+		provider = config.DefaultProvider
+	}
 	server := viper.GetString("server")
 	password := viper.GetString("password")
 	privateKey := viper.GetString("privateKey")
 
-	fmt.Printf("Using following data: \n Server: %v\n Password: %v\n PrivateKey: %v\n", server, password, privateKey)
+	fmt.Printf("Using following data: \n Provider: %v\n Server: %v\n Password: %v\n PrivateKey: %v\n", provider, server, password, privateKey)
 }
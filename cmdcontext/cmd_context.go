@@ -5,13 +5,25 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/chadsmith12/dotsec/aws"
+	"github.com/chadsmith12/dotsec/azure"
+	"github.com/chadsmith12/dotsec/bitwarden"
 	"github.com/chadsmith12/dotsec/config"
 	"github.com/chadsmith12/dotsec/dotnet"
 	"github.com/chadsmith12/dotsec/env"
 	"github.com/chadsmith12/dotsec/input"
+	"github.com/chadsmith12/dotsec/keepass"
+	"github.com/chadsmith12/dotsec/kubernetes"
+	"github.com/chadsmith12/dotsec/onepassword"
+	"github.com/chadsmith12/dotsec/pass"
 	"github.com/chadsmith12/dotsec/passbolt"
+	"github.com/chadsmith12/dotsec/plugin"
 	"github.com/chadsmith12/dotsec/secrets"
+	"github.com/chadsmith12/dotsec/sops"
+	"github.com/chadsmith12/dotsec/vault"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -23,6 +35,7 @@ type Configuration struct {
 }
 
 type CommandContext struct {
+	provider      string
 	secretsType   string
 	configuration *Configuration
 	client        *passbolt.PassboltApi
@@ -31,15 +44,30 @@ type CommandContext struct {
 }
 
 // Initializes a new CommandContext which gives you context into the how the current command is being ran.
-// This is meant to be used accross commands that need to access Passbolt and the secrets.
+// This is meant to be used accross commands that need to access the secret store and the secrets.
 // Returns an error if the configuration is invalid or required flags are missing.
 func NewCommandContext(cmd *cobra.Command, projectConfig *config.ProjectConfig) (*CommandContext, error) {
-	configuration, err := getConfiguration()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get configuration: %w", err)
+	provider := projectConfig.Provider
+	if provider == "" {
+		provider = config.DefaultProvider
+	}
+
+	var configuration *Configuration
+	if provider == "passbolt" {
+		passboltConfig, err := getConfiguration()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get configuration: %w", err)
+		}
+		configuration = passboltConfig
+	} else {
+		configuration = &Configuration{
+			privateKey: viper.GetViper().GetString("privateKey"),
+			password:   viper.GetViper().GetString("password"),
+		}
 	}
 
 	return &CommandContext{
+		provider:      provider,
 		secretsType:   projectConfig.Type,
 		configuration: configuration,
 		cmd:           cmd,
@@ -47,14 +75,110 @@ func NewCommandContext(cmd *cobra.Command, projectConfig *config.ProjectConfig)
 	}, nil
 }
 
-// Gets the secret fetcher we are going to use get the secrets from this environment type
-func (cmdContext *CommandContext) SecretsFetcher() (secrets.SecretsFetcher, error) {
+// Gets the secret store for the provider configured for this project, logging the user in if needed.
+func (cmdContext *CommandContext) SecretStore(ctx context.Context) (secrets.SecretStore, error) {
+	switch cmdContext.provider {
+	case "passbolt":
+		return cmdContext.UserClient(ctx)
+	case "keepass":
+		return cmdContext.keepassDatabase()
+	case "vault":
+		return vaultClient(ctx)
+	case "pass", "gopass":
+		return cmdContext.passwordStore()
+	case "sops":
+		return cmdContext.sopsStore()
+	case "bitwarden", "vaultwarden", "bw":
+		return cmdContext.bitwardenClient()
+	case "1password", "onepassword", "op":
+		return onePasswordClient()
+	case "secretsmanager", "aws-secretsmanager":
+		return aws.NewSecretsManager(ctx, awsConfig())
+	case "ssm", "aws-ssm":
+		return aws.NewParameterStore(ctx, awsConfig())
+	case "azure", "keyvault", "azure-keyvault":
+		return keyVaultClient(ctx)
+	case "kubernetes", "k8s":
+		return kubernetesClient(ctx)
+	default:
+		return pluginProvider(cmdContext.provider)
+	}
+}
+
+// The name of the secret store provider being used.
+func (cmdContext *CommandContext) Provider() string {
+	return cmdContext.provider
+}
+
+// A Target is a folder in the secret store and the project or env file its secrets are synced with.
+type Target struct {
+	Folder string
+	Path   string
+	// the name of the project when the target is one of the projects in a solution
+	Project string
+}
+
+// Gets the folders and projects to sync. When the dotnet project is a solution, every project in it with user secrets is a target,
+// using the folder mapped to it in the projects of the .dotsecrc, or a subfolder of the folder named after the project.
+func (cmdContext *CommandContext) Targets() ([]Target, error) {
+	projectConfig := cmdContext.projectconfig
+	solution := ""
+	if cmdContext.secretsType == "dotnet" {
+		var err error
+		if solution, err = dotnet.FindSolution(projectConfig.Path); err != nil {
+			return nil, err
+		}
+	}
+	if solution == "" {
+		if projectConfig.Folder == "" {
+			return nil, errors.New("folder is required. Provide from argument or a .dotsecrc file")
+		}
+		path := projectConfig.Path
+		if path == "" && cmdContext.secretsType == "env" {
+			path = ".env"
+		}
+		return []Target{{Folder: projectConfig.Folder, Path: path}}, nil
+	}
+
+	projects, err := dotnet.SolutionProjects(solution)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]Target, 0, len(projects))
+	for _, project := range projects {
+		folder, found := mappedFolder(projectConfig.Projects, project)
+		if !found {
+			if projectConfig.Folder == "" {
+				fmt.Fprintf(os.Stderr, "Skipping %s - no folder is mapped to it in .dotsecrc\n", project.Name)
+				continue
+			}
+			folder = projectConfig.Folder + "/" + project.Name
+		}
+		targets = append(targets, Target{Folder: folder, Path: project.Path, Project: project.Name})
+	}
+
+	return targets, nil
+}
+
+func mappedFolder(mapping map[string]string, project dotnet.SolutionProject) (string, bool) {
+	for key, folder := range mapping {
+		key = strings.TrimPrefix(strings.ReplaceAll(key, `\`, "/"), "./")
+		if strings.EqualFold(key, project.Name) || strings.EqualFold(key, project.RelativePath) {
+			return folder, true
+		}
+	}
+
+	return "", false
+}
+
+// Gets the secret fetcher we are going to use to get the secrets from the project or env file at path
+func (cmdContext *CommandContext) SecretsFetcher(path string) (secrets.SecretsFetcher, error) {
 	switch cmdContext.secretsType {
 	case "dotnet":
-		project := cmdContext.projectconfig.Path
-		return dotnet.NewFetcher(project), nil
+		return dotnet.NewFetcher(path), nil
 	case "env":
-		envFile := cmdContext.projectconfig.Path
+		envFile := path
 		if envFile == "" {
 			envFile = ".env"
 		}
@@ -64,14 +188,13 @@ func (cmdContext *CommandContext) SecretsFetcher() (secrets.SecretsFetcher, erro
 	}
 }
 
-// Gets the secrets setter we are going to use to set the secrets from this environment type
-func (cmdContext *CommandContext) SecretsSetter() (secrets.SecretsSetter, error) {
+// Gets the secrets setter we are going to use to set the secrets in the project or env file at path
+func (cmdContext *CommandContext) SecretsSetter(path string) (secrets.SecretsSetter, error) {
 	switch cmdContext.secretsType {
 	case "dotnet":
-		project := cmdContext.projectconfig.Path
-		return dotnet.NewSetter(project), nil
+		return dotnet.NewSetter(path), nil
 	case "env":
-		envFile := cmdContext.projectconfig.Path
+		envFile := path
 		if envFile == "" {
 			envFile = ".env"
 		}
@@ -81,6 +204,24 @@ func (cmdContext *CommandContext) SecretsSetter() (secrets.SecretsSetter, error)
 	}
 }
 
+// Gets the groups new folders are shared with, from the .dotsecrc or else the users config.
+func (cmdContext *CommandContext) ShareGroups() []string {
+	if len(cmdContext.projectconfig.ShareGroups) > 0 {
+		return cmdContext.projectconfig.ShareGroups
+	}
+
+	return viper.GetViper().GetStringSlice("shareGroups")
+}
+
+// Gets how the keys of secrets in subfolders are named for the secrets type, when syncing recursively.
+func (cmdContext *CommandContext) KeyFormat() secrets.KeyFormat {
+	if cmdContext.secretsType == "env" {
+		return secrets.EnvKeys
+	}
+
+	return secrets.DotnetKeys
+}
+
 // Attempts to get and initialize the passbolt api client with the user logged in
 func (cmdContext *CommandContext) UserClient(ctx context.Context) (*passbolt.PassboltApi, error) {
 	if cmdContext.client != nil && cmdContext.client.ValidLogin() {
@@ -118,6 +259,233 @@ func (cmdContext *CommandContext) UserClient(ctx context.Context) (*passbolt.Pas
 	return cmdContext.client, nil
 }
 
+// Unlocks the KeePass database configured with keepassDatabase, using the master password and optional keepassKeyFile.
+func (cmdContext *CommandContext) keepassDatabase() (*keepass.KeepassDatabase, error) {
+	databasePath := viper.GetViper().GetString("keepassDatabase")
+	if databasePath == "" {
+		return nil, errors.New("keepassDatabase not configured - use configure command or environment variable")
+	}
+
+	password, err := cmdContext.Password()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get password: %w", err)
+	}
+
+	return keepass.Open(databasePath, password, viper.GetViper().GetString("keepassKeyFile"))
+}
+
+// Logs into Vault with vaultToken, or with AppRole when vaultRoleId and vaultSecretId are configured.
+// The standard VAULT_ADDR, VAULT_TOKEN and VAULT_NAMESPACE environment variables are used when dotsec has no value.
+func vaultClient(ctx context.Context) (*vault.VaultApi, error) {
+	address := configOrEnv("vaultAddress", "VAULT_ADDR")
+	if address == "" {
+		return nil, errors.New("vaultAddress not configured - use configure command or environment variable")
+	}
+
+	client, err := vault.NewClient(ctx, address, viper.GetViper().GetString("vaultMount"), configOrEnv("vaultNamespace", "VAULT_NAMESPACE"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Vault client: %w", err)
+	}
+
+	roleId := viper.GetViper().GetString("vaultRoleId")
+	if roleId != "" {
+		err = client.LoginWithAppRole(viper.GetViper().GetString("vaultAppRoleMount"), roleId, viper.GetViper().GetString("vaultSecretId"))
+	} else {
+		token := configOrEnv("vaultToken", "VAULT_TOKEN")
+		if token == "" {
+			return nil, errors.New("vaultToken or vaultRoleId not configured - use configure command or environment variable")
+		}
+		err = client.LoginWithToken(token)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to login to Vault: %w", err)
+	}
+
+	return client, nil
+}
+
+// Opens the pass password store from passStore, PASSWORD_STORE_DIR or ~/.password-store,
+// decrypting entries with the private key configured for dotsec.
+func (cmdContext *CommandContext) passwordStore() (*pass.PasswordStore, error) {
+	storeDir := configOrEnv("passStore", "PASSWORD_STORE_DIR")
+	if storeDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find home directory: %w", err)
+		}
+		storeDir = filepath.Join(home, ".password-store")
+	}
+
+	if cmdContext.configuration.privateKey == "" {
+		return nil, errors.New("privateKey not configured - use configure command, --privateKey flag, or environment variable")
+	}
+	keyData, err := os.ReadFile(cmdContext.configuration.privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key file: %w", err)
+	}
+
+	password, err := cmdContext.Password()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get password: %w", err)
+	}
+
+	return pass.NewStore(storeDir, string(keyData), password)
+}
+
+// Opens the SOPS files under sopsRoot, or the current directory, decrypting them with the age keys from
+// sopsAgeKeyFile, SOPS_AGE_KEY_FILE, SOPS_AGE_KEY or the default sops key file, and the private key configured for dotsec.
+func (cmdContext *CommandContext) sopsStore() (*sops.SopsStore, error) {
+	root := viper.GetViper().GetString("sopsRoot")
+	if root == "" {
+		root = "."
+	}
+
+	ageKeys := os.Getenv("SOPS_AGE_KEY")
+	keyFile := configOrEnv("sopsAgeKeyFile", "SOPS_AGE_KEY_FILE")
+	if keyFile == "" {
+		configDir, err := os.UserConfigDir()
+		if err == nil {
+			keyFile = filepath.Join(configDir, "sops", "age", "keys.txt")
+		}
+	}
+	if keyData, err := os.ReadFile(keyFile); err == nil {
+		ageKeys += "\n" + string(keyData)
+	} else if viper.GetViper().GetString("sopsAgeKeyFile") != "" {
+		return nil, fmt.Errorf("failed to read age key file: %w", err)
+	}
+
+	var armoredKey, password string
+	if cmdContext.configuration.privateKey != "" {
+		keyData, err := os.ReadFile(cmdContext.configuration.privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private key file: %w", err)
+		}
+		armoredKey = string(keyData)
+		password, err = cmdContext.Password()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get password: %w", err)
+		}
+	}
+
+	return sops.NewStore(root, ageKeys, armoredKey, password)
+}
+
+// Runs the bw CLI from bwPath or the PATH, reusing the session in bwSession or BW_SESSION when there is one.
+// A locked vault is unlocked with the master password, and the vault is synced so we never read stale items.
+func (cmdContext *CommandContext) bitwardenClient() (*bitwarden.BitwardenCli, error) {
+	client := bitwarden.NewClient(viper.GetViper().GetString("bwPath"))
+	if session := configOrEnv("bwSession", "BW_SESSION"); session != "" {
+		client.UseSession(session)
+	}
+
+	status, err := client.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Bitwarden status: %w", err)
+	}
+
+	password := ""
+	if status == "locked" {
+		password, err = cmdContext.Password()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get password: %w", err)
+		}
+	}
+	if err := client.Unlock(password); err != nil {
+		return nil, fmt.Errorf("failed to unlock Bitwarden: %w", err)
+	}
+
+	if err := client.Sync(); err != nil {
+		return nil, fmt.Errorf("failed to sync Bitwarden: %w", err)
+	}
+
+	return client, nil
+}
+
+// Runs the op CLI from opPath or the PATH. op uses its own session, desktop app integration or OP_SERVICE_ACCOUNT_TOKEN,
+// unless a session token from op signin --raw is configured with opAccount and opSession.
+func onePasswordClient() (*onepassword.OnePasswordCli, error) {
+	client := onepassword.NewClient(viper.GetViper().GetString("opPath"))
+	account := viper.GetViper().GetString("opAccount")
+	if session := viper.GetViper().GetString("opSession"); account != "" && session != "" {
+		client.UseSession(account, session)
+	}
+
+	if err := client.CheckSignedIn(); err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// Builds the AWS config from awsRegion, awsProfile and awsEndpoint. The SDK falls back to the standard AWS environment
+// variables and shared config files. awsEndpoint (or AWS_ENDPOINT_URL) points dotsec at LocalStack for local testing.
+func awsConfig() aws.Config {
+	return aws.Config{
+		Region:   viper.GetViper().GetString("awsRegion"),
+		Profile:  viper.GetViper().GetString("awsProfile"),
+		Endpoint: configOrEnv("awsEndpoint", "AWS_ENDPOINT_URL"),
+	}
+}
+
+// Logs into Azure Key Vault with azureToken, a service principal from azureClientId and azureClientSecret
+// (or the AZURE_* environment variables), and otherwise with the Azure CLI. azureEndpoint points dotsec at a local emulator.
+func keyVaultClient(ctx context.Context) (*azure.KeyVaultApi, error) {
+	client, err := azure.NewClient(ctx, viper.GetViper().GetString("azureEndpoint"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Key Vault client: %w", err)
+	}
+
+	clientId := configOrEnv("azureClientId", "AZURE_CLIENT_ID")
+	clientSecret := configOrEnv("azureClientSecret", "AZURE_CLIENT_SECRET")
+	switch {
+	case viper.GetViper().GetString("azureToken") != "":
+		client.LoginWithToken(viper.GetViper().GetString("azureToken"))
+	case clientId != "" && clientSecret != "":
+		err = client.LoginWithClientSecret(configOrEnv("azureAuthorityHost", "AZURE_AUTHORITY_HOST"), configOrEnv("azureTenantId", "AZURE_TENANT_ID"), clientId, clientSecret)
+	default:
+		err = client.LoginWithAzureCli()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to login to Azure: %w", err)
+	}
+
+	return client, nil
+}
+
+// Connects to the API server of kubeContext, or the current context, in the kubeconfig file from kubeconfig, KUBECONFIG or ~/.kube/config.
+func kubernetesClient(ctx context.Context) (*kubernetes.KubernetesApi, error) {
+	config, err := kubernetes.LoadKubeConfig(viper.GetViper().GetString("kubeconfig"), viper.GetViper().GetString("kubeContext"))
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := kubernetes.NewClient(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+
+	return client, nil
+}
+
+// Finds the dotsec-provider-<name> executable for a provider that isn't built in.
+// The providers.<name> section of the configuration is passed to it with every request.
+func pluginProvider(name string) (*plugin.Provider, error) {
+	provider, err := plugin.Find(name, viper.GetViper().GetStringMap("providers."+name))
+	if errors.Is(err, plugin.NotFoundErr) {
+		return nil, fmt.Errorf("unsupported provider: %s (no %s%s executable on the PATH)", name, plugin.ExecutablePrefix, name)
+	}
+
+	return provider, err
+}
+
+func configOrEnv(key, envName string) string {
+	if value := viper.GetViper().GetString(key); value != "" {
+		return value
+	}
+
+	return os.Getenv(envName)
+}
+
 // Attempts to get the password to unlock the users private key.
 // First checks to see if we have it stored from viper in the configuration.
 // If not then we will immediately prompt the user for their password.
@@ -6,14 +6,22 @@ import (
 	"os"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 const defaultName = ".dotsecrc"
 
+const DefaultProvider = "passbolt"
+
 type ProjectConfig struct {
-	Folder string `json:"folder"`
-	Type   string `json:"type"`
-	Path   string `json:"path"`
+	Provider string `json:"provider,omitempty"`
+	Folder   string `json:"folder"`
+	Type     string `json:"type"`
+	Path     string `json:"path"`
+	// maps the projects of a solution, by name or by their path relative to the solution, to the folder they use
+	Projects map[string]string `json:"projects,omitempty"`
+	// the groups a folder created by push --create-folder is shared with
+	ShareGroups []string `json:"shareGroups,omitempty"`
 }
 
 func defaultProjectConfig() ProjectConfig {
@@ -43,7 +51,7 @@ func WriteProjectConfig() error {
 	return nil
 }
 
-func WriteProjectConfigWithData(folder, secretType, path string) error {
+func WriteProjectConfigWithData(provider, folder, secretType, path string) error {
 	file, err := os.Create(defaultName)
 	if err != nil {
 		return fmt.Errorf("error creating .dotsecrc file: %w", err)
@@ -51,9 +59,10 @@ func WriteProjectConfigWithData(folder, secretType, path string) error {
 	defer file.Close()
 
 	config := ProjectConfig{
-		Folder: folder,
-		Type:   secretType,
-		Path:   path,
+		Provider: provider,
+		Folder:   folder,
+		Type:     secretType,
+		Path:     path,
 	}
 
 	data, err := json.MarshalIndent(config, "", "  ")
@@ -81,7 +90,7 @@ func LoadProjectConfig(cmd *cobra.Command, folder string) (*ProjectConfig, error
 		config.Folder = folder
 	}
 
-	if config.Folder == "" {
+	if config.Folder == "" && len(config.Projects) == 0 {
 		return nil, fmt.Errorf("folder is required. Provide from argument or a .dotsecrc file")
 	}
 
@@ -104,6 +113,19 @@ func loadFromFile() (*ProjectConfig, error) {
 
 func overrideFromFlags(cmd *cobra.Command, config *ProjectConfig) {
 	flags := cmd.Flags()
+	if provider, _ := flags.GetString("provider"); flags.Changed("provider") && provider != "" {
+		config.Provider = provider
+	}
+
+	// the .dotsecrc wins over the users config, so a project can pin the provider it uses
+	if config.Provider == "" {
+		config.Provider = viper.GetString("provider")
+	}
+
+	if config.Provider == "" {
+		config.Provider = DefaultProvider
+	}
+
 	if secretType, _ := flags.GetString("type"); secretType != "" {
 		config.Type = secretType
 	}
@@ -0,0 +1,101 @@
+package config_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/chadsmith12/dotsec/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func newTestCommand() *cobra.Command {
+	cmd := &cobra.Command{Use: "test"}
+	cmd.Flags().String("provider", "", "")
+	cmd.Flags().String("type", "", "")
+	cmd.Flags().String("project", "", "")
+	cmd.Flags().String("file", "", "")
+
+	return cmd
+}
+
+func inTempDir(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestLoadProjectConfig_DefaultProvider(t *testing.T) {
+	inTempDir(t)
+	viper.Reset()
+
+	projectConfig, err := config.LoadProjectConfig(newTestCommand(), "Folder")
+	if err != nil {
+		t.Fatalf("LoadProjectConfig failed: %v", err)
+	}
+
+	if projectConfig.Provider != config.DefaultProvider {
+		t.Errorf("Provider = %s, expected %s", projectConfig.Provider, config.DefaultProvider)
+	}
+}
+
+func TestLoadProjectConfig_ProviderPrecedence(t *testing.T) {
+	inTempDir(t)
+	viper.Reset()
+	viper.Set("provider", "from-user-config")
+
+	cmd := newTestCommand()
+	projectConfig, err := config.LoadProjectConfig(cmd, "Folder")
+	if err != nil {
+		t.Fatalf("LoadProjectConfig failed: %v", err)
+	}
+	if projectConfig.Provider != "from-user-config" {
+		t.Errorf("Provider = %s, expected the user config provider", projectConfig.Provider)
+	}
+
+	if err := os.WriteFile(".dotsecrc", []byte(`{"provider": "from-dotsecrc", "folder": "Folder"}`), 0600); err != nil {
+		t.Fatalf("Failed to write .dotsecrc: %v", err)
+	}
+	projectConfig, err = config.LoadProjectConfig(cmd, "")
+	if err != nil {
+		t.Fatalf("LoadProjectConfig failed: %v", err)
+	}
+	if projectConfig.Provider != "from-dotsecrc" {
+		t.Errorf("Provider = %s, expected the .dotsecrc provider", projectConfig.Provider)
+	}
+
+	cmd.Flags().Set("provider", "from-flag")
+	projectConfig, err = config.LoadProjectConfig(cmd, "")
+	if err != nil {
+		t.Fatalf("LoadProjectConfig failed: %v", err)
+	}
+	if projectConfig.Provider != "from-flag" {
+		t.Errorf("Provider = %s, expected the flag provider", projectConfig.Provider)
+	}
+}
+
+func TestLoadProjectConfig_ProjectsWithoutFolder(t *testing.T) {
+	inTempDir(t)
+	viper.Reset()
+
+	if _, err := config.LoadProjectConfig(newTestCommand(), ""); err == nil {
+		t.Error("LoadProjectConfig should require a folder")
+	}
+
+	if err := os.WriteFile(".dotsecrc", []byte(`{"type": "dotnet", "path": "Backend.sln", "projects": {"Api": "backend/api"}}`), 0600); err != nil {
+		t.Fatalf("Failed to write .dotsecrc: %v", err)
+	}
+	projectConfig, err := config.LoadProjectConfig(newTestCommand(), "")
+	if err != nil {
+		t.Fatalf("LoadProjectConfig failed: %v", err)
+	}
+	if projectConfig.Projects["Api"] != "backend/api" {
+		t.Errorf("Projects = %v, expected the Api project to be mapped", projectConfig.Projects)
+	}
+}
@@ -29,6 +29,16 @@ func SetSecret(projectPath, key, value string) error {
 	return logAndRunCommand(cmd)
 }
 
+func RemoveSecret(projectPath, key string) error {
+	cmd := exec.Command("dotnet", "user-secrets", "remove", key)
+	if projectPath != "" {
+		cmd.Args = append(cmd.Args, "--project")
+		cmd.Args = append(cmd.Args, projectPath)
+	}
+
+	return logAndRunCommand(cmd)
+}
+
 func ListSecrets(projectPath string) (bytes.Buffer, error) {
 	cmd := exec.Command("dotnet", "user-secrets", "list")
 	if projectPath != "" {
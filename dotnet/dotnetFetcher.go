@@ -1,6 +1,9 @@
 package dotnet
 
 import (
+	"errors"
+	"strings"
+
 	"github.com/chadsmith12/dotsec/secrets"
 )
 
@@ -12,7 +15,35 @@ func NewFetcher(project string) DotNetFetcher {
 	return DotNetFetcher{project: project}
 }
 
+// Reads the secrets straight from the projects secrets.json, flattening nested objects and arrays into
+// Section:Key and Items:0 keys. dotnet user-secrets list is only used when dotsec can't find the project file.
 func (fetcher DotNetFetcher) FetchSecrets() ([]secrets.SecretData, error) {
+	userSecrets, err := OpenUserSecrets(fetcher.project, false)
+	if errors.Is(err, NoUserSecretsIdErr) {
+		return []secrets.SecretData{}, nil
+	}
+	if errors.Is(err, ProjectNotFoundErr) {
+		return fetcher.fetchWithCli()
+	}
+	if err != nil {
+		return []secrets.SecretData{}, err
+	}
+
+	file, err := userSecrets.read()
+	if err != nil {
+		return []secrets.SecretData{}, err
+	}
+
+	entries := file.entries()
+	secretsData := make([]secrets.SecretData, 0, len(entries))
+	for _, entry := range entries {
+		secretsData = append(secretsData, secrets.SecretData{Key: entry.key, Value: entry.value})
+	}
+
+	return secretsData, nil
+}
+
+func (fetcher DotNetFetcher) fetchWithCli() ([]secrets.SecretData, error) {
 	stdOut, err := ListSecrets(fetcher.project)
 	if err != nil {
 		return []secrets.SecretData{}, err
@@ -22,6 +53,15 @@ func (fetcher DotNetFetcher) FetchSecrets() ([]secrets.SecretData, error) {
 		return []secrets.SecretData{}, err
 	}
 
-	secretsData := secrets.SecretDataFromSlice(values)
+	secretsData := make([]secrets.SecretData, 0, len(values))
+	for _, value := range values {
+		// dotnet user-secrets list writes "Key = Value", and values can contain " = " themselves
+		key, secret, found := strings.Cut(value, " = ")
+		if !found {
+			continue
+		}
+		secretsData = append(secretsData, secrets.SecretData{Key: key, Value: secret})
+	}
+
 	return secretsData, nil
 }
@@ -0,0 +1,317 @@
+package dotnet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// The separator .NET configuration uses between the sections of a key, such as ConnectionStrings:Default.
+const KeyDelimiter = ":"
+
+type nodeKind int
+
+const (
+	scalarNode nodeKind = iota
+	objectNode
+	arrayNode
+)
+
+// secretsJson is secrets.json as a tree that keeps the order, nesting and value types of the file,
+// so setting a few keys doesn't rewrite the parts of the file that didn't change.
+type secretsJson struct {
+	root *jsonNode
+}
+
+type jsonNode struct {
+	kind    nodeKind
+	members []jsonMember
+	items   []*jsonNode
+	// the value of a scalar as .NET configuration reads it, and as it is written in the file
+	value string
+	raw   string
+}
+
+type jsonMember struct {
+	key  string
+	node *jsonNode
+}
+
+type secretEntry struct {
+	key   string
+	value string
+	node  *jsonNode
+}
+
+func parseSecretsJson(data []byte) (*secretsJson, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return &secretsJson{root: &jsonNode{kind: objectNode}}, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	root, err := parseNode(decoder)
+	if err != nil {
+		return nil, fmt.Errorf("parsing secrets.json: %w", err)
+	}
+	if root.kind != objectNode {
+		return nil, errors.New("secrets.json is not a json object")
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("parsing secrets.json: unexpected data after the object")
+	}
+
+	return &secretsJson{root: root}, nil
+}
+
+func parseNode(decoder *json.Decoder) (*jsonNode, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token := token.(type) {
+	case json.Delim:
+		if token == '[' {
+			node := &jsonNode{kind: arrayNode, items: []*jsonNode{}}
+			for decoder.More() {
+				item, err := parseNode(decoder)
+				if err != nil {
+					return nil, err
+				}
+				node.items = append(node.items, item)
+			}
+			_, err := decoder.Token()
+			return node, err
+		}
+
+		node := &jsonNode{kind: objectNode, members: []jsonMember{}}
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			child, err := parseNode(decoder)
+			if err != nil {
+				return nil, err
+			}
+			node.members = append(node.members, jsonMember{key: key.(string), node: child})
+		}
+		_, err := decoder.Token()
+		return node, err
+	case string:
+		return stringNode(token), nil
+	case json.Number:
+		return &jsonNode{value: token.String(), raw: token.String()}, nil
+	case bool:
+		return &jsonNode{value: strconv.FormatBool(token), raw: strconv.FormatBool(token)}, nil
+	default:
+		return &jsonNode{value: "", raw: "null"}, nil
+	}
+}
+
+func stringNode(value string) *jsonNode {
+	return &jsonNode{value: value, raw: string(jsonString(value))}
+}
+
+// Flattens the tree into keys the same way .NET configuration does, joining sections with : and using the index of array items.
+// Empty objects and arrays don't have a value, so they are left out.
+func (file *secretsJson) entries() []secretEntry {
+	entries := []secretEntry{}
+	var visit func(prefix string, node *jsonNode)
+	visit = func(prefix string, node *jsonNode) {
+		switch node.kind {
+		case objectNode:
+			for _, member := range node.members {
+				visit(joinKey(prefix, member.key), member.node)
+			}
+		case arrayNode:
+			for i, item := range node.items {
+				visit(joinKey(prefix, strconv.Itoa(i)), item)
+			}
+		default:
+			entries = append(entries, secretEntry{key: prefix, value: node.value, node: node})
+		}
+	}
+	visit("", file.root)
+
+	return entries
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+
+	return prefix + KeyDelimiter + key
+}
+
+// Sets the value of a flattened key. An existing value is changed where it is, otherwise the value is added
+// under the deepest object or array that already exists for the key, the way it would have been written by hand.
+// Keys that have nothing in the file yet are added at the top the same as dotnet user-secrets set.
+func (file *secretsJson) set(key, value string) {
+	for _, entry := range file.entries() {
+		// configuration keys are case insensitive
+		if strings.EqualFold(entry.key, key) {
+			if entry.node.value != value || entry.node.raw == "null" {
+				*entry.node = *stringNode(value)
+			}
+			return
+		}
+	}
+
+	segments := strings.Split(key, KeyDelimiter)
+	current := file.root
+	depth := 0
+	for depth < len(segments)-1 {
+		child := current.child(segments[depth])
+		if child == nil || child.kind == scalarNode {
+			break
+		}
+		current = child
+		depth++
+	}
+
+	remaining := segments[depth:]
+	switch {
+	case depth == 0:
+		file.root.members = append(file.root.members, jsonMember{key: key, node: stringNode(value)})
+	case current.kind == objectNode:
+		current.members = append(current.members, jsonMember{key: remaining[0], node: buildNode(remaining[1:], value)})
+	case remaining[0] == strconv.Itoa(len(current.items)):
+		current.items = append(current.items, buildNode(remaining[1:], value))
+	default:
+		file.root.members = append(file.root.members, jsonMember{key: key, node: stringNode(value)})
+	}
+}
+
+// Removes the values of flattened keys, and the objects and arrays that are left empty by removing them.
+// Every key is found before anything is removed, so removing an array item doesn't shift which item a later key is.
+func (file *secretsJson) remove(keys ...string) {
+	targets := map[*jsonNode]bool{}
+	for _, entry := range file.entries() {
+		for _, key := range keys {
+			if strings.EqualFold(entry.key, key) {
+				targets[entry.node] = true
+			}
+		}
+	}
+
+	removeNodes(file.root, targets)
+}
+
+// Removes the targets from under node, returning true when removing them left node empty.
+func removeNodes(node *jsonNode, targets map[*jsonNode]bool) bool {
+	switch node.kind {
+	case objectNode:
+		if len(node.members) == 0 {
+			return false
+		}
+		kept := make([]jsonMember, 0, len(node.members))
+		for _, member := range node.members {
+			if !targets[member.node] && !removeNodes(member.node, targets) {
+				kept = append(kept, member)
+			}
+		}
+		node.members = kept
+		return len(kept) == 0
+	case arrayNode:
+		if len(node.items) == 0 {
+			return false
+		}
+		kept := make([]*jsonNode, 0, len(node.items))
+		for _, item := range node.items {
+			if !targets[item] && !removeNodes(item, targets) {
+				kept = append(kept, item)
+			}
+		}
+		node.items = kept
+		return len(kept) == 0
+	}
+
+	return false
+}
+
+func (node *jsonNode) child(segment string) *jsonNode {
+	switch node.kind {
+	case objectNode:
+		for _, member := range node.members {
+			if strings.EqualFold(member.key, segment) {
+				return member.node
+			}
+		}
+	case arrayNode:
+		if index, err := strconv.Atoi(segment); err == nil && index >= 0 && index < len(node.items) {
+			return node.items[index]
+		}
+	}
+
+	return nil
+}
+
+// Builds nested objects for the rest of a key, with the value at the end.
+func buildNode(segments []string, value string) *jsonNode {
+	if len(segments) == 0 {
+		return stringNode(value)
+	}
+
+	return &jsonNode{kind: objectNode, members: []jsonMember{{key: segments[0], node: buildNode(segments[1:], value)}}}
+}
+
+// Formats the file the same way dotnet user-secrets does, indented by two spaces.
+func (file *secretsJson) format() []byte {
+	var buffer bytes.Buffer
+	writeNode(&buffer, file.root, "")
+
+	return buffer.Bytes()
+}
+
+func writeNode(buffer *bytes.Buffer, node *jsonNode, indent string) {
+	switch node.kind {
+	case objectNode:
+		if len(node.members) == 0 {
+			buffer.WriteString("{}")
+			return
+		}
+		buffer.WriteString("{")
+		for i, member := range node.members {
+			if i > 0 {
+				buffer.WriteString(",")
+			}
+			buffer.WriteString("\n" + indent + "  ")
+			buffer.Write(jsonString(member.key))
+			buffer.WriteString(": ")
+			writeNode(buffer, member.node, indent+"  ")
+		}
+		buffer.WriteString("\n" + indent + "}")
+	case arrayNode:
+		if len(node.items) == 0 {
+			buffer.WriteString("[]")
+			return
+		}
+		buffer.WriteString("[")
+		for i, item := range node.items {
+			if i > 0 {
+				buffer.WriteString(",")
+			}
+			buffer.WriteString("\n" + indent + "  ")
+			writeNode(buffer, item, indent+"  ")
+		}
+		buffer.WriteString("\n" + indent + "]")
+	default:
+		buffer.WriteString(node.raw)
+	}
+}
+
+func jsonString(value string) []byte {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(value)
+
+	return bytes.TrimRight(buffer.Bytes(), "\n")
+}
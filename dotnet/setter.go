@@ -1,6 +1,10 @@
 package dotnet
 
-import "github.com/chadsmith12/dotsec/secrets"
+import (
+	"errors"
+
+	"github.com/chadsmith12/dotsec/secrets"
+)
 
 type DotNetSetter struct {
 	project string
@@ -10,14 +14,74 @@ func NewSetter(project string) DotNetSetter {
 	return DotNetSetter{project: project}
 }
 
-func (setter DotNetSetter) SetSecrets(secrets []secrets.SecretData) error {
+// Writes the secrets straight to the projects secrets.json, adding a UserSecretsId to the project when it does not have one.
+// Secrets already in the file are changed where they are, so nested objects and arrays are kept.
+// dotnet user-secrets set is only used when dotsec can't find the project file.
+func (setter DotNetSetter) SetSecrets(secretsData []secrets.SecretData) error {
+	userSecrets, err := OpenUserSecrets(setter.project, true)
+	if errors.Is(err, ProjectNotFoundErr) {
+		return setter.setWithCli(secretsData)
+	}
+	if err != nil {
+		return err
+	}
+
+	file, err := userSecrets.read()
+	if err != nil {
+		return err
+	}
+	for _, secret := range secretsData {
+		file.set(secret.Key, secret.Value)
+	}
+
+	return userSecrets.write(file)
+}
+
+// Sets every secret with dotnet user-secrets set, returning the secrets that failed as SecretErrors.
+func (setter DotNetSetter) setWithCli(secretsData []secrets.SecretData) error {
 	if err := InitSecrets(setter.project); err != nil {
 		return err
 	}
 
-	for _, secret := range secrets {
-		SetSecret(setter.project, secret.Key, secret.Value)
+	var errs secrets.SecretErrors
+	for _, secret := range secretsData {
+		if err := SetSecret(setter.project, secret.Key, secret.Value); err != nil {
+			errs = append(errs, secrets.SecretError{Key: secret.Key, Operation: "set", Err: err})
+		}
+	}
+
+	return errs.Err()
+}
+
+// Removes the keys from the projects secrets.json, along with any objects or arrays left empty by removing them.
+func (setter DotNetSetter) RemoveSecrets(keys []string) error {
+	userSecrets, err := OpenUserSecrets(setter.project, false)
+	if errors.Is(err, NoUserSecretsIdErr) {
+		return nil
+	}
+	if errors.Is(err, ProjectNotFoundErr) {
+		return setter.removeWithCli(keys)
+	}
+	if err != nil {
+		return err
+	}
+
+	file, err := userSecrets.read()
+	if err != nil {
+		return err
+	}
+	file.remove(keys...)
+
+	return userSecrets.write(file)
+}
+
+func (setter DotNetSetter) removeWithCli(keys []string) error {
+	var errs secrets.SecretErrors
+	for _, key := range keys {
+		if err := RemoveSecret(setter.project, key); err != nil {
+			errs = append(errs, secrets.SecretError{Key: key, Operation: "remove", Err: err})
+		}
 	}
 
-	return nil
+	return errs.Err()
 }
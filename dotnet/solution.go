@@ -0,0 +1,140 @@
+package dotnet
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// A project line in a .sln file, such as Project("{FAE04EC0-...}") = "Api", "src\Api\Api.csproj", "{6EC3...}"
+var solutionProjectPattern = regexp.MustCompile(`^Project\("[^"]*"\)\s*=\s*"([^"]*)"\s*,\s*"([^"]*)"`)
+
+// A project in a solution that has user secrets.
+type SolutionProject struct {
+	Name string
+	// the path of the project file relative to the solution, using forward slashes
+	RelativePath string
+	Path         string
+	Id           string
+}
+
+// Finds the solution at path. Returns an empty string when path is a project, or a directory that has a project file,
+// so a directory with both a solution and a project keeps using the project the same as dotnet user-secrets.
+func FindSolution(path string) (string, error) {
+	if path == "" {
+		path = "."
+	}
+	if isSolution(path) {
+		return path, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		return "", nil
+	}
+	if projects, _ := filepath.Glob(filepath.Join(path, "*.*proj")); len(projects) > 0 {
+		return "", nil
+	}
+
+	solutions, err := filepath.Glob(filepath.Join(path, "*.sln"))
+	if err != nil {
+		return "", err
+	}
+	slnx, err := filepath.Glob(filepath.Join(path, "*.slnx"))
+	if err != nil {
+		return "", err
+	}
+	solutions = append(solutions, slnx...)
+	if len(solutions) > 1 {
+		return "", fmt.Errorf("multiple solution files found in %s, specify which one to use with --project", path)
+	}
+	if len(solutions) == 0 {
+		return "", nil
+	}
+
+	return solutions[0], nil
+}
+
+func isSolution(path string) bool {
+	extension := strings.ToLower(filepath.Ext(path))
+	return extension == ".sln" || extension == ".slnx"
+}
+
+// Lists the projects in the solution that have a UserSecretsId, in the order they are in the solution.
+func SolutionProjects(solution string) ([]SolutionProject, error) {
+	paths, err := readSolution(solution)
+	if err != nil {
+		return nil, err
+	}
+
+	projects := make([]SolutionProject, 0, len(paths))
+	for _, relativePath := range paths {
+		path := filepath.Join(filepath.Dir(solution), filepath.FromSlash(relativePath))
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("project %s in %s: %w", relativePath, solution, err)
+		}
+		id, err := UserSecretsId(path)
+		if errors.Is(err, NoUserSecretsIdErr) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		projects = append(projects, SolutionProject{Name: name, RelativePath: relativePath, Path: path, Id: id})
+	}
+
+	return projects, nil
+}
+
+// Reads the paths of the project files in a .sln or .slnx, leaving out solution folders.
+func readSolution(solution string) ([]string, error) {
+	data, err := os.ReadFile(solution)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{}
+	if strings.EqualFold(filepath.Ext(solution), ".slnx") {
+		var file struct {
+			Projects []struct {
+				Path string `xml:"Path,attr"`
+			} `xml:"Project"`
+			Folders []struct {
+				Projects []struct {
+					Path string `xml:"Path,attr"`
+				} `xml:"Project"`
+			} `xml:"Folder"`
+		}
+		if err := xml.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", solution, err)
+		}
+		for _, project := range file.Projects {
+			paths = append(paths, strings.ReplaceAll(project.Path, `\`, "/"))
+		}
+		for _, folder := range file.Folders {
+			for _, project := range folder.Projects {
+				paths = append(paths, strings.ReplaceAll(project.Path, `\`, "/"))
+			}
+		}
+		return paths, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		match := solutionProjectPattern.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if match == nil || !strings.HasSuffix(strings.ToLower(match[2]), "proj") {
+			continue
+		}
+		paths = append(paths, strings.ReplaceAll(match[2], `\`, "/"))
+	}
+
+	return paths, scanner.Err()
+}
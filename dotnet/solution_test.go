@@ -0,0 +1,70 @@
+package dotnet_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/chadsmith12/dotsec/dotnet"
+)
+
+const solution = `
+Microsoft Visual Studio Solution File, Format Version 12.00
+# Visual Studio Version 17
+Project("{2150E333-8FDC-42A3-9474-1A3956D46DE8}") = "src", "src", "{0C88DD14-F956-CE84-757C-A364CCF449FC}"
+EndProject
+Project("{FAE04EC0-301F-11D3-BF4B-00C04F79EFBC}") = "Api", "src\Api\Api.csproj", "{6EC3BC37-5F2E-4D5B-9C3A-1B2C3D4E5F60}"
+EndProject
+Project("{FAE04EC0-301F-11D3-BF4B-00C04F79EFBC}") = "Shared", "src\Shared\Shared.csproj", "{7FD4CD48-6A3F-4E6C-AD4B-2C3D4E5F6071}"
+EndProject
+Project("{FAE04EC0-301F-11D3-BF4B-00C04F79EFBC}") = "Worker", "src\Worker\Worker.csproj", "{8AE5DE59-7B40-4F7D-BE5C-3D4E5F607182}"
+EndProject
+Global
+EndGlobal
+`
+
+func TestSolutionProjects_OnlyProjectsWithUserSecrets(t *testing.T) {
+	root, _ := newTestProject(t, map[string]string{
+		"Backend.sln":                  solution,
+		"src/Api/Api.csproj":           projectWithId,
+		"src/Shared/Shared.csproj":     "<Project Sdk=\"Microsoft.NET.Sdk\">\n</Project>\n",
+		"src/Worker/Worker.csproj":     "<Project Sdk=\"Microsoft.NET.Sdk.Worker\">\n  <PropertyGroup>\n    <UserSecretsId>worker-id</UserSecretsId>\n  </PropertyGroup>\n</Project>\n",
+		"tools/Tool/Tool.csproj":       projectWithId,
+		"tools/Tool/Tool.Tests.csproj": projectWithId,
+	})
+
+	found, err := dotnet.FindSolution(root)
+	if err != nil || found != filepath.Join(root, "Backend.sln") {
+		t.Fatalf("FindSolution() = %s, %v, expected Backend.sln", found, err)
+	}
+	if found, _ := dotnet.FindSolution(filepath.Join(root, "src", "Api")); found != "" {
+		t.Errorf("FindSolution() = %s, expected a project directory to not be a solution", found)
+	}
+
+	projects, err := dotnet.SolutionProjects(found)
+	if err != nil {
+		t.Fatalf("SolutionProjects failed: %v", err)
+	}
+	expected := []dotnet.SolutionProject{
+		{Name: "Api", RelativePath: "src/Api/Api.csproj", Path: filepath.Join(root, "src", "Api", "Api.csproj"), Id: "4d5c8a2e-1f0b-4a8e-9c3d-7b6a5e4f3d2c"},
+		{Name: "Worker", RelativePath: "src/Worker/Worker.csproj", Path: filepath.Join(root, "src", "Worker", "Worker.csproj"), Id: "worker-id"},
+	}
+	if !reflect.DeepEqual(projects, expected) {
+		t.Errorf("SolutionProjects() = %v, expected %v", projects, expected)
+	}
+}
+
+func TestSolutionProjects_Slnx(t *testing.T) {
+	root, _ := newTestProject(t, map[string]string{
+		"Backend.slnx":       "<Solution>\n  <Folder Name=\"/src/\">\n    <Project Path=\"src/Api/Api.csproj\" />\n  </Folder>\n</Solution>\n",
+		"src/Api/Api.csproj": projectWithId,
+	})
+
+	projects, err := dotnet.SolutionProjects(filepath.Join(root, "Backend.slnx"))
+	if err != nil {
+		t.Fatalf("SolutionProjects failed: %v", err)
+	}
+	if len(projects) != 1 || projects[0].Name != "Api" {
+		t.Errorf("SolutionProjects() = %v, expected the Api project", projects)
+	}
+}
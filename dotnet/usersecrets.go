@@ -0,0 +1,241 @@
+package dotnet
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+var (
+	ProjectNotFoundErr   = errors.New("no project file found")
+	NoUserSecretsIdErr   = errors.New("project does not have a UserSecretsId")
+	propertyGroupPattern = regexp.MustCompile(`(?m)^([ \t]*)<PropertyGroup\s*>[ \t]*\r?\n`)
+	projectPattern       = regexp.MustCompile(`<Project\b[^>]*>[ \t]*\r?\n?`)
+)
+
+// The user secrets of a project, stored in secrets.json under the users profile, the same file dotnet user-secrets uses.
+type UserSecrets struct {
+	Project string
+	Id      string
+	Path    string
+}
+
+// Finds the project file at path. A directory is searched for a single project file, the same as dotnet user-secrets --project.
+// An empty path uses the current directory.
+func FindProject(path string) (string, error) {
+	if path == "" {
+		path = "."
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ProjectNotFoundErr, err)
+	}
+	if !info.IsDir() {
+		return path, nil
+	}
+
+	projects, err := filepath.Glob(filepath.Join(path, "*.*proj"))
+	if err != nil {
+		return "", err
+	}
+	switch len(projects) {
+	case 0:
+		return "", fmt.Errorf("%w in %s", ProjectNotFoundErr, path)
+	case 1:
+		return projects[0], nil
+	default:
+		return "", fmt.Errorf("multiple project files found in %s, specify which one to use with --project", path)
+	}
+}
+
+// Gets the UserSecretsId of the project, which is set in the project file or a Directory.Build.props in one of its parent directories.
+func UserSecretsId(project string) (string, error) {
+	id, err := readUserSecretsId(project)
+	if err != nil || id != "" {
+		return id, err
+	}
+
+	// msbuild only imports the closest Directory.Build.props
+	dir, err := filepath.Abs(filepath.Dir(project))
+	if err != nil {
+		return "", err
+	}
+	for {
+		props := filepath.Join(dir, "Directory.Build.props")
+		if _, err := os.Stat(props); err == nil {
+			id, err := readUserSecretsId(props)
+			if err != nil || id != "" {
+				return id, err
+			}
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	return "", NoUserSecretsIdErr
+}
+
+func readUserSecretsId(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	var project struct {
+		PropertyGroups []struct {
+			UserSecretsId string `xml:"UserSecretsId"`
+		} `xml:"PropertyGroup"`
+	}
+	if err := xml.Unmarshal(data, &project); err != nil {
+		return "", fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for _, group := range project.PropertyGroups {
+		if id := strings.TrimSpace(group.UserSecretsId); id != "" {
+			return id, nil
+		}
+	}
+
+	return "", nil
+}
+
+// Adds a new UserSecretsId to the first PropertyGroup of the project, the same as dotnet user-secrets init.
+func InitUserSecretsId(project string) (string, error) {
+	data, err := os.ReadFile(project)
+	if err != nil {
+		return "", err
+	}
+	id, err := newGuid()
+	if err != nil {
+		return "", err
+	}
+
+	var updated []byte
+	if match := propertyGroupPattern.FindSubmatchIndex(data); match != nil {
+		indent := string(data[match[2]:match[3]])
+		element := fmt.Sprintf("%s%s<UserSecretsId>%s</UserSecretsId>\n", indent, detectIndent(data[match[1]:], indent), id)
+		updated = insertAt(data, match[1], element)
+	} else if match := projectPattern.FindIndex(data); match != nil {
+		element := fmt.Sprintf("  <PropertyGroup>\n    <UserSecretsId>%s</UserSecretsId>\n  </PropertyGroup>\n", id)
+		if data[match[1]-1] != '\n' {
+			element = "\n" + element
+		}
+		updated = insertAt(data, match[1], element)
+	} else {
+		return "", fmt.Errorf("%s is not a project file", project)
+	}
+
+	info, err := os.Stat(project)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(project, updated, info.Mode().Perm()); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// Guesses one level of indentation from the first line in the PropertyGroup, defaulting to two spaces.
+func detectIndent(group []byte, groupIndent string) string {
+	line, _, _ := bytes.Cut(group, []byte("\n"))
+	indent := line[:len(line)-len(bytes.TrimLeft(line, " \t"))]
+	if len(indent) <= len(groupIndent) || !bytes.HasPrefix(indent, []byte(groupIndent)) {
+		return "  "
+	}
+
+	return string(indent[len(groupIndent):])
+}
+
+func insertAt(data []byte, index int, value string) []byte {
+	updated := make([]byte, 0, len(data)+len(value))
+	updated = append(updated, data[:index]...)
+	updated = append(updated, value...)
+
+	return append(updated, data[index:]...)
+}
+
+func newGuid() (string, error) {
+	guid := make([]byte, 16)
+	if _, err := rand.Read(guid); err != nil {
+		return "", err
+	}
+	guid[6] = guid[6]&0x0f | 0x40
+	guid[8] = guid[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", guid[0:4], guid[4:6], guid[6:8], guid[8:10], guid[10:]), nil
+}
+
+// Gets the path of secrets.json for the id, which is %APPDATA%\Microsoft\UserSecrets on Windows and ~/.microsoft/usersecrets everywhere else.
+func SecretsPath(id string) (string, error) {
+	if runtime.GOOS == "windows" {
+		appData := os.Getenv("APPDATA")
+		if appData == "" {
+			return "", errors.New("APPDATA is not set")
+		}
+		return filepath.Join(appData, "Microsoft", "UserSecrets", id, "secrets.json"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory: %w", err)
+	}
+
+	return filepath.Join(home, ".microsoft", "usersecrets", id, "secrets.json"), nil
+}
+
+// Resolves the user secrets of the project at path. When create is true a UserSecretsId is added to the project if it does not have one.
+func OpenUserSecrets(path string, create bool) (UserSecrets, error) {
+	project, err := FindProject(path)
+	if err != nil {
+		return UserSecrets{}, err
+	}
+
+	id, err := UserSecretsId(project)
+	if errors.Is(err, NoUserSecretsIdErr) && create {
+		id, err = InitUserSecretsId(project)
+	}
+	if err != nil {
+		return UserSecrets{}, err
+	}
+
+	secretsPath, err := SecretsPath(id)
+	if err != nil {
+		return UserSecrets{}, err
+	}
+
+	return UserSecrets{Project: project, Id: id, Path: secretsPath}, nil
+}
+
+// Reads the secrets in secrets.json. A missing file has no secrets.
+func (userSecrets UserSecrets) read() (*secretsJson, error) {
+	data, err := os.ReadFile(userSecrets.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return parseSecretsJson(nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSecretsJson(data)
+}
+
+// Writes secrets.json, creating the directory for the id when it does not exist yet.
+func (userSecrets UserSecrets) write(file *secretsJson) error {
+	if err := os.MkdirAll(filepath.Dir(userSecrets.Path), 0700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(userSecrets.Path, file.format(), 0600)
+}
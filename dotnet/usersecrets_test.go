@@ -0,0 +1,270 @@
+package dotnet_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/chadsmith12/dotsec/dotnet"
+	"github.com/chadsmith12/dotsec/secrets"
+)
+
+const projectWithId = `<Project Sdk="Microsoft.NET.Sdk.Web">
+
+  <PropertyGroup>
+    <TargetFramework>net8.0</TargetFramework>
+    <UserSecretsId>4d5c8a2e-1f0b-4a8e-9c3d-7b6a5e4f3d2c</UserSecretsId>
+  </PropertyGroup>
+
+</Project>
+`
+
+// Creates a project in a temp directory and points the home directory at another, so secrets.json is written there.
+func newTestProject(t *testing.T, files map[string]string) (string, string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("APPDATA", home)
+
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	return root, home
+}
+
+func TestSetSecrets_WritesSecretsJson(t *testing.T) {
+	root, home := newTestProject(t, map[string]string{"api/Api.csproj": projectWithId})
+	secretsPath, _ := dotnet.SecretsPath("4d5c8a2e-1f0b-4a8e-9c3d-7b6a5e4f3d2c")
+	if !strings.HasPrefix(secretsPath, home) {
+		t.Fatalf("SecretsPath() = %s, expected it to be in %s", secretsPath, home)
+	}
+	os.MkdirAll(filepath.Dir(secretsPath), 0700)
+	os.WriteFile(secretsPath, []byte(`{"Existing": "kept", "ApiKey": "old"}`), 0600)
+
+	setter := dotnet.NewSetter(filepath.Join(root, "api"))
+	if err := setter.SetSecrets([]secrets.SecretData{{Key: "apikey", Value: "new"}, {Key: "Html", Value: "<b>&</b>"}}); err != nil {
+		t.Fatalf("SetSecrets failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(secretsPath)
+	expected := "{\n  \"Existing\": \"kept\",\n  \"ApiKey\": \"new\",\n  \"Html\": \"<b>&</b>\"\n}"
+	if string(data) != expected {
+		t.Errorf("secrets.json = %s, expected %s", data, expected)
+	}
+
+	actual, err := dotnet.NewFetcher(filepath.Join(root, "api", "Api.csproj")).FetchSecrets()
+	if err != nil {
+		t.Fatalf("FetchSecrets failed: %v", err)
+	}
+	expectedSecrets := []secrets.SecretData{{Key: "Existing", Value: "kept"}, {Key: "ApiKey", Value: "new"}, {Key: "Html", Value: "<b>&</b>"}}
+	if !reflect.DeepEqual(actual, expectedSecrets) {
+		t.Errorf("FetchSecrets() = %v, expected %v", actual, expectedSecrets)
+	}
+}
+
+func TestUserSecretsId_FromDirectoryBuildProps(t *testing.T) {
+	root, _ := newTestProject(t, map[string]string{
+		"Directory.Build.props": "<Project>\n  <PropertyGroup>\n    <UserSecretsId>shared-id</UserSecretsId>\n  </PropertyGroup>\n</Project>\n",
+		"src/Api/Api.csproj":    "<Project Sdk=\"Microsoft.NET.Sdk\">\n</Project>\n",
+		"src/Web/Web.csproj":    projectWithId,
+	})
+
+	testCases := map[string]string{
+		"src/Api/Api.csproj": "shared-id",
+		"src/Web":            "4d5c8a2e-1f0b-4a8e-9c3d-7b6a5e4f3d2c",
+	}
+	for path, expected := range testCases {
+		project, err := dotnet.FindProject(filepath.Join(root, path))
+		if err != nil {
+			t.Fatalf("FindProject(%s) failed: %v", path, err)
+		}
+		id, err := dotnet.UserSecretsId(project)
+		if err != nil || id != expected {
+			t.Errorf("UserSecretsId(%s) = %s, %v, expected %s", path, id, err, expected)
+		}
+	}
+}
+
+func TestFetchSecrets_NoUserSecretsId(t *testing.T) {
+	root, _ := newTestProject(t, map[string]string{"Api.csproj": "<Project Sdk=\"Microsoft.NET.Sdk\">\n</Project>\n"})
+
+	actual, err := dotnet.NewFetcher(root).FetchSecrets()
+	if err != nil || len(actual) != 0 {
+		t.Errorf("FetchSecrets() = %v, %v, expected no secrets", actual, err)
+	}
+}
+
+func TestSecrets_BrokenProjectIsNotPassedToCli(t *testing.T) {
+	root, _ := newTestProject(t, map[string]string{"Api.csproj": "<Project Sdk=\"Microsoft.NET.Sdk\">\n  <PropertyGroup>\n"})
+	// a fake dotnet that succeeds, so falling back to the cli would hide the error
+	bin := t.TempDir()
+	os.WriteFile(filepath.Join(bin, "dotnet"), []byte("#!/bin/sh\nexit 0\n"), 0700)
+	t.Setenv("PATH", bin)
+
+	if _, err := dotnet.NewFetcher(root).FetchSecrets(); err == nil || !strings.Contains(err.Error(), "parsing") {
+		t.Errorf("FetchSecrets() error = %v, expected the project parse error", err)
+	}
+	if err := dotnet.NewSetter(root).SetSecrets([]secrets.SecretData{{Key: "ApiKey", Value: "secret123"}}); err == nil || !strings.Contains(err.Error(), "parsing") {
+		t.Errorf("SetSecrets() error = %v, expected the project parse error", err)
+	}
+	if err := dotnet.NewSetter(root).RemoveSecrets([]string{"ApiKey"}); err == nil || !strings.Contains(err.Error(), "parsing") {
+		t.Errorf("RemoveSecrets() error = %v, expected the project parse error", err)
+	}
+}
+
+func TestSetSecrets_InitializesUserSecretsId(t *testing.T) {
+	project := "<Project Sdk=\"Microsoft.NET.Sdk\">\n\n\t<PropertyGroup>\n\t\t<TargetFramework>net8.0</TargetFramework>\n\t</PropertyGroup>\n\n</Project>\n"
+	root, _ := newTestProject(t, map[string]string{"Api.csproj": project})
+
+	if err := dotnet.NewSetter(root).SetSecrets([]secrets.SecretData{{Key: "ApiKey", Value: "secret123"}}); err != nil {
+		t.Fatalf("SetSecrets failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(root, "Api.csproj"))
+	pattern := regexp.MustCompile("\n\t<PropertyGroup>\n\t\t<UserSecretsId>[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}</UserSecretsId>\n\t\t<TargetFramework>")
+	if !pattern.Match(data) {
+		t.Fatalf("project = %s, expected a UserSecretsId in the PropertyGroup", data)
+	}
+
+	actual, err := dotnet.NewFetcher(root).FetchSecrets()
+	expected := []secrets.SecretData{{Key: "ApiKey", Value: "secret123"}}
+	if err != nil || !reflect.DeepEqual(actual, expected) {
+		t.Errorf("FetchSecrets() = %v, %v, expected %v", actual, err, expected)
+	}
+}
+
+func TestSecrets_NestedRoundTrip(t *testing.T) {
+	root, _ := newTestProject(t, map[string]string{"Api.csproj": projectWithId})
+	secretsPath, _ := dotnet.SecretsPath("4d5c8a2e-1f0b-4a8e-9c3d-7b6a5e4f3d2c")
+	os.MkdirAll(filepath.Dir(secretsPath), 0700)
+	original := `{
+  "ConnectionStrings": {
+    "Default": "Server=db;Password=a = b"
+  },
+  "Serilog:MinimumLevel": "Information",
+  "Items": [
+    "first",
+    {
+      "Name": "second",
+      "Port": 5432,
+      "Enabled": true
+    }
+  ],
+  "Empty": {}
+}`
+	os.WriteFile(secretsPath, []byte(original), 0600)
+
+	actual, err := dotnet.NewFetcher(root).FetchSecrets()
+	if err != nil {
+		t.Fatalf("FetchSecrets failed: %v", err)
+	}
+	expected := []secrets.SecretData{
+		{Key: "ConnectionStrings:Default", Value: "Server=db;Password=a = b"},
+		{Key: "Serilog:MinimumLevel", Value: "Information"},
+		{Key: "Items:0", Value: "first"},
+		{Key: "Items:1:Name", Value: "second"},
+		{Key: "Items:1:Port", Value: "5432"},
+		{Key: "Items:1:Enabled", Value: "true"},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("FetchSecrets() = %v, expected %v", actual, expected)
+	}
+
+	// setting the same values back doesn't change the file
+	if err := dotnet.NewSetter(root).SetSecrets(actual); err != nil {
+		t.Fatalf("SetSecrets failed: %v", err)
+	}
+	if data, _ := os.ReadFile(secretsPath); string(data) != original {
+		t.Errorf("secrets.json = %s, expected it to be unchanged", data)
+	}
+
+	changed := []secrets.SecretData{
+		{Key: "connectionstrings:default", Value: "Server=db2"},
+		{Key: "ConnectionStrings:Redis", Value: "localhost"},
+		{Key: "Items:2", Value: "third"},
+		{Key: "Items:1:Port", Value: "5433"},
+		{Key: "Logging:LogLevel:Default", Value: "Debug"},
+	}
+	if err := dotnet.NewSetter(root).SetSecrets(changed); err != nil {
+		t.Fatalf("SetSecrets failed: %v", err)
+	}
+	data, _ := os.ReadFile(secretsPath)
+	expectedJson := `{
+  "ConnectionStrings": {
+    "Default": "Server=db2",
+    "Redis": "localhost"
+  },
+  "Serilog:MinimumLevel": "Information",
+  "Items": [
+    "first",
+    {
+      "Name": "second",
+      "Port": "5433",
+      "Enabled": true
+    },
+    "third"
+  ],
+  "Empty": {},
+  "Logging:LogLevel:Default": "Debug"
+}`
+	if string(data) != expectedJson {
+		t.Errorf("secrets.json = %s, expected %s", data, expectedJson)
+	}
+}
+
+func TestRemoveSecrets_RemovesEmptySections(t *testing.T) {
+	root, _ := newTestProject(t, map[string]string{"Api.csproj": projectWithId})
+	secretsPath, _ := dotnet.SecretsPath("4d5c8a2e-1f0b-4a8e-9c3d-7b6a5e4f3d2c")
+	os.MkdirAll(filepath.Dir(secretsPath), 0700)
+	os.WriteFile(secretsPath, []byte(`{"ConnectionStrings": {"Default": "a", "Redis": "b"}, "Legacy": {"Key": "c"}, "Items": ["x", "y"], "Flat:Key": "d", "Empty": {}}`), 0600)
+
+	err := dotnet.NewSetter(root).RemoveSecrets([]string{"ConnectionStrings:Redis", "legacy:key", "Items:0", "Flat:Key", "Missing:Key"})
+	if err != nil {
+		t.Fatalf("RemoveSecrets failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(secretsPath)
+	expected := `{
+  "ConnectionStrings": {
+    "Default": "a"
+  },
+  "Items": [
+    "y"
+  ],
+  "Empty": {}
+}`
+	if string(data) != expected {
+		t.Errorf("secrets.json = %s, expected %s", data, expected)
+	}
+}
+
+func TestRemoveSecrets_SeveralItemsFromOneArray(t *testing.T) {
+	root, _ := newTestProject(t, map[string]string{"Api.csproj": projectWithId})
+	secretsPath, _ := dotnet.SecretsPath("4d5c8a2e-1f0b-4a8e-9c3d-7b6a5e4f3d2c")
+	os.MkdirAll(filepath.Dir(secretsPath), 0700)
+	os.WriteFile(secretsPath, []byte(`{"Items": ["a", "b", "c"], "Hosts": [{"Name": "x"}, {"Name": "y"}]}`), 0600)
+
+	err := dotnet.NewSetter(root).RemoveSecrets([]string{"Items:0", "Items:1", "Hosts:0:Name", "Hosts:1:Name"})
+	if err != nil {
+		t.Fatalf("RemoveSecrets failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(secretsPath)
+	expected := `{
+  "Items": [
+    "c"
+  ]
+}`
+	if string(data) != expected {
+		t.Errorf("secrets.json = %s, expected %s", data, expected)
+	}
+}
@@ -181,3 +181,37 @@ func createSecretsMap(secretsData []secrets.SecretData) map[string]string {
 
 	return secretMap
 }
+
+func removeSecrets(envFile string, keys []string) error {
+	info, err := os.Stat(envFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("RemoveSecrets - failed to open file. %w", err)
+	}
+	data, err := os.ReadFile(envFile)
+	if err != nil {
+		return fmt.Errorf("RemoveSecrets - failed to read file. %w", err)
+	}
+
+	remove := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		remove[key] = true
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	var kept strings.Builder
+	for _, line := range lines {
+		if key, _, _ := parseEnvLine(line); key != "" && remove[key] {
+			continue
+		}
+		kept.WriteString(line)
+	}
+
+	if err := os.WriteFile(envFile, []byte(kept.String()), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("RemoveSecrets - failed to write file. %w", err)
+	}
+
+	return nil
+}
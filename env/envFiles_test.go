@@ -311,3 +311,18 @@ func TestSetSecrets_NonexistentDirectory(t *testing.T) {
 		t.Error("Expected error for nonexistent directory")
 	}
 }
+
+func TestRemoveSecrets_KeepsOtherLines(t *testing.T) {
+	envFile := createTempEnvFile(t, "# database\nDB_PASSWORD=\"hunter2\"\nAPI_KEY=\"secret123\"\nMANUAL=\"mine\"\n")
+
+	setter := env.NewSetter(envFile)
+	if err := setter.RemoveSecrets([]string{"DB_PASSWORD", "MISSING"}); err != nil {
+		t.Fatalf("RemoveSecrets failed: %v", err)
+	}
+
+	content := readEnvFile(t, envFile)
+	expected := "# database\nAPI_KEY=\"secret123\"\nMANUAL=\"mine\"\n"
+	if content != expected {
+		t.Errorf("env file = %q, expected %q", content, expected)
+	}
+}
@@ -15,3 +15,8 @@ func (setter EnvSetter) SetSecrets(secrets []secrets.SecretData) error {
 
 	return err
 }
+
+// Removes the lines that set the keys from the env file, leaving comments and every other line as they are.
+func (setter EnvSetter) RemoveSecrets(keys []string) error {
+	return removeSecrets(setter.envFile, keys)
+}
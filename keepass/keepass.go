@@ -0,0 +1,360 @@
+package keepass
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chadsmith12/dotsec/secrets"
+	"github.com/tobischo/gokeepasslib/v3"
+	w "github.com/tobischo/gokeepasslib/v3/wrappers"
+)
+
+var (
+	InvalidFolderErr      = secrets.ErrFolderNotFound
+	InvalidCredentialsErr = errors.New("invalid master password or key file")
+	AmbiguousFolderErr    = errors.New("more than one group matches")
+)
+
+// KeepassDatabase is an unlocked KDBX database where each group is a folder of secrets.
+// The title of an entry is the secrets key and the password of the entry is the secrets value.
+type KeepassDatabase struct {
+	path  string
+	db    *gokeepasslib.Database
+	dirty bool
+}
+
+// Opens and unlocks the KeePass database at path with the master password and an optional key file.
+// Returns InvalidCredentialsErr if the password or key file is wrong.
+func Open(path, password, keyFile string) (*KeepassDatabase, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading database: %w", err)
+	}
+	defer file.Close()
+
+	db := gokeepasslib.NewDatabase()
+	db.Credentials, err = credentials(password, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := gokeepasslib.NewDecoder(file).Decode(db); err != nil {
+		// gokeepasslib doesn't export the errors for a wrong key, they all start by asking about the password
+		if strings.HasPrefix(err.Error(), "Wrong password?") {
+			return nil, InvalidCredentialsErr
+		}
+		return nil, fmt.Errorf("reading database: %w", err)
+	}
+	if err := db.UnlockProtectedEntries(); err != nil {
+		return nil, fmt.Errorf("reading database: %w", err)
+	}
+
+	return &KeepassDatabase{path: path, db: db}, nil
+}
+
+// Encrypts and writes the database back to the file it was opened from.
+func (db *KeepassDatabase) Save() error {
+	// the encoder expects the protected values to be locked, the same as after decoding
+	if err := db.db.LockProtectedEntries(); err != nil {
+		return fmt.Errorf("saving database: %w", err)
+	}
+	defer db.db.UnlockProtectedEntries()
+
+	tempFile, err := os.CreateTemp(filepath.Dir(db.path), ".dotsec-kdbx")
+	if err != nil {
+		return fmt.Errorf("saving database: %w", err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	if err := gokeepasslib.NewEncoder(tempFile).Encode(db.db); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("saving database: %w", err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("saving database: %w", err)
+	}
+
+	if err := os.Rename(tempFile.Name(), db.path); err != nil {
+		return fmt.Errorf("saving database: %w", err)
+	}
+
+	db.dirty = false
+	return nil
+}
+
+// Saves the database if any secrets were changed since it was opened or last saved,
+// so a push writes the file once instead of once for every secret.
+func (db *KeepassDatabase) Flush() error {
+	if !db.dirty {
+		return nil
+	}
+
+	return db.Save()
+}
+
+// Gets every group in the database.
+func (db *KeepassDatabase) ListFolders() ([]secrets.Folder, error) {
+	folders := make([]secrets.Folder, 0)
+	walkGroups(db.rootGroup(), func(group *gokeepasslib.Group) bool {
+		folders = append(folders, groupFolder(group))
+		return true
+	})
+
+	return folders, nil
+}
+
+// Finds the group with the matching name. A slash separated path can be used to find a nested group.
+// Returns AmbiguousFolderErr when the name matches more than one group and a path is needed instead.
+func (db *KeepassDatabase) GetFolder(name string) (secrets.Folder, error) {
+	group, err := db.findGroup(name)
+	if err != nil {
+		return secrets.Folder{}, err
+	}
+	if group == nil {
+		return secrets.Folder{}, InvalidFolderErr
+	}
+
+	return groupFolder(group), nil
+}
+
+// Lists the entries directly inside of the group, using the title as the secrets key.
+func (db *KeepassDatabase) ListSecrets(folder secrets.Folder) ([]secrets.SecretRef, error) {
+	group := db.groupByID(folder.ID)
+	if group == nil {
+		return nil, InvalidFolderErr
+	}
+
+	refs := make([]secrets.SecretRef, 0, len(group.Entries))
+	for i := range group.Entries {
+		refs = append(refs, secrets.SecretRef{ID: uuidString(group.Entries[i].UUID), Key: group.Entries[i].GetTitle()})
+	}
+
+	return refs, nil
+}
+
+// Reads the title and password of every entry directly inside of the group.
+func (db *KeepassDatabase) GetSecrets(folder secrets.Folder) ([]secrets.SecretData, error) {
+	group := db.groupByID(folder.ID)
+	if group == nil {
+		return []secrets.SecretData{}, InvalidFolderErr
+	}
+
+	secretData := make([]secrets.SecretData, 0, len(group.Entries))
+	for i := range group.Entries {
+		secretData = append(secretData, secrets.SecretData{Key: group.Entries[i].GetTitle(), Value: group.Entries[i].GetPassword()})
+	}
+
+	return secretData, nil
+}
+
+// Adds a new entry to the group. The database is written when it is flushed.
+func (db *KeepassDatabase) CreateSecret(folder secrets.Folder, secret secrets.SecretData) error {
+	group := db.groupByID(folder.ID)
+	if group == nil {
+		return InvalidFolderErr
+	}
+
+	entry := gokeepasslib.NewEntry(gokeepasslib.WithEntryFormattedTime(!db.db.Header.IsKdbx4()))
+	entry.Values = []gokeepasslib.ValueData{
+		{Key: "Title", Value: gokeepasslib.V{Content: secret.Key}},
+		{Key: "UserName"},
+		{Key: "Password", Value: gokeepasslib.V{Content: secret.Value, Protected: w.NewBoolWrapper(true)}},
+		{Key: "URL"},
+		{Key: "Notes"},
+	}
+	group.Entries = append(group.Entries, entry)
+	db.dirty = true
+
+	return nil
+}
+
+// Updates the password of the entry. The database is written when it is flushed.
+func (db *KeepassDatabase) UpdateSecret(folder secrets.Folder, ref secrets.SecretRef, secret secrets.SecretData) error {
+	group := db.groupByID(folder.ID)
+	if group == nil {
+		return InvalidFolderErr
+	}
+
+	index := entryIndex(group, ref.ID)
+	if index == -1 {
+		return fmt.Errorf("failed to find entry %s", ref.Key)
+	}
+	entry := &group.Entries[index]
+
+	if password := entry.Get("Password"); password != nil {
+		password.Value.Content = secret.Value
+		password.Value.Protected = w.NewBoolWrapper(true)
+	} else {
+		entry.Values = append(entry.Values, gokeepasslib.ValueData{Key: "Password", Value: gokeepasslib.V{Content: secret.Value, Protected: w.NewBoolWrapper(true)}})
+	}
+	entry.Times.LastModificationTime = db.now()
+	db.dirty = true
+
+	return nil
+}
+
+// Removes the entry from the group and records it as deleted. The database is written when it is flushed.
+func (db *KeepassDatabase) DeleteSecret(folder secrets.Folder, ref secrets.SecretRef) error {
+	group := db.groupByID(folder.ID)
+	if group == nil {
+		return InvalidFolderErr
+	}
+
+	index := entryIndex(group, ref.ID)
+	if index == -1 {
+		return fmt.Errorf("failed to find entry %s", ref.Key)
+	}
+	deleted := gokeepasslib.DeletedObjectData{UUID: group.Entries[index].UUID, DeletionTime: db.now()}
+	group.Entries = append(group.Entries[:index], group.Entries[index+1:]...)
+	db.db.Content.Root.DeletedObjects = append(db.db.Content.Root.DeletedObjects, deleted)
+	db.dirty = true
+
+	return nil
+}
+
+func (db *KeepassDatabase) rootGroup() *gokeepasslib.Group {
+	if db.db.Content == nil || db.db.Content.Root == nil || len(db.db.Content.Root.Groups) == 0 {
+		return nil
+	}
+
+	return &db.db.Content.Root.Groups[0]
+}
+
+func (db *KeepassDatabase) groupByID(id string) *gokeepasslib.Group {
+	var found *gokeepasslib.Group
+	walkGroups(db.rootGroup(), func(group *gokeepasslib.Group) bool {
+		if uuidString(group.UUID) == id {
+			found = group
+			return false
+		}
+		return true
+	})
+
+	return found
+}
+
+func (db *KeepassDatabase) findGroup(name string) (*gokeepasslib.Group, error) {
+	root := db.rootGroup()
+	if root == nil {
+		return nil, nil
+	}
+
+	if !strings.Contains(name, "/") {
+		matches := make([]*gokeepasslib.Group, 0)
+		candidates := make([]string, 0)
+		walkGroupPaths(root, nil, func(group *gokeepasslib.Group, path []string) {
+			if strings.EqualFold(group.Name, name) {
+				matches = append(matches, group)
+				candidates = append(candidates, strings.Join(path, "/"))
+			}
+		})
+
+		switch len(matches) {
+		case 0:
+			return nil, nil
+		case 1:
+			return matches[0], nil
+		}
+		return nil, fmt.Errorf("%w %s, use the path of the group instead: %s", AmbiguousFolderErr, name, strings.Join(candidates, ", "))
+	}
+
+	parts := strings.Split(strings.Trim(name, "/"), "/")
+	if strings.EqualFold(parts[0], root.Name) {
+		parts = parts[1:]
+	}
+
+	current := root
+	for _, part := range parts {
+		var next *gokeepasslib.Group
+		for i := range current.Groups {
+			if strings.EqualFold(current.Groups[i].Name, part) {
+				next = &current.Groups[i]
+				break
+			}
+		}
+		if next == nil {
+			return nil, nil
+		}
+		current = next
+	}
+
+	return current, nil
+}
+
+// The current time, formatted the way the database's KDBX version stores times.
+func (db *KeepassDatabase) now() *w.TimeWrapper {
+	now := w.Now()
+	now.Formatted = !db.db.Header.IsKdbx4()
+
+	return &now
+}
+
+// Walks every group depth first until visit returns false.
+func walkGroups(group *gokeepasslib.Group, visit func(*gokeepasslib.Group) bool) bool {
+	if group == nil {
+		return true
+	}
+	if !visit(group) {
+		return false
+	}
+	for i := range group.Groups {
+		if !walkGroups(&group.Groups[i], visit) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Visits every group depth first along with the names of the groups leading to it, starting at the root.
+func walkGroupPaths(group *gokeepasslib.Group, parents []string, visit func(*gokeepasslib.Group, []string)) {
+	path := append(append([]string{}, parents...), group.Name)
+	visit(group, path)
+	for i := range group.Groups {
+		walkGroupPaths(&group.Groups[i], path, visit)
+	}
+}
+
+func entryIndex(group *gokeepasslib.Group, id string) int {
+	for i := range group.Entries {
+		if uuidString(group.Entries[i].UUID) == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func groupFolder(group *gokeepasslib.Group) secrets.Folder {
+	return secrets.Folder{ID: uuidString(group.UUID), Name: group.Name}
+}
+
+// The base64 encoded UUID, the same as it is written in the database.
+func uuidString(id gokeepasslib.UUID) string {
+	text, _ := id.MarshalText()
+	return string(text)
+}
+
+// Builds the composite key from the master password and key file. The password is left out when it is empty
+// and there is a key file, which is how KeePass opens a database protected only by a key file.
+func credentials(password, keyFile string) (*gokeepasslib.DBCredentials, error) {
+	if keyFile == "" {
+		return gokeepasslib.NewPasswordCredentials(password), nil
+	}
+
+	var creds *gokeepasslib.DBCredentials
+	var err error
+	if password == "" {
+		creds, err = gokeepasslib.NewKeyCredentials(keyFile)
+	} else {
+		creds, err = gokeepasslib.NewPasswordAndKeyCredentials(password, keyFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading key file: %w", err)
+	}
+
+	return creds, nil
+}
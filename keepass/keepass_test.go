@@ -0,0 +1,218 @@
+package keepass
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chadsmith12/dotsec/secrets"
+	"github.com/tobischo/gokeepasslib/v3"
+	w "github.com/tobischo/gokeepasslib/v3/wrappers"
+)
+
+// Creates a database protected by the credentials that has a Dev group with a single API_KEY entry.
+func createTestDatabase(t *testing.T, credentials *gokeepasslib.DBCredentials, options ...gokeepasslib.DatabaseOption) string {
+	t.Helper()
+	entry := gokeepasslib.NewEntry()
+	entry.Values = []gokeepasslib.ValueData{
+		{Key: "Title", Value: gokeepasslib.V{Content: "API_KEY"}},
+		{Key: "UserName", Value: gokeepasslib.V{Content: "api"}},
+		{Key: "Password", Value: gokeepasslib.V{Content: "secret123", Protected: w.NewBoolWrapper(true)}},
+	}
+	dev := gokeepasslib.NewGroup()
+	dev.Name = "Dev"
+	dev.Entries = []gokeepasslib.Entry{entry}
+	root := gokeepasslib.NewGroup()
+	root.Name = "Root"
+	root.Groups = []gokeepasslib.Group{dev}
+
+	db := gokeepasslib.NewDatabase(options...)
+	db.Credentials = credentials
+	db.Content.Meta.Generator = "KeePassXC"
+	db.Content.Root = &gokeepasslib.RootData{Groups: []gokeepasslib.Group{root}}
+	if err := db.LockProtectedEntries(); err != nil {
+		t.Fatalf("Failed to lock entries: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "team.kdbx")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer file.Close()
+	if err := gokeepasslib.NewEncoder(file).Encode(db); err != nil {
+		t.Fatalf("Failed to write test database: %v", err)
+	}
+
+	return path
+}
+
+func TestOpen_ReadsSecretsFromGroup(t *testing.T) {
+	testCases := []struct {
+		name    string
+		options []gokeepasslib.DatabaseOption
+	}{
+		{name: "KDBX 4", options: []gokeepasslib.DatabaseOption{gokeepasslib.WithDatabaseKDBXVersion4()}},
+		{name: "KDBX 3.1", options: []gokeepasslib.DatabaseOption{gokeepasslib.WithDatabaseKDBXVersion3()}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			path := createTestDatabase(t, gokeepasslib.NewPasswordCredentials("master"), testCase.options...)
+			db, err := Open(path, "master", "")
+			if err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+
+			actual, err := secrets.GetSecretsByFolder(db, "dev")
+			if err != nil {
+				t.Fatalf("GetSecretsByFolder failed: %v", err)
+			}
+
+			expected := []secrets.SecretData{{Key: "API_KEY", Value: "secret123"}}
+			if len(actual) != 1 || actual[0] != expected[0] {
+				t.Errorf("GetSecretsByFolder() = %v, expected %v", actual, expected)
+			}
+		})
+	}
+}
+
+func TestOpen_KeyFile(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "team.keyx")
+	if err := os.WriteFile(keyFile, []byte("0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"), 0600); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+	credentials, err := gokeepasslib.NewPasswordAndKeyCredentials("master", keyFile)
+	if err != nil {
+		t.Fatalf("Failed to read key file: %v", err)
+	}
+	path := createTestDatabase(t, credentials, gokeepasslib.WithDatabaseKDBXVersion4())
+
+	if _, err := Open(path, "master", ""); !errors.Is(err, InvalidCredentialsErr) {
+		t.Errorf("Open() without the key file error = %v, expected %v", err, InvalidCredentialsErr)
+	}
+	db, err := Open(path, "master", keyFile)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if actual, _ := secrets.GetSecretsByFolder(db, "Dev"); len(actual) != 1 || actual[0].Value != "secret123" {
+		t.Errorf("GetSecretsByFolder() = %v, expected API_KEY", actual)
+	}
+}
+
+func TestOpen_WrongPassword(t *testing.T) {
+	path := createTestDatabase(t, gokeepasslib.NewPasswordCredentials("master"), gokeepasslib.WithDatabaseKDBXVersion4())
+
+	_, err := Open(path, "wrong", "")
+	if !errors.Is(err, InvalidCredentialsErr) {
+		t.Errorf("Open() error = %v, expected %v", err, InvalidCredentialsErr)
+	}
+}
+
+func TestPush_WritesEntriesBackToDatabase(t *testing.T) {
+	path := createTestDatabase(t, gokeepasslib.NewPasswordCredentials("master"), gokeepasslib.WithDatabaseKDBXVersion4())
+	db, err := Open(path, "master", "")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	folder, err := db.GetFolder("Root/Dev")
+	if err != nil {
+		t.Fatalf("GetFolder failed: %v", err)
+	}
+	refs, err := db.ListSecrets(folder)
+	if err != nil {
+		t.Fatalf("ListSecrets failed: %v", err)
+	}
+
+	if err := db.UpdateSecret(folder, refs[0], secrets.SecretData{Key: "API_KEY", Value: "rotated"}); err != nil {
+		t.Fatalf("UpdateSecret failed: %v", err)
+	}
+	if err := db.CreateSecret(folder, secrets.SecretData{Key: "DB_PASSWORD", Value: "hunter2"}); err != nil {
+		t.Fatalf("CreateSecret failed: %v", err)
+	}
+
+	unflushed, err := Open(path, "master", "")
+	if err != nil {
+		t.Fatalf("Open before flush failed: %v", err)
+	}
+	if actual, _ := unflushed.GetSecrets(folder); len(actual) != 1 || actual[0].Value != "secret123" {
+		t.Errorf("the database should not be written until it is flushed, got %v", actual)
+	}
+	if err := db.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	reopened, err := Open(path, "master", "")
+	if err != nil {
+		t.Fatalf("Open after save failed: %v", err)
+	}
+	actual, err := secrets.GetSecretsByFolder(reopened, "Dev")
+	if err != nil {
+		t.Fatalf("GetSecretsByFolder failed: %v", err)
+	}
+
+	expected := []secrets.SecretData{{Key: "API_KEY", Value: "rotated"}, {Key: "DB_PASSWORD", Value: "hunter2"}}
+	if len(actual) != len(expected) || actual[0] != expected[0] || actual[1] != expected[1] {
+		t.Errorf("GetSecretsByFolder() = %v, expected %v", actual, expected)
+	}
+
+	if name := reopened.db.Content.Meta.Generator; name != "KeePassXC" {
+		t.Errorf("Meta should be preserved, got generator %q", name)
+	}
+	if username := reopened.groupByID(folder.ID).Entries[0].GetContent("UserName"); username != "api" {
+		t.Errorf("the other fields of an updated entry should be kept, got username %q", username)
+	}
+
+	refs, _ = reopened.ListSecrets(folder)
+	if err := reopened.DeleteSecret(folder, refs[0]); err != nil {
+		t.Fatalf("DeleteSecret failed: %v", err)
+	}
+	if err := reopened.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	reopened, err = Open(path, "master", "")
+	if err != nil {
+		t.Fatalf("Open after delete failed: %v", err)
+	}
+	actual, _ = reopened.GetSecrets(folder)
+	if len(actual) != 1 || actual[0].Key != "DB_PASSWORD" {
+		t.Errorf("GetSecrets() after delete = %v, expected only DB_PASSWORD", actual)
+	}
+	if deleted := reopened.db.Content.Root.DeletedObjects; len(deleted) != 1 || uuidString(deleted[0].UUID) != refs[0].ID {
+		t.Errorf("the deleted entry should be recorded, got %v", deleted)
+	}
+}
+
+func TestGetFolder_AmbiguousName(t *testing.T) {
+	path := createTestDatabase(t, gokeepasslib.NewPasswordCredentials("master"))
+	db, err := Open(path, "master", "")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	staging := gokeepasslib.NewGroup()
+	staging.Name = "Staging"
+	dev := gokeepasslib.NewGroup()
+	dev.Name = "Dev"
+	staging.Groups = []gokeepasslib.Group{dev}
+	root := db.rootGroup()
+	root.Groups = append(root.Groups, staging)
+
+	_, err = db.GetFolder("Dev")
+	if !errors.Is(err, AmbiguousFolderErr) {
+		t.Fatalf("GetFolder() error = %v, expected AmbiguousFolderErr", err)
+	}
+	if !strings.Contains(err.Error(), "Root/Dev, Root/Staging/Dev") {
+		t.Errorf("the error should list the paths of the matching groups, got %q", err)
+	}
+
+	folder, err := db.GetFolder("Staging/Dev")
+	if err != nil {
+		t.Fatalf("GetFolder() with a path failed: %v", err)
+	}
+	if folder.ID != uuidString(dev.UUID) {
+		t.Errorf("GetFolder() = %v, expected the nested Dev group", folder)
+	}
+}
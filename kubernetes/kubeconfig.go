@@ -0,0 +1,44 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	// registers the auth-provider plugins kubectl supports, such as oidc
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+)
+
+// KubeConfig is the cluster, user and namespace of a kubeconfig context, which is everything needed to talk to its API server.
+type KubeConfig struct {
+	Server    string
+	Namespace string
+	rest      *rest.Config
+}
+
+// Loads the context from the kubeconfig files at path, or KUBECONFIG, or ~/.kube/config, with the same loader kubectl uses.
+// KUBECONFIG can list several files and the first file to define a name wins, and inside of a pod without a kubeconfig
+// the pods service account is used. An empty contextName uses the current-context.
+func LoadKubeConfig(path, contextName string) (*KubeConfig, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = path
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: contextName}
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
+
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("loading kubeconfig: %w", err)
+	}
+	namespace, _, err := clientConfig.Namespace()
+	if err != nil {
+		return nil, fmt.Errorf("loading kubeconfig: %w", err)
+	}
+
+	return &KubeConfig{
+		Server:    strings.TrimRight(restConfig.Host, "/"),
+		Namespace: namespace,
+		rest:      restConfig,
+	}, nil
+}
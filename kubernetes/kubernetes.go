@@ -0,0 +1,237 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/chadsmith12/dotsec/secrets"
+	"k8s.io/client-go/rest"
+)
+
+const opaqueType = "Opaque"
+
+var (
+	InvalidFolderErr = secrets.ErrFolderNotFound
+)
+
+// KubernetesApi reads and writes Kubernetes Secret objects through the API server. A namespace/name is the folder,
+// and each key in the secrets data is a SecretData. A name without a namespace uses the namespace of the kubeconfig context.
+type KubernetesApi struct {
+	config     *KubeConfig
+	httpClient *http.Client
+	context    context.Context
+}
+
+type kubeSecret struct {
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+	Type string            `json:"type"`
+	Data map[string]string `json:"data"`
+}
+
+type statusError struct {
+	status  int
+	message string
+}
+
+func (err statusError) Error() string {
+	if err.message == "" {
+		return fmt.Sprintf("kubernetes returned status %d", err.status)
+	}
+
+	return fmt.Sprintf("kubernetes returned status %d: %s", err.status, err.message)
+}
+
+// Initializes a new Kubernetes Api for the cluster and user in the kubeconfig context.
+// client-go's transport handles the certificates, tokens, exec plugins and proxy of the context.
+func NewClient(ctx context.Context, config *KubeConfig) (*KubernetesApi, error) {
+	httpClient, err := rest.HTTPClientFor(config.rest)
+	if err != nil {
+		return nil, fmt.Errorf("getting credentials: %w", err)
+	}
+
+	return &KubernetesApi{
+		config:     config,
+		httpClient: httpClient,
+		context:    ctx,
+	}, nil
+}
+
+// Lists the Opaque secrets in the contexts namespace. Service account tokens, TLS secrets and
+// helm releases are left out since they aren't app configuration.
+func (client *KubernetesApi) ListFolders() ([]secrets.Folder, error) {
+	var list struct {
+		Items []kubeSecret `json:"items"`
+	}
+	if err := client.do(http.MethodGet, fmt.Sprintf("/api/v1/namespaces/%s/secrets", url.PathEscape(client.config.Namespace)), "", nil, &list); err != nil {
+		return nil, err
+	}
+
+	folders := make([]secrets.Folder, 0, len(list.Items))
+	for _, secret := range list.Items {
+		if secret.Type != opaqueType && secret.Type != "" {
+			continue
+		}
+		name := secret.Metadata.Namespace + "/" + secret.Metadata.Name
+		folders = append(folders, secrets.Folder{ID: name, Name: name})
+	}
+
+	return folders, nil
+}
+
+// Finds the secret by namespace/name, or by name in the contexts namespace.
+func (client *KubernetesApi) GetFolder(name string) (secrets.Folder, error) {
+	namespace, secretName, found := strings.Cut(strings.Trim(name, "/"), "/")
+	if !found {
+		namespace, secretName = client.config.Namespace, namespace
+	}
+	if secretName == "" || strings.Contains(secretName, "/") {
+		return secrets.Folder{}, fmt.Errorf("%w: %s should be namespace/name", InvalidFolderErr, name)
+	}
+
+	folder := secrets.Folder{ID: namespace + "/" + secretName, Name: name}
+	if _, err := client.readSecret(folder); err != nil {
+		return secrets.Folder{}, err
+	}
+
+	return folder, nil
+}
+
+func (client *KubernetesApi) ListSecrets(folder secrets.Folder) ([]secrets.SecretRef, error) {
+	secret, err := client.readSecret(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := sortedKeys(secret.Data)
+	refs := make([]secrets.SecretRef, 0, len(keys))
+	for _, key := range keys {
+		refs = append(refs, secrets.SecretRef{ID: key, Key: key})
+	}
+
+	return refs, nil
+}
+
+// Reads and decodes every key in the secrets data.
+func (client *KubernetesApi) GetSecrets(folder secrets.Folder) ([]secrets.SecretData, error) {
+	secret, err := client.readSecret(folder)
+	if err != nil {
+		return []secrets.SecretData{}, err
+	}
+
+	secretData := make([]secrets.SecretData, 0, len(secret.Data))
+	var errs secrets.SecretErrors
+	for _, key := range sortedKeys(secret.Data) {
+		value, err := base64.StdEncoding.DecodeString(secret.Data[key])
+		if err != nil {
+			errs = append(errs, secrets.SecretError{Key: key, Operation: "read", Err: fmt.Errorf("decoding: %w", err)})
+			continue
+		}
+		secretData = append(secretData, secrets.SecretData{Key: key, Value: string(value)})
+	}
+
+	return secretData, errs.Err()
+}
+
+func (client *KubernetesApi) CreateSecret(folder secrets.Folder, secret secrets.SecretData) error {
+	return client.patchData(folder, secret.Key, base64.StdEncoding.EncodeToString([]byte(secret.Value)))
+}
+
+func (client *KubernetesApi) UpdateSecret(folder secrets.Folder, ref secrets.SecretRef, secret secrets.SecretData) error {
+	return client.patchData(folder, ref.ID, base64.StdEncoding.EncodeToString([]byte(secret.Value)))
+}
+
+func (client *KubernetesApi) DeleteSecret(folder secrets.Folder, ref secrets.SecretRef) error {
+	return client.patchData(folder, ref.ID, nil)
+}
+
+// Changes a single key with a json merge patch, so keys changed by someone else at the same time are kept.
+// A nil value removes the key.
+func (client *KubernetesApi) patchData(folder secrets.Folder, key string, value any) error {
+	patch := map[string]any{"data": map[string]any{key: value}}
+	return client.do(http.MethodPatch, secretPath(folder), "application/merge-patch+json", patch, nil)
+}
+
+func (client *KubernetesApi) readSecret(folder secrets.Folder) (kubeSecret, error) {
+	var secret kubeSecret
+	if err := client.do(http.MethodGet, secretPath(folder), "", nil, &secret); err != nil {
+		var statusErr statusError
+		if errors.As(err, &statusErr) && statusErr.status == http.StatusNotFound {
+			return kubeSecret{}, InvalidFolderErr
+		}
+		return kubeSecret{}, err
+	}
+
+	return secret, nil
+}
+
+func secretPath(folder secrets.Folder) string {
+	namespace, name, _ := strings.Cut(folder.ID, "/")
+	return fmt.Sprintf("/api/v1/namespaces/%s/secrets/%s", url.PathEscape(namespace), url.PathEscape(name))
+}
+
+func (client *KubernetesApi) do(method, path, contentType string, body any, result any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	request, err := http.NewRequestWithContext(client.context, method, client.config.Server+path, reader)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+
+	response, err := client.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode >= 300 {
+		var status struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(data, &status)
+		return statusError{status: response.StatusCode, message: status.Message}
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(data, result)
+}
+
+func sortedKeys(data map[string]string) []string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
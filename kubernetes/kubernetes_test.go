@@ -0,0 +1,422 @@
+package kubernetes_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chadsmith12/dotsec/kubernetes"
+	"github.com/chadsmith12/dotsec/secrets"
+)
+
+const (
+	bearerToken = "token"
+	// the client certificate and key files the fake exec plugin hands to client-go
+	fakeExecEnv = "DOTSEC_FAKE_EXEC_CREDENTIAL"
+)
+
+// When the test binary is started with the fake exec variable it acts as an exec credential plugin instead of running the tests.
+func TestMain(m *testing.M) {
+	if paths := os.Getenv(fakeExecEnv); paths != "" {
+		os.Exit(fakeExecPlugin(strings.Split(paths, string(os.PathListSeparator))))
+	}
+
+	os.Exit(m.Run())
+}
+
+// Prints an ExecCredential with client certificate data, the way plugins like the ones for kind or Teleport do.
+func fakeExecPlugin(paths []string) int {
+	certificate, err := os.ReadFile(paths[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	key, err := os.ReadFile(paths[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	json.NewEncoder(os.Stdout).Encode(map[string]any{
+		"apiVersion": "client.authentication.k8s.io/v1",
+		"kind":       "ExecCredential",
+		"status":     map[string]string{"clientCertificateData": string(certificate), "clientKeyData": string(key)},
+	})
+	return 0
+}
+
+// fakeApiServer serves the secrets endpoints of the core v1 api, the way envtest or kind would.
+type fakeApiServer struct {
+	mu      sync.Mutex
+	secrets map[string]map[string]any
+}
+
+func newFakeApiServer(t *testing.T) (*fakeApiServer, *httptest.Server) {
+	t.Helper()
+	fake := &fakeApiServer{secrets: map[string]map[string]any{}}
+	server := httptest.NewUnstartedServer(fake)
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return fake, server
+}
+
+func (fake *fakeApiServer) add(namespace, name, secretType string, data map[string]string) {
+	encoded := map[string]any{}
+	for key, value := range data {
+		encoded[key] = base64.StdEncoding.EncodeToString([]byte(value))
+	}
+	fake.secrets[namespace+"/"+name] = map[string]any{
+		"metadata": map[string]string{"name": name, "namespace": namespace},
+		"type":     secretType,
+		"data":     encoded,
+	}
+}
+
+func (fake *fakeApiServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	hasCertificate := r.TLS != nil && len(r.TLS.PeerCertificates) > 0 && r.TLS.PeerCertificates[0].Subject.CommonName == "developer"
+	if r.Header.Get("Authorization") != "Bearer "+bearerToken && !hasCertificate {
+		writeStatus(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/namespaces/"), "/")
+	if len(parts) == 2 && parts[1] == "secrets" {
+		items := make([]any, 0)
+		for key, secret := range fake.secrets {
+			if strings.HasPrefix(key, parts[0]+"/") {
+				items = append(items, secret)
+			}
+		}
+		writeJson(w, http.StatusOK, map[string]any{"kind": "SecretList", "items": items})
+		return
+	}
+	if len(parts) != 3 || parts[1] != "secrets" {
+		writeStatus(w, http.StatusNotFound, "the server could not find the requested resource")
+		return
+	}
+
+	secret, found := fake.secrets[parts[0]+"/"+parts[2]]
+	if !found {
+		writeStatus(w, http.StatusNotFound, `secrets "`+parts[2]+`" not found`)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJson(w, http.StatusOK, secret)
+	case http.MethodPatch:
+		if r.Header.Get("Content-Type") != "application/merge-patch+json" {
+			writeStatus(w, http.StatusUnsupportedMediaType, "unsupported patch type")
+			return
+		}
+		var patch struct {
+			Data map[string]any `json:"data"`
+		}
+		json.NewDecoder(r.Body).Decode(&patch)
+		data := secret["data"].(map[string]any)
+		for key, value := range patch.Data {
+			if value == nil {
+				delete(data, key)
+			} else {
+				data[key] = value
+			}
+		}
+		writeJson(w, http.StatusOK, secret)
+	}
+}
+
+func writeJson(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeStatus(w http.ResponseWriter, status int, message string) {
+	writeJson(w, status, map[string]any{"kind": "Status", "status": "Failure", "message": message, "code": status})
+}
+
+// Writes a kubeconfig that trusts the fake api servers certificate and logs in with a token from a file.
+func writeKubeConfig(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+	dir := t.TempDir()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(filepath.Join(dir, "token"), []byte(bearerToken+"\n"), 0600); err != nil {
+		t.Fatalf("Failed to write token: %v", err)
+	}
+
+	config := `apiVersion: v1
+kind: Config
+current-context: kind-dev
+clusters:
+- name: kind-dev
+  cluster:
+    server: ` + server.URL + `
+    certificate-authority-data: ` + base64.StdEncoding.EncodeToString(ca) + `
+contexts:
+- name: kind-dev
+  context:
+    cluster: kind-dev
+    user: developer
+    namespace: myapp
+- name: other
+  context:
+    cluster: missing
+    user: developer
+users:
+- name: developer
+  user:
+    tokenFile: token
+`
+	path := filepath.Join(dir, "config")
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatalf("Failed to write kubeconfig: %v", err)
+	}
+
+	return path
+}
+
+// Writes a kubeconfig whose user runs the test binary as an exec plugin from a path relative to the kubeconfig.
+func writeExecKubeConfig(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+	dir := t.TempDir()
+	certificate, key := clientCertificate(t)
+	certificatePath := filepath.Join(dir, "client.crt")
+	keyPath := filepath.Join(dir, "client.key")
+	os.WriteFile(certificatePath, certificate, 0600)
+	os.WriteFile(keyPath, key, 0600)
+
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatalf("Failed to find the test binary: %v", err)
+	}
+	os.Mkdir(filepath.Join(dir, "bin"), 0700)
+	if err := os.Symlink(executable, filepath.Join(dir, "bin", "get-credential")); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	config := `apiVersion: v1
+kind: Config
+current-context: kind-dev
+clusters:
+- name: kind-dev
+  cluster:
+    server: ` + server.URL + `
+    certificate-authority-data: ` + base64.StdEncoding.EncodeToString(ca) + `
+contexts:
+- name: kind-dev
+  context:
+    cluster: kind-dev
+    user: developer
+users:
+- name: developer
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1
+      command: ./bin/get-credential
+      interactiveMode: Never
+      env:
+      - name: ` + fakeExecEnv + `
+        value: "` + certificatePath + string(os.PathListSeparator) + keyPath + `"
+`
+	path := filepath.Join(dir, "config")
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatalf("Failed to write kubeconfig: %v", err)
+	}
+
+	return path
+}
+
+// A self signed client certificate for the developer user.
+func clientCertificate(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "developer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to encode key: %v", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func newTestClient(t *testing.T, server *httptest.Server) *kubernetes.KubernetesApi {
+	t.Helper()
+	config, err := kubernetes.LoadKubeConfig(writeKubeConfig(t, server), "")
+	if err != nil {
+		t.Fatalf("LoadKubeConfig failed: %v", err)
+	}
+	client, err := kubernetes.NewClient(context.Background(), config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	return client
+}
+
+func TestLoadKubeConfig(t *testing.T) {
+	_, server := newFakeApiServer(t)
+	path := writeKubeConfig(t, server)
+
+	config, err := kubernetes.LoadKubeConfig(path, "")
+	if err != nil {
+		t.Fatalf("LoadKubeConfig failed: %v", err)
+	}
+	if config.Server != server.URL || config.Namespace != "myapp" {
+		t.Errorf("LoadKubeConfig() = %s in %s, expected %s in myapp", config.Server, config.Namespace, server.URL)
+	}
+
+	if _, err := kubernetes.LoadKubeConfig(path, "other"); err == nil {
+		t.Error("LoadKubeConfig should fail for a context without a cluster")
+	}
+}
+
+func TestGetSecrets_DecodesData(t *testing.T) {
+	fake, server := newFakeApiServer(t)
+	fake.add("myapp", "api-secrets", "Opaque", map[string]string{"API_KEY": "secret123", "DB_PASSWORD": "hunter2"})
+	client := newTestClient(t, server)
+
+	for _, folderName := range []string{"myapp/api-secrets", "api-secrets"} {
+		actual, err := secrets.GetSecretsByFolder(client, folderName)
+		if err != nil {
+			t.Fatalf("GetSecretsByFolder(%s) failed: %v", folderName, err)
+		}
+
+		expected := []secrets.SecretData{{Key: "API_KEY", Value: "secret123"}, {Key: "DB_PASSWORD", Value: "hunter2"}}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("GetSecretsByFolder(%s) = %v, expected %v", folderName, actual, expected)
+		}
+	}
+
+	if _, err := client.GetFolder("other/api-secrets"); !errors.Is(err, kubernetes.InvalidFolderErr) {
+		t.Errorf("GetFolder() error = %v, expected %v", err, kubernetes.InvalidFolderErr)
+	}
+}
+
+func TestListFolders_OnlyOpaqueSecrets(t *testing.T) {
+	fake, server := newFakeApiServer(t)
+	fake.add("myapp", "api-secrets", "Opaque", map[string]string{})
+	fake.add("myapp", "sh.helm.release.v1.api.v1", "helm.sh/release.v1", map[string]string{})
+	fake.add("other", "web-secrets", "Opaque", map[string]string{})
+	client := newTestClient(t, server)
+
+	folders, err := client.ListFolders()
+	if err != nil {
+		t.Fatalf("ListFolders failed: %v", err)
+	}
+
+	expected := []secrets.Folder{{ID: "myapp/api-secrets", Name: "myapp/api-secrets"}}
+	if !reflect.DeepEqual(folders, expected) {
+		t.Errorf("ListFolders() = %v, expected %v", folders, expected)
+	}
+}
+
+func TestPush_PatchesKeys(t *testing.T) {
+	fake, server := newFakeApiServer(t)
+	fake.add("myapp", "api-secrets", "Opaque", map[string]string{"API_KEY": "old", "STALE": "value"})
+	client := newTestClient(t, server)
+
+	folder, err := client.GetFolder("myapp/api-secrets")
+	if err != nil {
+		t.Fatalf("GetFolder failed: %v", err)
+	}
+	refs, err := client.ListSecrets(folder)
+	if err != nil {
+		t.Fatalf("ListSecrets failed: %v", err)
+	}
+	apiKey, _ := secrets.FindSecretRef(refs, "API_KEY")
+	stale, _ := secrets.FindSecretRef(refs, "STALE")
+
+	if err := client.UpdateSecret(folder, apiKey, secrets.SecretData{Key: "API_KEY", Value: "new"}); err != nil {
+		t.Fatalf("UpdateSecret failed: %v", err)
+	}
+	if err := client.CreateSecret(folder, secrets.SecretData{Key: "DB_PASSWORD", Value: "hunter2"}); err != nil {
+		t.Fatalf("CreateSecret failed: %v", err)
+	}
+	if err := client.DeleteSecret(folder, stale); err != nil {
+		t.Fatalf("DeleteSecret failed: %v", err)
+	}
+
+	actual, _ := client.GetSecrets(folder)
+	expected := []secrets.SecretData{{Key: "API_KEY", Value: "new"}, {Key: "DB_PASSWORD", Value: "hunter2"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("GetSecrets() = %v, expected %v", actual, expected)
+	}
+}
+
+func TestGetSecrets_ReturnsTheKeysThatDecode(t *testing.T) {
+	fake, server := newFakeApiServer(t)
+	fake.add("myapp", "api-secrets", "Opaque", map[string]string{"API_KEY": "secret123"})
+	fake.secrets["myapp/api-secrets"]["data"].(map[string]any)["BROKEN"] = "not base64!"
+	client := newTestClient(t, server)
+
+	actual, err := secrets.GetSecretsByFolder(client, "myapp/api-secrets")
+	var failures secrets.SecretErrors
+	if !errors.As(err, &failures) || len(failures) != 1 || failures[0].Key != "BROKEN" || failures[0].Operation != "read" {
+		t.Fatalf("GetSecretsByFolder() error = %v, expected a read failure for BROKEN", err)
+	}
+	expected := []secrets.SecretData{{Key: "API_KEY", Value: "secret123"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("GetSecretsByFolder() = %v, expected %v", actual, expected)
+	}
+}
+
+func TestNewClient_ExecPluginClientCertificate(t *testing.T) {
+	fake, server := newFakeApiServer(t)
+	fake.add("default", "api-secrets", "Opaque", map[string]string{"API_KEY": "secret123"})
+
+	config, err := kubernetes.LoadKubeConfig(writeExecKubeConfig(t, server), "")
+	if err != nil {
+		t.Fatalf("LoadKubeConfig failed: %v", err)
+	}
+	client, err := kubernetes.NewClient(context.Background(), config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	actual, err := secrets.GetSecretsByFolder(client, "api-secrets")
+	if err != nil {
+		t.Fatalf("GetSecretsByFolder failed: %v", err)
+	}
+	expected := []secrets.SecretData{{Key: "API_KEY", Value: "secret123"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("GetSecretsByFolder() = %v, expected %v", actual, expected)
+	}
+}
@@ -0,0 +1,320 @@
+package onepassword
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/chadsmith12/dotsec/secrets"
+)
+
+const passwordCategory = "PASSWORD"
+
+var (
+	InvalidFolderErr = secrets.ErrFolderNotFound
+	NotSignedInErr   = errors.New("not signed in to 1Password - run op signin or set OP_SERVICE_ACCOUNT_TOKEN")
+)
+
+// OnePasswordCli reads and writes 1Password items by shelling out to the op CLI.
+// A vault is used as the folder, optionally narrowed down to the items with a tag by naming the folder vault/tag.
+// Each items title is the secrets key and its password field is the value.
+type OnePasswordCli struct {
+	binary string
+	env    []string
+}
+
+type opVault struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type opItem struct {
+	Id     string    `json:"id"`
+	Title  string    `json:"title"`
+	Tags   []string  `json:"tags"`
+	Fields []opField `json:"fields"`
+}
+
+type opField struct {
+	Id      string `json:"id"`
+	Label   string `json:"label"`
+	Purpose string `json:"purpose"`
+	Value   string `json:"value"`
+}
+
+// Initializes a OnePasswordCli that runs the op binary, which is looked up on the PATH when it is not a path.
+func NewClient(binary string) *OnePasswordCli {
+	if binary == "" {
+		binary = "op"
+	}
+
+	return &OnePasswordCli{binary: binary}
+}
+
+// Uses the session token from op signin --raw for the account instead of the session in the environment.
+func (client *OnePasswordCli) UseSession(account, token string) {
+	client.env = append(client.env, "OP_SESSION_"+account+"="+token)
+}
+
+// Checks that op is signed in, either with a session, the desktop app integration or a service account.
+func (client *OnePasswordCli) CheckSignedIn() error {
+	if _, err := client.run(nil, "whoami"); err != nil {
+		return fmt.Errorf("%w: %w", NotSignedInErr, err)
+	}
+
+	return nil
+}
+
+// Lists every vault, and every vault/tag pair for the tags used by items in the vaults.
+func (client *OnePasswordCli) ListFolders() ([]secrets.Folder, error) {
+	vaults, err := client.vaults()
+	if err != nil {
+		return nil, err
+	}
+
+	folders := make([]secrets.Folder, 0, len(vaults))
+	for _, vault := range vaults {
+		folders = append(folders, secrets.Folder{ID: vault.Id, Name: vault.Name})
+
+		var items []opItem
+		if err := client.runJson(&items, nil, "item", "list", "--vault", vault.Id); err != nil {
+			return nil, err
+		}
+		tags := map[string]bool{}
+		for _, item := range items {
+			for _, tag := range item.Tags {
+				tags[tag] = true
+			}
+		}
+		tagNames := make([]string, 0, len(tags))
+		for tag := range tags {
+			tagNames = append(tagNames, tag)
+		}
+		sort.Strings(tagNames)
+		for _, tag := range tagNames {
+			folders = append(folders, secrets.Folder{ID: vault.Id + "/" + tag, Name: vault.Name + "/" + tag})
+		}
+	}
+
+	return folders, nil
+}
+
+// Finds the vault by name or id. Anything after the first slash is used as a tag to filter the items by.
+func (client *OnePasswordCli) GetFolder(name string) (secrets.Folder, error) {
+	vaultName, tag, hasTag := strings.Cut(name, "/")
+	vaults, err := client.vaults()
+	if err != nil {
+		return secrets.Folder{}, err
+	}
+
+	for _, vault := range vaults {
+		if vault.Name != vaultName && vault.Id != vaultName {
+			continue
+		}
+		if hasTag {
+			return secrets.Folder{ID: vault.Id + "/" + tag, Name: name}, nil
+		}
+		return secrets.Folder{ID: vault.Id, Name: name}, nil
+	}
+
+	return secrets.Folder{}, InvalidFolderErr
+}
+
+func (client *OnePasswordCli) ListSecrets(folder secrets.Folder) ([]secrets.SecretRef, error) {
+	items, err := client.items(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]secrets.SecretRef, 0, len(items))
+	for _, item := range items {
+		refs = append(refs, secrets.SecretRef{ID: item.Id, Key: item.Title})
+	}
+
+	return refs, nil
+}
+
+func (client *OnePasswordCli) GetSecrets(folder secrets.Folder) ([]secrets.SecretData, error) {
+	items, err := client.items(folder)
+	if err != nil {
+		return []secrets.SecretData{}, err
+	}
+
+	secretData := make([]secrets.SecretData, 0, len(items))
+	for _, item := range items {
+		secretData = append(secretData, secrets.SecretData{Key: item.Title, Value: passwordField(item).Value})
+	}
+
+	return secretData, nil
+}
+
+// Creates a Password item, tagged with the folders tag when it has one. The item is piped to op as a template,
+// which keeps the password out of the process list.
+func (client *OnePasswordCli) CreateSecret(folder secrets.Folder, secret secrets.SecretData) error {
+	vaultId, tag, hasTag := strings.Cut(folder.ID, "/")
+	item := map[string]any{
+		"title":    secret.Key,
+		"category": passwordCategory,
+		"fields": []map[string]any{
+			{"id": "password", "type": "CONCEALED", "purpose": "PASSWORD", "label": "password", "value": secret.Value},
+		},
+	}
+	if hasTag {
+		item["tags"] = []string{tag}
+	}
+	template, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("encoding item: %w", err)
+	}
+
+	_, err = client.run(template, "item", "create", "--vault", vaultId, "--template", "-")
+	return err
+}
+
+// Sets the items password field, leaving the rest of the item alone. The edited item is piped to op as a template.
+func (client *OnePasswordCli) UpdateSecret(folder secrets.Folder, ref secrets.SecretRef, secret secrets.SecretData) error {
+	vaultId, _, _ := strings.Cut(folder.ID, "/")
+	output, err := client.run(nil, "item", "get", ref.ID, "--vault", vaultId, "--format", "json")
+	if err != nil {
+		return err
+	}
+	var item opItem
+	var template map[string]any
+	if err := json.Unmarshal(output, &item); err != nil {
+		return fmt.Errorf("parsing op item get output: %w", err)
+	}
+	if err := json.Unmarshal(output, &template); err != nil {
+		return fmt.Errorf("parsing op item get output: %w", err)
+	}
+
+	field := passwordField(item)
+	if field.Label == "" {
+		return fmt.Errorf("%s does not have a password field", ref.Key)
+	}
+	fields, _ := template["fields"].([]any)
+	for _, value := range fields {
+		if templateField, ok := value.(map[string]any); ok && templateField["id"] == field.Id {
+			templateField["value"] = secret.Value
+		}
+	}
+
+	edited, err := json.Marshal(template)
+	if err != nil {
+		return fmt.Errorf("encoding item: %w", err)
+	}
+	_, err = client.run(edited, "item", "edit", ref.ID, "--vault", vaultId, "--template", "-")
+	return err
+}
+
+func (client *OnePasswordCli) DeleteSecret(folder secrets.Folder, ref secrets.SecretRef) error {
+	vaultId, _, _ := strings.Cut(folder.ID, "/")
+	_, err := client.run(nil, "item", "delete", ref.ID, "--vault", vaultId)
+	return err
+}
+
+func (client *OnePasswordCli) vaults() ([]opVault, error) {
+	var vaults []opVault
+	if err := client.runJson(&vaults, nil, "vault", "list"); err != nil {
+		return nil, err
+	}
+
+	return vaults, nil
+}
+
+// Gets every item in the folder that has a password field. The item list is piped back into op item get,
+// which returns every item with its fields in one call instead of one call per item.
+func (client *OnePasswordCli) items(folder secrets.Folder) ([]opItem, error) {
+	vaultId, tag, hasTag := strings.Cut(folder.ID, "/")
+	args := []string{"item", "list", "--vault", vaultId}
+	if hasTag {
+		args = append(args, "--tags", tag)
+	}
+
+	list, err := client.run(nil, append(args, "--format", "json")...)
+	if err != nil {
+		return nil, err
+	}
+	var summaries []opItem
+	if err := json.Unmarshal(list, &summaries); err != nil {
+		return nil, fmt.Errorf("parsing op item list output: %w", err)
+	}
+	if len(summaries) == 0 {
+		return []opItem{}, nil
+	}
+
+	output, err := client.run(list, "item", "get", "-", "--format", "json")
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]opItem, 0, len(summaries))
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	for {
+		var item opItem
+		if err := decoder.Decode(&item); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("parsing op item get output: %w", err)
+		}
+		if passwordField(item).Label != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items, nil
+}
+
+// Finds the field 1Password uses as the items password, falling back to a field named password or credential.
+func passwordField(item opItem) opField {
+	for _, field := range item.Fields {
+		if field.Purpose == "PASSWORD" {
+			return field
+		}
+	}
+
+	for _, field := range item.Fields {
+		if field.Id == "password" || field.Id == "credential" || strings.EqualFold(field.Label, "password") {
+			return field
+		}
+	}
+
+	return opField{}
+}
+
+func (client *OnePasswordCli) runJson(value any, stdin []byte, args ...string) error {
+	output, err := client.run(stdin, append(args, "--format", "json")...)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(output, value); err != nil {
+		return fmt.Errorf("parsing op %s output: %w", strings.Join(args[:2], " "), err)
+	}
+
+	return nil
+}
+
+func (client *OnePasswordCli) run(stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command(client.binary, args...)
+	cmd.Env = append(os.Environ(), client.env...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	var stdOut bytes.Buffer
+	var errOut bytes.Buffer
+	cmd.Stdout = &stdOut
+	cmd.Stderr = &errOut
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("op %s error: %s: %w", strings.Join(args[:min(2, len(args))], " "), strings.TrimSpace(errOut.String()), err)
+	}
+
+	return stdOut.Bytes(), nil
+}
@@ -0,0 +1,276 @@
+package onepassword_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/chadsmith12/dotsec/onepassword"
+	"github.com/chadsmith12/dotsec/secrets"
+)
+
+const fakeOpEnv = "DOTSEC_FAKE_OP_STATE"
+
+type fakeItem struct {
+	Id       string           `json:"id"`
+	Title    string           `json:"title"`
+	Category string           `json:"category"`
+	Vault    string           `json:"vault"`
+	Tags     []string         `json:"tags"`
+	Fields   []map[string]any `json:"fields"`
+}
+
+// The account the fake op binary reads and writes between runs.
+type fakeAccount struct {
+	Vaults []map[string]string `json:"vaults"`
+	Items  []fakeItem          `json:"items"`
+}
+
+// When the test binary is started with the fake state variable it acts as the op CLI instead of running the tests.
+func TestMain(m *testing.M) {
+	if statePath := os.Getenv(fakeOpEnv); statePath != "" {
+		os.Exit(fakeOp(statePath, os.Args[1:]))
+	}
+
+	os.Exit(m.Run())
+}
+
+func fakeOp(statePath string, args []string) int {
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var account fakeAccount
+	json.Unmarshal(data, &account)
+
+	if os.Getenv("OP_SESSION_test") != "token" {
+		fmt.Fprintln(os.Stderr, "[ERROR] account is not signed in")
+		return 1
+	}
+
+	flags := map[string]string{}
+	positional := []string{}
+	for i := 0; i < len(args); i++ {
+		if strings.HasPrefix(args[i], "--") {
+			flags[args[i]] = args[i+1]
+			i++
+			continue
+		}
+		positional = append(positional, args[i])
+	}
+
+	switch strings.Join(positional[:min(2, len(positional))], " ") {
+	case "whoami":
+		return 0
+	case "vault list":
+		return writeJson(account.Vaults)
+	case "item list":
+		items := make([]fakeItem, 0)
+		for _, item := range account.Items {
+			if item.Vault == flags["--vault"] && (flags["--tags"] == "" || slices.Contains(item.Tags, flags["--tags"])) {
+				summary := item
+				summary.Fields = nil
+				items = append(items, summary)
+			}
+		}
+		return writeJson(items)
+	case "item get":
+		ids := []string{positional[2]}
+		if positional[2] == "-" {
+			var summaries []fakeItem
+			json.NewDecoder(os.Stdin).Decode(&summaries)
+			ids = ids[:0]
+			for _, summary := range summaries {
+				ids = append(ids, summary.Id)
+			}
+		}
+		for _, id := range ids {
+			for _, item := range account.Items {
+				if item.Id == id {
+					writeJson(item)
+				}
+			}
+		}
+		return 0
+	case "item create":
+		var item fakeItem
+		if err := json.NewDecoder(os.Stdin).Decode(&item); err != nil || flags["--template"] != "-" || len(positional) != 2 {
+			fmt.Fprintln(os.Stderr, "[ERROR] expected the item template on stdin")
+			return 1
+		}
+		item.Id = fmt.Sprintf("item-%d", len(account.Items)+1)
+		item.Vault = flags["--vault"]
+		account.Items = append(account.Items, item)
+		return saveAccount(statePath, account)
+	case "item edit":
+		var edited fakeItem
+		if err := json.NewDecoder(os.Stdin).Decode(&edited); err != nil || flags["--template"] != "-" || len(positional) != 3 {
+			fmt.Fprintln(os.Stderr, "[ERROR] expected the item template on stdin")
+			return 1
+		}
+		for i, item := range account.Items {
+			if item.Id == positional[2] {
+				account.Items[i] = edited
+			}
+		}
+		return saveAccount(statePath, account)
+	case "item delete":
+		account.Items = slices.DeleteFunc(account.Items, func(item fakeItem) bool { return item.Id == positional[2] })
+		return saveAccount(statePath, account)
+	}
+
+	fmt.Fprintln(os.Stderr, "[ERROR] unknown command")
+	return 1
+}
+
+func writeJson(value any) int {
+	json.NewEncoder(os.Stdout).Encode(value)
+	return 0
+}
+
+func saveAccount(statePath string, account fakeAccount) int {
+	data, _ := json.Marshal(account)
+	if err := os.WriteFile(statePath, data, 0600); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// Writes the account for the fake op binary and returns a client that runs the test binary as op.
+func newFakeClient(t *testing.T) (*onepassword.OnePasswordCli, string) {
+	t.Helper()
+	account := fakeAccount{
+		Vaults: []map[string]string{{"id": "vault-1", "name": "Mobile"}},
+		Items: []fakeItem{
+			{Id: "item-1", Title: "API_KEY", Category: "LOGIN", Vault: "vault-1", Tags: []string{"dev"}, Fields: []map[string]any{
+				{"id": "username", "label": "username", "purpose": "USERNAME", "value": "api"},
+				{"id": "password", "label": "password", "purpose": "PASSWORD", "value": "secret123"},
+			}},
+			{Id: "item-2", Title: "STRIPE_KEY", Category: "API_CREDENTIAL", Vault: "vault-1", Tags: []string{"prod"}, Fields: []map[string]any{
+				{"id": "credential", "label": "credential", "value": "sk_live"},
+			}},
+			{Id: "item-3", Title: "Wifi notes", Category: "SECURE_NOTE", Vault: "vault-1", Tags: []string{"dev"}, Fields: []map[string]any{
+				{"id": "notesPlain", "label": "notesPlain", "purpose": "NOTES", "value": "not a secret"},
+			}},
+		},
+	}
+
+	statePath := filepath.Join(t.TempDir(), "account.json")
+	data, _ := json.Marshal(account)
+	if err := os.WriteFile(statePath, data, 0600); err != nil {
+		t.Fatalf("Failed to write fake account: %v", err)
+	}
+	t.Setenv(fakeOpEnv, statePath)
+
+	client := onepassword.NewClient(os.Args[0])
+	client.UseSession("test", "token")
+
+	return client, statePath
+}
+
+func TestCheckSignedIn(t *testing.T) {
+	client, _ := newFakeClient(t)
+	if err := client.CheckSignedIn(); err != nil {
+		t.Errorf("CheckSignedIn failed: %v", err)
+	}
+
+	if err := onepassword.NewClient(os.Args[0]).CheckSignedIn(); !errors.Is(err, onepassword.NotSignedInErr) {
+		t.Errorf("CheckSignedIn() error = %v, expected %v", err, onepassword.NotSignedInErr)
+	}
+}
+
+func TestGetSecrets_VaultAndTag(t *testing.T) {
+	client, _ := newFakeClient(t)
+
+	testCases := []struct {
+		folder   string
+		expected []secrets.SecretData
+	}{
+		{folder: "Mobile", expected: []secrets.SecretData{{Key: "API_KEY", Value: "secret123"}, {Key: "STRIPE_KEY", Value: "sk_live"}}},
+		{folder: "Mobile/dev", expected: []secrets.SecretData{{Key: "API_KEY", Value: "secret123"}}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.folder, func(t *testing.T) {
+			actual, err := secrets.GetSecretsByFolder(client, testCase.folder)
+			if err != nil {
+				t.Fatalf("GetSecretsByFolder failed: %v", err)
+			}
+			if !reflect.DeepEqual(actual, testCase.expected) {
+				t.Errorf("GetSecretsByFolder() = %v, expected %v", actual, testCase.expected)
+			}
+		})
+	}
+
+	if _, err := client.GetFolder("Missing/dev"); !errors.Is(err, onepassword.InvalidFolderErr) {
+		t.Errorf("GetFolder() error = %v, expected %v", err, onepassword.InvalidFolderErr)
+	}
+}
+
+func TestListFolders_IncludesTags(t *testing.T) {
+	client, _ := newFakeClient(t)
+
+	folders, err := client.ListFolders()
+	if err != nil {
+		t.Fatalf("ListFolders failed: %v", err)
+	}
+
+	expected := []secrets.Folder{
+		{ID: "vault-1", Name: "Mobile"},
+		{ID: "vault-1/dev", Name: "Mobile/dev"},
+		{ID: "vault-1/prod", Name: "Mobile/prod"},
+	}
+	if !reflect.DeepEqual(folders, expected) {
+		t.Errorf("ListFolders() = %v, expected %v", folders, expected)
+	}
+}
+
+func TestPush_CreatesUpdatesAndDeletesItems(t *testing.T) {
+	client, statePath := newFakeClient(t)
+
+	folder, err := client.GetFolder("Mobile/dev")
+	if err != nil {
+		t.Fatalf("GetFolder failed: %v", err)
+	}
+	refs, err := client.ListSecrets(folder)
+	if err != nil {
+		t.Fatalf("ListSecrets failed: %v", err)
+	}
+	apiKey, _ := secrets.FindSecretRef(refs, "API_KEY")
+
+	if err := client.UpdateSecret(folder, apiKey, secrets.SecretData{Key: "API_KEY", Value: "rotated"}); err != nil {
+		t.Fatalf("UpdateSecret failed: %v", err)
+	}
+	if err := client.CreateSecret(folder, secrets.SecretData{Key: "DB_PASSWORD", Value: "a=b"}); err != nil {
+		t.Fatalf("CreateSecret failed: %v", err)
+	}
+
+	actual, _ := client.GetSecrets(folder)
+	expected := []secrets.SecretData{{Key: "API_KEY", Value: "rotated"}, {Key: "DB_PASSWORD", Value: "a=b"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("GetSecrets() = %v, expected %v", actual, expected)
+	}
+	var account fakeAccount
+	data, _ := os.ReadFile(statePath)
+	json.Unmarshal(data, &account)
+	if account.Items[0].Fields[0]["value"] != "api" || account.Items[0].Category != "LOGIN" {
+		t.Errorf("UpdateSecret changed the rest of the item: %v", account.Items[0])
+	}
+
+	if err := client.DeleteSecret(folder, apiKey); err != nil {
+		t.Fatalf("DeleteSecret failed: %v", err)
+	}
+	refs, _ = client.ListSecrets(folder)
+	if _, found := secrets.FindSecretRef(refs, "API_KEY"); found {
+		t.Error("API_KEY should have been deleted")
+	}
+}
@@ -0,0 +1,345 @@
+package pass
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/chadsmith12/dotsec/secrets"
+)
+
+const (
+	gpgIdFile     = ".gpg-id"
+	publicKeysDir = ".public-keys"
+	gpgExtension  = ".gpg"
+)
+
+var (
+	InvalidFolderErr = secrets.ErrFolderNotFound
+)
+
+// PasswordStore reads and writes a pass (or gopass) password store, where each subdirectory is a folder.
+// The first line of each decrypted .gpg file is the secrets value, and the file name is its key.
+type PasswordStore struct {
+	root       string
+	privateKey *crypto.Key
+	keyRing    *crypto.KeyRing
+}
+
+// Initializes a PasswordStore rooted at storeDir, decrypting entries with the armored private key unlocked by passphrase.
+// Returns an error if the private key cannot be unlocked.
+func NewStore(storeDir, armoredPrivateKey, passphrase string) (*PasswordStore, error) {
+	info, err := os.Stat(storeDir)
+	if err != nil {
+		return nil, fmt.Errorf("opening password store: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("password store %s is not a directory", storeDir)
+	}
+
+	key, err := crypto.NewKeyFromArmored(armoredPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("reading private key: %w", err)
+	}
+
+	locked, err := key.IsLocked()
+	if err != nil {
+		return nil, fmt.Errorf("reading private key: %w", err)
+	}
+	if locked {
+		key, err = key.Unlock([]byte(passphrase))
+		if err != nil {
+			return nil, fmt.Errorf("unlocking private key: %w", err)
+		}
+	}
+
+	keyRing, err := crypto.NewKeyRing(key)
+	if err != nil {
+		return nil, fmt.Errorf("creating key ring: %w", err)
+	}
+
+	return &PasswordStore{root: storeDir, privateKey: key, keyRing: keyRing}, nil
+}
+
+// Lists every directory in the store, skipping hidden directories like .git.
+func (store *PasswordStore) ListFolders() ([]secrets.Folder, error) {
+	folders := make([]secrets.Folder, 0)
+	err := filepath.WalkDir(store.root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() || path == store.root {
+			return nil
+		}
+		if strings.HasPrefix(entry.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		relative, err := filepath.Rel(store.root, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(relative)
+		folders = append(folders, secrets.Folder{ID: name, Name: name})
+		return nil
+	})
+
+	return folders, err
+}
+
+// Finds the directory in the store. The folder name is the path relative to the root of the store.
+func (store *PasswordStore) GetFolder(name string) (secrets.Folder, error) {
+	name = strings.Trim(filepath.ToSlash(filepath.Clean(name)), "/")
+	if name == "" || name == "." || strings.HasPrefix(name, "..") {
+		return secrets.Folder{}, InvalidFolderErr
+	}
+
+	info, err := os.Stat(store.folderPath(name))
+	if err != nil || !info.IsDir() {
+		return secrets.Folder{}, InvalidFolderErr
+	}
+
+	return secrets.Folder{ID: name, Name: name}, nil
+}
+
+// Lists the .gpg files directly inside of the folder.
+func (store *PasswordStore) ListSecrets(folder secrets.Folder) ([]secrets.SecretRef, error) {
+	entries, err := os.ReadDir(store.folderPath(folder.ID))
+	if err != nil {
+		return nil, fmt.Errorf("reading folder: %w", err)
+	}
+
+	refs := make([]secrets.SecretRef, 0)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), gpgExtension) {
+			continue
+		}
+		refs = append(refs, secrets.SecretRef{ID: entry.Name(), Key: strings.TrimSuffix(entry.Name(), gpgExtension)})
+	}
+
+	return refs, nil
+}
+
+// Decrypts every entry in the folder, using the first line as the value.
+func (store *PasswordStore) GetSecrets(folder secrets.Folder) ([]secrets.SecretData, error) {
+	refs, err := store.ListSecrets(folder)
+	if err != nil {
+		return []secrets.SecretData{}, err
+	}
+
+	secretData := make([]secrets.SecretData, 0, len(refs))
+	var errs secrets.SecretErrors
+	for _, ref := range refs {
+		content, err := store.decrypt(filepath.Join(store.folderPath(folder.ID), ref.ID))
+		if err != nil {
+			errs = append(errs, secrets.SecretError{Key: ref.Key, Operation: "read", Err: fmt.Errorf("decrypting: %w", err)})
+			continue
+		}
+		value, _, _ := strings.Cut(content, "\n")
+		secretData = append(secretData, secrets.SecretData{Key: ref.Key, Value: strings.TrimSuffix(value, "\r")})
+	}
+
+	return secretData, errs.Err()
+}
+
+// Encrypts a new entry for every recipient in the folders .gpg-id.
+func (store *PasswordStore) CreateSecret(folder secrets.Folder, secret secrets.SecretData) error {
+	if strings.ContainsAny(secret.Key, `/\`) {
+		return fmt.Errorf("invalid key %s: keys cannot contain path separators", secret.Key)
+	}
+
+	return store.encrypt(folder, secret.Key+gpgExtension, secret.Value+"\n")
+}
+
+// Replaces the first line of the entry and re-encrypts it, keeping any extra lines such as usernames or urls.
+func (store *PasswordStore) UpdateSecret(folder secrets.Folder, ref secrets.SecretRef, secret secrets.SecretData) error {
+	content, err := store.decrypt(filepath.Join(store.folderPath(folder.ID), ref.ID))
+	if err != nil {
+		return fmt.Errorf("decrypting %s: %w", ref.Key, err)
+	}
+
+	_, rest, found := strings.Cut(content, "\n")
+	content = secret.Value + "\n"
+	if found {
+		content += rest
+	}
+
+	return store.encrypt(folder, ref.ID, content)
+}
+
+func (store *PasswordStore) DeleteSecret(folder secrets.Folder, ref secrets.SecretRef) error {
+	return os.Remove(filepath.Join(store.folderPath(folder.ID), ref.ID))
+}
+
+func (store *PasswordStore) folderPath(name string) string {
+	return filepath.Join(store.root, filepath.FromSlash(name))
+}
+
+func (store *PasswordStore) decrypt(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	var message *crypto.PGPMessage
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN PGP MESSAGE")) {
+		message, err = crypto.NewPGPMessageFromArmored(string(data))
+		if err != nil {
+			return "", err
+		}
+	} else {
+		message = crypto.NewPGPMessage(data)
+	}
+
+	plain, err := store.keyRing.Decrypt(message, nil, 0)
+	if err != nil {
+		return "", err
+	}
+
+	return plain.GetString(), nil
+}
+
+func (store *PasswordStore) encrypt(folder secrets.Folder, fileName, content string) error {
+	recipients, err := store.recipients(folder.ID)
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := recipients.Encrypt(crypto.NewPlainMessageFromString(content), nil)
+	if err != nil {
+		return fmt.Errorf("encrypting %s: %w", fileName, err)
+	}
+
+	return os.WriteFile(filepath.Join(store.folderPath(folder.ID), fileName), encrypted.GetBinary(), 0600)
+}
+
+// Builds a key ring of every recipient in the nearest .gpg-id, looking from the folder up to the root of the store.
+func (store *PasswordStore) recipients(folderName string) (*crypto.KeyRing, error) {
+	ids, err := store.gpgIds(folderName)
+	if err != nil {
+		return nil, err
+	}
+
+	keyRing, err := crypto.NewKeyRing(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		key, err := store.publicKey(id)
+		if err != nil {
+			return nil, err
+		}
+		if err := keyRing.AddKey(key); err != nil {
+			return nil, fmt.Errorf("adding key for recipient %s: %w", id, err)
+		}
+	}
+
+	return keyRing, nil
+}
+
+func (store *PasswordStore) gpgIds(folderName string) ([]string, error) {
+	dir := store.folderPath(folderName)
+	for {
+		data, err := os.ReadFile(filepath.Join(dir, gpgIdFile))
+		if err == nil {
+			ids := make([]string, 0)
+			for _, line := range strings.Split(string(data), "\n") {
+				line = strings.TrimSpace(line)
+				if line == "" || strings.HasPrefix(line, "#") {
+					continue
+				}
+				ids = append(ids, line)
+			}
+			if len(ids) == 0 {
+				return nil, fmt.Errorf("%s has no recipients", filepath.Join(dir, gpgIdFile))
+			}
+			return ids, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+
+		if filepath.Clean(dir) == filepath.Clean(store.root) {
+			return nil, fmt.Errorf("no %s found in the password store", gpgIdFile)
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// Finds the public key of a recipient. Our own key is used when it matches, then the keys exported to
+// the stores .public-keys directory the way gopass does, and finally the keys in the users gpg keyring.
+func (store *PasswordStore) publicKey(id string) (*crypto.Key, error) {
+	if matchesKey(store.privateKey, id) {
+		return store.privateKey.ToPublic()
+	}
+
+	keyFiles, _ := filepath.Glob(filepath.Join(store.root, publicKeysDir, "*"))
+	sort.Strings(keyFiles)
+	for _, keyFile := range keyFiles {
+		key, err := readKeyFile(keyFile)
+		if err != nil {
+			continue
+		}
+		if matchesKey(key, id) || filepath.Base(keyFile) == id {
+			return key, nil
+		}
+	}
+
+	exported, err := exec.Command("gpg", "--export", id).Output()
+	if err == nil && len(exported) > 0 {
+		key, err := crypto.NewKey(exported)
+		if err == nil {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no public key found for recipient %s", id)
+}
+
+func readKeyFile(path string) (*crypto.Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN PGP")) {
+		return crypto.NewKeyFromArmored(string(data))
+	}
+
+	return crypto.NewKey(data)
+}
+
+// A .gpg-id line can be an email, a key id or a fingerprint, optionally prefixed with 0x.
+func matchesKey(key *crypto.Key, id string) bool {
+	normalized := strings.ToUpper(strings.TrimPrefix(strings.TrimPrefix(id, "0x"), "0X"))
+	entity := key.GetEntity()
+	if entity == nil {
+		return false
+	}
+
+	publicKeys := []string{strings.ToUpper(fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint))}
+	for _, subkey := range entity.Subkeys {
+		publicKeys = append(publicKeys, strings.ToUpper(fmt.Sprintf("%X", subkey.PublicKey.Fingerprint)))
+	}
+	for _, fingerprint := range publicKeys {
+		if len(normalized) >= 8 && strings.HasSuffix(fingerprint, normalized) {
+			return true
+		}
+	}
+
+	email := strings.Trim(id, "<>")
+	for _, identity := range entity.Identities {
+		if identity.UserId != nil && strings.EqualFold(identity.UserId.Email, email) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,171 @@
+package pass_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/chadsmith12/dotsec/pass"
+	"github.com/chadsmith12/dotsec/secrets"
+)
+
+func generateKey(t *testing.T, email string) *crypto.Key {
+	t.Helper()
+	key, err := crypto.GenerateKey("Test User", email, "x25519", 0)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	return key
+}
+
+func armoredPrivateKey(t *testing.T, key *crypto.Key, passphrase string) string {
+	t.Helper()
+	locked, err := key.Lock([]byte(passphrase))
+	if err != nil {
+		t.Fatalf("Failed to lock key: %v", err)
+	}
+	armored, err := locked.Armor()
+	if err != nil {
+		t.Fatalf("Failed to armor key: %v", err)
+	}
+
+	return armored
+}
+
+// Creates a password store with a myapp/dev folder that is shared between us and a teammate.
+func createTestStore(t *testing.T, ours, teammate *crypto.Key) string {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "myapp", "dev"), 0700); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".gpg-id"), []byte("me@example.com\n# teammate\n"+teammate.GetFingerprint()+"\n"), 0600); err != nil {
+		t.Fatalf("Failed to write .gpg-id: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(root, ".public-keys"), 0700); err != nil {
+		t.Fatalf("Failed to create public keys folder: %v", err)
+	}
+	teammatePublic, err := teammate.GetArmoredPublicKey()
+	if err != nil {
+		t.Fatalf("Failed to export public key: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".public-keys", "teammate@example.com"), []byte(teammatePublic), 0600); err != nil {
+		t.Fatalf("Failed to write public key: %v", err)
+	}
+
+	keyRing, _ := crypto.NewKeyRing(ours)
+	encrypted, err := keyRing.Encrypt(crypto.NewPlainMessageFromString("secret123\nusername: api\n"), nil)
+	if err != nil {
+		t.Fatalf("Failed to encrypt entry: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "myapp", "dev", "API_KEY.gpg"), encrypted.GetBinary(), 0600); err != nil {
+		t.Fatalf("Failed to write entry: %v", err)
+	}
+
+	return root
+}
+
+func decryptAs(t *testing.T, key *crypto.Key, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read entry: %v", err)
+	}
+	keyRing, _ := crypto.NewKeyRing(key)
+	plain, err := keyRing.Decrypt(crypto.NewPGPMessage(data), nil, 0)
+	if err != nil {
+		t.Fatalf("Failed to decrypt %s: %v", path, err)
+	}
+
+	return plain.GetString()
+}
+
+func TestGetSecrets_UsesFirstLine(t *testing.T) {
+	ours := generateKey(t, "me@example.com")
+	root := createTestStore(t, ours, generateKey(t, "teammate@example.com"))
+
+	store, err := pass.NewStore(root, armoredPrivateKey(t, ours, "master"), "master")
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+
+	actual, err := secrets.GetSecretsByFolder(store, "myapp/dev")
+	if err != nil {
+		t.Fatalf("GetSecretsByFolder failed: %v", err)
+	}
+
+	expected := []secrets.SecretData{{Key: "API_KEY", Value: "secret123"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("GetSecretsByFolder() = %v, expected %v", actual, expected)
+	}
+}
+
+func TestNewStore_WrongPassphrase(t *testing.T) {
+	ours := generateKey(t, "me@example.com")
+	root := createTestStore(t, ours, generateKey(t, "teammate@example.com"))
+
+	if _, err := pass.NewStore(root, armoredPrivateKey(t, ours, "master"), "wrong"); err == nil {
+		t.Error("NewStore should fail with the wrong passphrase")
+	}
+}
+
+func TestPush_EncryptsForEveryRecipient(t *testing.T) {
+	ours := generateKey(t, "me@example.com")
+	teammate := generateKey(t, "teammate@example.com")
+	root := createTestStore(t, ours, teammate)
+
+	store, err := pass.NewStore(root, armoredPrivateKey(t, ours, "master"), "master")
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	folder, err := store.GetFolder("myapp/dev")
+	if err != nil {
+		t.Fatalf("GetFolder failed: %v", err)
+	}
+	refs, err := store.ListSecrets(folder)
+	if err != nil {
+		t.Fatalf("ListSecrets failed: %v", err)
+	}
+
+	if err := store.UpdateSecret(folder, refs[0], secrets.SecretData{Key: "API_KEY", Value: "rotated"}); err != nil {
+		t.Fatalf("UpdateSecret failed: %v", err)
+	}
+	if err := store.CreateSecret(folder, secrets.SecretData{Key: "DB_PASSWORD", Value: "hunter2"}); err != nil {
+		t.Fatalf("CreateSecret failed: %v", err)
+	}
+
+	apiKey := filepath.Join(root, "myapp", "dev", "API_KEY.gpg")
+	if content := decryptAs(t, teammate, apiKey); content != "rotated\nusername: api\n" {
+		t.Errorf("teammate decrypted %q, expected the value to change and the extra lines to be kept", content)
+	}
+	if content := decryptAs(t, ours, filepath.Join(root, "myapp", "dev", "DB_PASSWORD.gpg")); content != "hunter2\n" {
+		t.Errorf("decrypted %q, expected %q", content, "hunter2\n")
+	}
+}
+
+func TestGetSecrets_ReturnsTheEntriesThatDecrypt(t *testing.T) {
+	ours := generateKey(t, "me@example.com")
+	root := createTestStore(t, ours, generateKey(t, "teammate@example.com"))
+	if err := os.WriteFile(filepath.Join(root, "myapp", "dev", "BROKEN.gpg"), []byte("not an encrypted entry"), 0600); err != nil {
+		t.Fatalf("Failed to write entry: %v", err)
+	}
+	store, err := pass.NewStore(root, armoredPrivateKey(t, ours, "master"), "master")
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+
+	actual, err := secrets.GetSecretsByFolder(store, "myapp/dev")
+	var failures secrets.SecretErrors
+	if !errors.As(err, &failures) || len(failures) != 1 || failures[0].Key != "BROKEN" || failures[0].Operation != "read" {
+		t.Fatalf("GetSecretsByFolder() error = %v, expected a read failure for BROKEN", err)
+	}
+	expected := []secrets.SecretData{{Key: "API_KEY", Value: "secret123"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("GetSecretsByFolder() = %v, expected %v", actual, expected)
+	}
+}
@@ -0,0 +1,102 @@
+package passbolt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/passbolt/go-passbolt/api"
+)
+
+var AmbiguousFolderErr = errors.New("more than one folder matches")
+
+// Finds the folder matching the path in folders, which needs to have the parents of the folders to match a nested path.
+// The path is matched against the end of each folders path, so Service/Dev finds Company/Team/Service/Dev.
+// A path starting with a slash has to match the whole path from a top level folder.
+func FindFolder(folders []api.Folder, path string) (api.Folder, error) {
+	segments := splitFolderPath(path)
+	if len(segments) == 0 {
+		return api.Folder{}, InvalidFolderErr
+	}
+	anchored := strings.HasPrefix(strings.TrimSpace(path), "/")
+	foldersById := indexFolders(folders)
+
+	matches := []api.Folder{}
+	for _, folder := range folders {
+		if folderMatches(foldersById, folder, segments, anchored) {
+			matches = append(matches, folder)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return api.Folder{}, InvalidFolderErr
+	case 1:
+		return matches[0], nil
+	}
+
+	candidates := make([]string, 0, len(matches))
+	for _, match := range matches {
+		candidates = append(candidates, folderPath(foldersById, match))
+	}
+
+	return api.Folder{}, fmt.Errorf("%w %s, use the path of the folder instead: %s", AmbiguousFolderErr, path, strings.Join(candidates, ", "))
+}
+
+// Walks up from the folder through its parents, matching the segments from the last one.
+func folderMatches(foldersById map[string]api.Folder, folder api.Folder, segments []string, anchored bool) bool {
+	current := folder
+	for i := len(segments) - 1; i >= 0; i-- {
+		if !strings.EqualFold(current.Name, segments[i]) {
+			return false
+		}
+		if i == 0 {
+			break
+		}
+		parent, found := foldersById[current.FolderParentID]
+		if !found {
+			return false
+		}
+		current = parent
+	}
+
+	_, hasParent := foldersById[current.FolderParentID]
+	return !anchored || !hasParent
+}
+
+// The names of the folder and its parents, joined with slashes. Parents the user can't see are left out.
+func folderPath(foldersById map[string]api.Folder, folder api.Folder) string {
+	names := []string{folder.Name}
+	current := folder
+	// the depth is limited to the number of folders so a cycle can't loop forever
+	for range foldersById {
+		parent, found := foldersById[current.FolderParentID]
+		if !found {
+			break
+		}
+		names = append([]string{parent.Name}, names...)
+		current = parent
+	}
+
+	return strings.Join(names, "/")
+}
+
+func indexFolders(folders []api.Folder) map[string]api.Folder {
+	foldersById := make(map[string]api.Folder, len(folders))
+	for _, folder := range folders {
+		foldersById[folder.ID] = folder
+	}
+
+	return foldersById
+}
+
+func splitFolderPath(path string) []string {
+	segments := []string{}
+	for _, segment := range strings.Split(path, "/") {
+		if segment = strings.TrimSpace(segment); segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+
+	return segments
+}
@@ -0,0 +1,67 @@
+package passbolt_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/chadsmith12/dotsec/passbolt"
+	"github.com/passbolt/go-passbolt/api"
+)
+
+var testFolders = []api.Folder{
+	{ID: "1", Name: "Company"},
+	{ID: "2", Name: "Payments", FolderParentID: "1"},
+	{ID: "3", Name: "Api", FolderParentID: "2"},
+	{ID: "4", Name: "Dev", FolderParentID: "3"},
+	{ID: "5", Name: "Search", FolderParentID: "1"},
+	{ID: "6", Name: "Dev", FolderParentID: "5"},
+	{ID: "7", Name: "Shared"},
+	// the parent of a folder shared with the user isn't always visible to them
+	{ID: "8", Name: "Api", FolderParentID: "hidden"},
+}
+
+func TestFindFolder(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"Shared", "7"},
+		{"Company/Payments/Api/Dev", "4"},
+		{"payments/api/dev", "4"},
+		{"Search/Dev", "6"},
+		{"/Company/Search/Dev", "6"},
+		{"/Company/", "1"},
+		{"/Api", "8"},
+	}
+	for _, test := range tests {
+		folder, err := passbolt.FindFolder(testFolders, test.path)
+		if err != nil || folder.ID != test.expected {
+			t.Errorf("FindFolder(%s) = %s, %v, expected folder %s", test.path, folder.ID, err, test.expected)
+		}
+	}
+}
+
+func TestFindFolder_NotFound(t *testing.T) {
+	for _, path := range []string{"Missing", "Search/Api", "/Payments/Api/Dev", ""} {
+		if _, err := passbolt.FindFolder(testFolders, path); !errors.Is(err, passbolt.InvalidFolderErr) {
+			t.Errorf("FindFolder(%s) error = %v, expected InvalidFolderErr", path, err)
+		}
+	}
+}
+
+func TestFindFolder_Ambiguous(t *testing.T) {
+	_, err := passbolt.FindFolder(testFolders, "Dev")
+	if !errors.Is(err, passbolt.AmbiguousFolderErr) {
+		t.Fatalf("FindFolder(Dev) error = %v, expected AmbiguousFolderErr", err)
+	}
+	for _, candidate := range []string{"Company/Payments/Api/Dev", "Company/Search/Dev"} {
+		if !strings.Contains(err.Error(), candidate) {
+			t.Errorf("error = %v, expected it to list %s", err, candidate)
+		}
+	}
+
+	if _, err := passbolt.FindFolder(testFolders, "Api"); !errors.Is(err, passbolt.AmbiguousFolderErr) {
+		t.Errorf("FindFolder(Api) error = %v, expected AmbiguousFolderErr", err)
+	}
+}
@@ -12,15 +12,20 @@ import (
 )
 
 var (
-	InvalidFolderErr = fmt.Errorf("failed to find folder")
+	InvalidFolderErr = secrets.ErrFolderNotFound
 )
 
+// The Passbolt permission that lets a group read and change a folder or resource, but not share or delete it.
+const updatePermission = 7
+
 type PassboltApi struct {
 	server     string
 	privateKey string
 	password   string
 	apiClient  *api.Client
 	context    context.Context
+	// the groups each folder was shared with by ShareFolder, which the resources created in it are shared with too
+	sharedGroups map[string][]string
 }
 
 type resourceResult struct {
@@ -63,45 +68,240 @@ func (client *PassboltApi) GetSecretsByFolder(folderName string) ([]secrets.Secr
 		return secretData, err
 	}
 
-	client.populateSecrets(folder.ChildrenResources, &secretData)
+	err = client.populateSecrets(folder.ChildrenResources, &secretData)
 
-	return secretData, nil
+	return secretData, err
 }
 
-func (client *PassboltApi) GetFolderWithResources(folderName string) (api.Folder, error) {
+// Finds the folder with its resources. folderPath is a folder name, or a slash separated path such as Team/Service/Dev
+// that is matched against the end of the folders path, or the whole path when it starts with a slash.
+// Returns AmbiguousFolderErr with the paths of the candidates when more than one folder matches.
+func (client *PassboltApi) GetFolderWithResources(folderPath string) (api.Folder, error) {
+	segments := splitFolderPath(folderPath)
+	if len(segments) == 0 {
+		return api.Folder{}, InvalidFolderErr
+	}
+	name := segments[len(segments)-1]
 	folders, err := client.apiClient.GetFolders(client.context, &api.GetFoldersOptions{
-		FilterSearch:             folderName,
+		FilterSearch:             name,
 		ContainChildrenResources: true,
 	})
+	if err != nil {
+		return api.Folder{}, err
+	}
+
+	candidates := make([]api.Folder, 0, len(folders))
+	for _, folder := range folders {
+		if strings.EqualFold(folder.Name, name) {
+			candidates = append(candidates, folder)
+		}
+	}
+	if len(candidates) == 0 {
+		return api.Folder{}, InvalidFolderErr
+	}
+	if len(candidates) == 1 && folderPath == name {
+		return candidates[0], nil
+	}
 
+	// the parents of the candidates are needed to match the path, so every folder is listed
+	allFolders, err := client.apiClient.GetFolders(client.context, nil)
+	if err != nil {
+		return api.Folder{}, err
+	}
+	found, err := FindFolder(allFolders, folderPath)
 	if err != nil {
 		return api.Folder{}, err
 	}
+	for _, candidate := range candidates {
+		if candidate.ID == found.ID {
+			return candidate, nil
+		}
+	}
+	found.ChildrenResources, err = client.folderResources(found.ID)
+
+	return found, err
+}
+
+// Gets every folder the user has access to in Passbolt, named by their path such as Company/Team/Dev.
+func (client *PassboltApi) ListFolders() ([]secrets.Folder, error) {
+	folders, err := client.apiClient.GetFolders(client.context, nil)
+	if err != nil {
+		return nil, err
+	}
 
+	foldersById := indexFolders(folders)
+	result := make([]secrets.Folder, 0, len(folders))
 	for _, folder := range folders {
-		if strings.EqualFold(folder.Name, folderName) {
-			return folder, nil
+		result = append(result, secrets.Folder{ID: folder.ID, Name: folderPath(foldersById, folder)})
+	}
+
+	return result, nil
+}
+
+// Finds the Passbolt folder with the matching name.
+func (client *PassboltApi) GetFolder(name string) (secrets.Folder, error) {
+	folder, err := client.GetFolderWithResources(name)
+	if err != nil {
+		return secrets.Folder{}, err
+	}
+
+	return secrets.Folder{ID: folder.ID, Name: folder.Name}, nil
+}
+
+// Gets the folders directly inside of the folder.
+func (client *PassboltApi) ListSubfolders(folder secrets.Folder) ([]secrets.Folder, error) {
+	folders, err := client.apiClient.GetFolders(client.context, &api.GetFoldersOptions{
+		FilterHasParent: []string{folder.ID},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]secrets.Folder, 0, len(folders))
+	for _, subfolder := range folders {
+		result = append(result, secrets.Folder{ID: subfolder.ID, Name: subfolder.Name})
+	}
+
+	return result, nil
+}
+
+// Creates a folder inside of parent.
+func (client *PassboltApi) CreateSubfolder(parent secrets.Folder, name string) (secrets.Folder, error) {
+	id, err := helper.CreateFolder(client.context, client.apiClient, parent.ID, name)
+	if err != nil {
+		return secrets.Folder{}, err
+	}
+
+	return secrets.Folder{ID: id, Name: name}, nil
+}
+
+// Creates a top level folder.
+func (client *PassboltApi) CreateFolder(name string) (secrets.Folder, error) {
+	id, err := helper.CreateFolder(client.context, client.apiClient, "", name)
+	if err != nil {
+		return secrets.Folder{}, err
+	}
+
+	return secrets.Folder{ID: id, Name: name}, nil
+}
+
+// Shares the folder with the groups by their names, letting them update its secrets. Passbolt doesn't share the
+// resources in a folder with it, so the secrets created in the folder afterwards are shared with the groups too.
+func (client *PassboltApi) ShareFolder(folder secrets.Folder, groups []string) error {
+	passboltGroups, err := client.apiClient.GetGroups(client.context, nil)
+	if err != nil {
+		return err
+	}
+
+	groupIds := make([]string, 0, len(groups))
+	missing := []string{}
+	for _, name := range groups {
+		found := false
+		for _, group := range passboltGroups {
+			if strings.EqualFold(group.Name, name) {
+				groupIds = append(groupIds, group.ID)
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, name)
 		}
 	}
+	if len(missing) > 0 {
+		return fmt.Errorf("groups not found: %s", strings.Join(missing, ", "))
+	}
+
+	if err := helper.ShareFolderWithUsersAndGroups(client.context, client.apiClient, folder.ID, nil, groupIds, updatePermission); err != nil {
+		return err
+	}
+	if client.sharedGroups == nil {
+		client.sharedGroups = map[string][]string{}
+	}
+	client.sharedGroups[folder.ID] = groupIds
+
+	return nil
+}
+
+// Lists the resources in the folder, using the resource name as the secret key.
+func (client *PassboltApi) ListSecrets(folder secrets.Folder) ([]secrets.SecretRef, error) {
+	resources, err := client.folderResources(folder.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]secrets.SecretRef, 0, len(resources))
+	for _, resource := range resources {
+		refs = append(refs, secrets.SecretRef{ID: resource.ID, Key: resource.Name})
+	}
+
+	return refs, nil
+}
+
+// Downloads and decrypts every resource in the folder. Resources that fail to download or decrypt are
+// returned as SecretErrors along with the secrets that didn't fail.
+func (client *PassboltApi) GetSecrets(folder secrets.Folder) ([]secrets.SecretData, error) {
+	resources, err := client.folderResources(folder.ID)
+	secretData := make([]secrets.SecretData, 0)
+	if err != nil {
+		return secretData, err
+	}
 
-	return api.Folder{}, InvalidFolderErr
+	err = client.populateSecrets(resources, &secretData)
+
+	return secretData, err
+}
+
+func (client *PassboltApi) CreateSecret(folder secrets.Folder, secret secrets.SecretData) error {
+	return client.CreateSecretInFolder(folder.ID, secret)
+}
+
+func (client *PassboltApi) UpdateSecret(folder secrets.Folder, ref secrets.SecretRef, secret secrets.SecretData) error {
+	return client.UpdateSecretById(ref.ID, secret)
+}
+
+func (client *PassboltApi) DeleteSecret(folder secrets.Folder, ref secrets.SecretRef) error {
+	return helper.DeleteResource(client.context, client.apiClient, ref.ID)
 }
 
 func (client *PassboltApi) CreateSecretInFolder(folderId string, secret secrets.SecretData) error {
-	_, err := helper.CreateResource(client.context, client.apiClient, folderId, secret.Key, "", "", secret.Value, "")
+	resourceId, err := helper.CreateResource(client.context, client.apiClient, folderId, secret.Key, "", "", secret.Value, "")
+	if err != nil {
+		return err
+	}
 
-	return err
+	if groupIds := client.sharedGroups[folderId]; len(groupIds) > 0 {
+		return helper.ShareResourceWithUsersAndGroups(client.context, client.apiClient, resourceId, nil, groupIds, updatePermission)
+	}
+
+	return nil
 }
 
-func (client *PassboltApi) UpdateSecret(resourceId string, secret secrets.SecretData) error {
+func (client *PassboltApi) UpdateSecretById(resourceId string, secret secrets.SecretData) error {
 	err := helper.UpdateResource(client.context, client.apiClient, resourceId, "", "", "", secret.Value, "")
 
 	return err
 }
 
-func (client *PassboltApi) populateSecrets(resources []api.Resource, secrets *[]secrets.SecretData) {
+func (client *PassboltApi) folderResources(folderId string) ([]api.Resource, error) {
+	folders, err := client.apiClient.GetFolders(client.context, &api.GetFoldersOptions{
+		FilterHasID:              []string{folderId},
+		ContainChildrenResources: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(folders) == 0 {
+		return nil, InvalidFolderErr
+	}
+
+	return folders[0].ChildrenResources, nil
+}
+
+func (client *PassboltApi) populateSecrets(resources []api.Resource, secretData *[]secrets.SecretData) error {
 	if len(resources) == 0 {
-		return
+		return nil
 	}
 	ch := make(chan resourceResult)
 	var wg sync.WaitGroup
@@ -115,18 +315,23 @@ func (client *PassboltApi) populateSecrets(resources []api.Resource, secrets *[]
 		close(ch)
 	}()
 
+	var errs secrets.SecretErrors
 	for result := range ch {
-		if result.err == nil {
-			*secrets = append(*secrets, result.secretData)
+		if result.err != nil {
+			errs = append(errs, secrets.SecretError{Key: result.secretData.Key, Operation: "read", Err: result.err})
+			continue
 		}
+		*secretData = append(*secretData, result.secretData)
 	}
+
+	return errs.Err()
 }
 
 func (client *PassboltApi) downloadResource(resource api.Resource, ch chan<- resourceResult, wg *sync.WaitGroup) {
 	defer wg.Done()
 	_, name, _, _, password, _, err := helper.GetResource(client.context, client.apiClient, resource.ID)
 	if err != nil {
-		secretData := secrets.SecretData{Key: "", Value: ""}
+		secretData := secrets.SecretData{Key: resource.Name, Value: ""}
 		ch <- resourceResult{secretData: secretData, err: err}
 		return
 	}
@@ -0,0 +1,213 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/chadsmith12/dotsec/secrets"
+)
+
+const (
+	// The prefix of the executables on the PATH that implement a provider, such as dotsec-provider-acme for the acme provider.
+	ExecutablePrefix = "dotsec-provider-"
+	ProtocolVersion  = 1
+
+	folderNotFoundCode = "folder_not_found"
+	notSupportedCode   = "not_supported"
+)
+
+// The operations a provider executable is run with. The operation is passed as the first argument and in the request.
+const (
+	ListFolders  = "list-folders"
+	GetFolder    = "get-folder"
+	CreateFolder = "create-folder"
+	ListSecrets  = "list-secrets"
+	GetSecrets   = "get-secrets"
+	CreateSecret = "create-secret"
+	UpdateSecret = "update-secret"
+	DeleteSecret = "delete-secret"
+)
+
+var (
+	InvalidFolderErr = secrets.ErrFolderNotFound
+	NotFoundErr      = errors.New("provider executable not found on PATH")
+	InvalidNameErr   = errors.New("invalid provider name - only lower case letters, digits and dashes are allowed")
+
+	errNotSupported = errors.New("operation not supported by the provider")
+
+	// a name with a slash would make LookPath run a file relative to the working directory instead of searching the PATH
+	validName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+)
+
+// The json written to the provider executables stdin. Only the fields used by the operation are set.
+type Request struct {
+	Version    int                 `json:"version"`
+	Operation  string              `json:"operation"`
+	Config     map[string]any      `json:"config,omitempty"`
+	FolderName string              `json:"folderName,omitempty"`
+	Folder     *secrets.Folder     `json:"folder,omitempty"`
+	Ref        *secrets.SecretRef  `json:"ref,omitempty"`
+	Secret     *secrets.SecretData `json:"secret,omitempty"`
+}
+
+// The json the provider executable writes to stdout. A provider reports a failure by setting error,
+// with code folder_not_found when the folder does not exist, or not_supported for an operation it doesn't implement.
+type Response struct {
+	Folders []secrets.Folder     `json:"folders,omitempty"`
+	Folder  *secrets.Folder      `json:"folder,omitempty"`
+	Refs    []secrets.SecretRef  `json:"refs,omitempty"`
+	Secrets []secrets.SecretData `json:"secrets,omitempty"`
+	Error   string               `json:"error,omitempty"`
+	Code    string               `json:"code,omitempty"`
+}
+
+// Provider is a SecretStore implemented by an external executable. Each call runs the executable with
+// the operation as its argument, a Request as json on stdin, and reads a Response as json from stdout.
+type Provider struct {
+	name   string
+	path   string
+	config map[string]any
+}
+
+// Finds the dotsec-provider-<name> executable on the PATH. The config is passed to the provider with every request.
+// Returns InvalidNameErr for a name that isn't only lower case letters, digits and dashes.
+func Find(name string, config map[string]any) (*Provider, error) {
+	if !validName.MatchString(name) {
+		return nil, fmt.Errorf("%w: %q", InvalidNameErr, name)
+	}
+	path, err := exec.LookPath(ExecutablePrefix + name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s%s", NotFoundErr, ExecutablePrefix, name)
+	}
+
+	return NewProvider(name, path, config), nil
+}
+
+// Initializes a Provider that runs the executable at path.
+func NewProvider(name, path string, config map[string]any) *Provider {
+	return &Provider{name: name, path: path, config: config}
+}
+
+func (provider *Provider) ListFolders() ([]secrets.Folder, error) {
+	response, err := provider.call(Request{Operation: ListFolders})
+	if err != nil {
+		return nil, err
+	}
+
+	return orEmpty(response.Folders), nil
+}
+
+func (provider *Provider) GetFolder(name string) (secrets.Folder, error) {
+	response, err := provider.call(Request{Operation: GetFolder, FolderName: name})
+	if err != nil {
+		return secrets.Folder{}, err
+	}
+	if response.Folder == nil {
+		return secrets.Folder{}, InvalidFolderErr
+	}
+
+	return *response.Folder, nil
+}
+
+// Creates the folder, which is sent as the folderName. For a path such as Team/Service/Dev the whole path is sent,
+// and it is up to the provider how its parents are handled. Returns ErrCreateFolderNotSupported when the provider
+// reports create-folder as not_supported.
+func (provider *Provider) CreateFolder(name string) (secrets.Folder, error) {
+	response, err := provider.call(Request{Operation: CreateFolder, FolderName: name})
+	if errors.Is(err, errNotSupported) {
+		return secrets.Folder{}, secrets.ErrCreateFolderNotSupported
+	}
+	if err != nil {
+		return secrets.Folder{}, err
+	}
+	if response.Folder == nil {
+		return secrets.Folder{}, fmt.Errorf("provider %s %s error: no folder returned", provider.name, CreateFolder)
+	}
+
+	return *response.Folder, nil
+}
+
+func (provider *Provider) ListSecrets(folder secrets.Folder) ([]secrets.SecretRef, error) {
+	response, err := provider.call(Request{Operation: ListSecrets, Folder: &folder})
+	if err != nil {
+		return nil, err
+	}
+
+	return orEmpty(response.Refs), nil
+}
+
+func (provider *Provider) GetSecrets(folder secrets.Folder) ([]secrets.SecretData, error) {
+	response, err := provider.call(Request{Operation: GetSecrets, Folder: &folder})
+	if err != nil {
+		return []secrets.SecretData{}, err
+	}
+
+	return orEmpty(response.Secrets), nil
+}
+
+func (provider *Provider) CreateSecret(folder secrets.Folder, secret secrets.SecretData) error {
+	_, err := provider.call(Request{Operation: CreateSecret, Folder: &folder, Secret: &secret})
+	return err
+}
+
+func (provider *Provider) UpdateSecret(folder secrets.Folder, ref secrets.SecretRef, secret secrets.SecretData) error {
+	_, err := provider.call(Request{Operation: UpdateSecret, Folder: &folder, Ref: &ref, Secret: &secret})
+	return err
+}
+
+func (provider *Provider) DeleteSecret(folder secrets.Folder, ref secrets.SecretRef) error {
+	_, err := provider.call(Request{Operation: DeleteSecret, Folder: &folder, Ref: &ref})
+	return err
+}
+
+func (provider *Provider) call(request Request) (Response, error) {
+	request.Version = ProtocolVersion
+	request.Config = provider.config
+	body, err := json.Marshal(request)
+	if err != nil {
+		return Response{}, err
+	}
+
+	cmd := exec.Command(provider.path, request.Operation)
+	cmd.Stdin = bytes.NewReader(body)
+	var stdOut, errOut bytes.Buffer
+	cmd.Stdout = &stdOut
+	cmd.Stderr = &errOut
+
+	runErr := cmd.Run()
+
+	var response Response
+	if stdOut.Len() > 0 {
+		if err := json.Unmarshal(stdOut.Bytes(), &response); err != nil && runErr == nil {
+			return Response{}, fmt.Errorf("provider %s returned invalid json for %s: %w", provider.name, request.Operation, err)
+		}
+	}
+
+	if response.Code == folderNotFoundCode {
+		return Response{}, InvalidFolderErr
+	}
+	if response.Code == notSupportedCode {
+		return Response{}, fmt.Errorf("provider %s %s: %w", provider.name, request.Operation, errNotSupported)
+	}
+	if response.Error != "" {
+		return Response{}, fmt.Errorf("provider %s %s error: %s", provider.name, request.Operation, response.Error)
+	}
+	if runErr != nil {
+		return Response{}, fmt.Errorf("provider %s %s error: %s: %w", provider.name, request.Operation, strings.TrimSpace(errOut.String()), runErr)
+	}
+
+	return response, nil
+}
+
+func orEmpty[T any](values []T) []T {
+	if values == nil {
+		return []T{}
+	}
+
+	return values
+}
@@ -0,0 +1,215 @@
+package plugin_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/chadsmith12/dotsec/plugin"
+	"github.com/chadsmith12/dotsec/secrets"
+)
+
+const fakePluginEnv = "DOTSEC_FAKE_PLUGIN_STATE"
+
+// When the test binary is started with the fake state variable it acts as a provider executable instead of running the tests.
+func TestMain(m *testing.M) {
+	if statePath := os.Getenv(fakePluginEnv); statePath != "" {
+		os.Exit(fakePlugin(statePath, os.Args[1:]))
+	}
+
+	os.Exit(m.Run())
+}
+
+// A provider that keeps its folders in a json file, the same way an internal provider would keep them in its own backend.
+func fakePlugin(statePath string, args []string) int {
+	folders := map[string]map[string]string{}
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	json.Unmarshal(data, &folders)
+
+	var request plugin.Request
+	if err := json.NewDecoder(os.Stdin).Decode(&request); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(args) != 1 || args[0] != request.Operation || request.Version != plugin.ProtocolVersion {
+		return writeJson(plugin.Response{Error: "unexpected request"})
+	}
+	if request.Config["token"] != "secret-token" {
+		return writeJson(plugin.Response{Error: "unauthorized"})
+	}
+
+	response := plugin.Response{}
+	switch request.Operation {
+	case plugin.ListFolders:
+		for name := range folders {
+			response.Folders = append(response.Folders, secrets.Folder{ID: name, Name: name})
+		}
+	case plugin.GetFolder:
+		if _, found := folders[request.FolderName]; !found {
+			return writeJson(plugin.Response{Error: "no such folder", Code: "folder_not_found"})
+		}
+		response.Folder = &secrets.Folder{ID: request.FolderName, Name: request.FolderName}
+	case plugin.CreateFolder:
+		if request.Config["readOnly"] == true {
+			return writeJson(plugin.Response{Error: "folders are managed by the platform team", Code: "not_supported"})
+		}
+		folders[request.FolderName] = map[string]string{}
+		response.Folder = &secrets.Folder{ID: request.FolderName, Name: request.FolderName}
+	case plugin.ListSecrets:
+		for key := range folders[request.Folder.ID] {
+			response.Refs = append(response.Refs, secrets.SecretRef{ID: key, Key: key})
+		}
+	case plugin.GetSecrets:
+		for key, value := range folders[request.Folder.ID] {
+			response.Secrets = append(response.Secrets, secrets.SecretData{Key: key, Value: value})
+		}
+	case plugin.CreateSecret, plugin.UpdateSecret:
+		folders[request.Folder.ID][request.Secret.Key] = request.Secret.Value
+	case plugin.DeleteSecret:
+		delete(folders[request.Folder.ID], request.Ref.ID)
+	default:
+		fmt.Fprintln(os.Stderr, "unknown operation", request.Operation)
+		return 2
+	}
+
+	data, _ = json.Marshal(folders)
+	os.WriteFile(statePath, data, 0600)
+
+	return writeJson(response)
+}
+
+func writeJson(value any) int {
+	json.NewEncoder(os.Stdout).Encode(value)
+	return 0
+}
+
+// Puts the test binary on the PATH as dotsec-provider-fake, with a single folder holding a single secret.
+func installFakePlugin(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "state.json")
+	if err := os.WriteFile(statePath, []byte(`{"api":{"API_KEY":"secret123"}}`), 0600); err != nil {
+		t.Fatalf("Failed to write state: %v", err)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatalf("Failed to find test binary: %v", err)
+	}
+	if err := os.Symlink(executable, filepath.Join(dir, plugin.ExecutablePrefix+"fake")); err != nil {
+		t.Fatalf("Failed to link fake provider: %v", err)
+	}
+
+	t.Setenv("PATH", dir)
+	t.Setenv(fakePluginEnv, statePath)
+}
+
+func TestFind_MissingExecutable(t *testing.T) {
+	installFakePlugin(t)
+
+	if _, err := plugin.Find("missing", nil); !errors.Is(err, plugin.NotFoundErr) {
+		t.Errorf("Find() error = %v, expected %v", err, plugin.NotFoundErr)
+	}
+}
+
+func TestFind_RejectsNamesOutsideThePath(t *testing.T) {
+	installFakePlugin(t)
+	// a provider in the working directory, which a cloned repo could ship next to its .dotsecrc
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	os.Chdir(dir)
+	t.Cleanup(func() { os.Chdir(wd) })
+	os.MkdirAll(filepath.Join(dir, plugin.ExecutablePrefix+"..", "evil"), 0700)
+	os.WriteFile(filepath.Join(dir, plugin.ExecutablePrefix+"..", "evil", "run"), []byte("#!/bin/sh\nexit 0\n"), 0700)
+
+	for _, name := range []string{"../evil/run", "fake/../fake", "/bin/sh", "Fake", "-fake", ""} {
+		if _, err := plugin.Find(name, nil); !errors.Is(err, plugin.InvalidNameErr) {
+			t.Errorf("Find(%q) error = %v, expected %v", name, err, plugin.InvalidNameErr)
+		}
+	}
+	if _, err := plugin.Find("fake", nil); err != nil {
+		t.Errorf("Find(fake) failed: %v", err)
+	}
+}
+
+func TestProvider_RoundTrip(t *testing.T) {
+	installFakePlugin(t)
+	provider, err := plugin.Find("fake", map[string]any{"token": "secret-token"})
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+
+	folder, err := provider.GetFolder("api")
+	if err != nil {
+		t.Fatalf("GetFolder failed: %v", err)
+	}
+	refs, err := provider.ListSecrets(folder)
+	if err != nil {
+		t.Fatalf("ListSecrets failed: %v", err)
+	}
+	apiKey, _ := secrets.FindSecretRef(refs, "API_KEY")
+
+	if err := provider.CreateSecret(folder, secrets.SecretData{Key: "DB_PASSWORD", Value: "hunter2"}); err != nil {
+		t.Fatalf("CreateSecret failed: %v", err)
+	}
+	if err := provider.DeleteSecret(folder, apiKey); err != nil {
+		t.Fatalf("DeleteSecret failed: %v", err)
+	}
+
+	actual, err := provider.GetSecrets(folder)
+	if err != nil {
+		t.Fatalf("GetSecrets failed: %v", err)
+	}
+	expected := []secrets.SecretData{{Key: "DB_PASSWORD", Value: "hunter2"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("GetSecrets() = %v, expected %v", actual, expected)
+	}
+}
+
+func TestProvider_Errors(t *testing.T) {
+	installFakePlugin(t)
+
+	provider, _ := plugin.Find("fake", map[string]any{"token": "secret-token"})
+	if _, err := provider.GetFolder("missing"); !errors.Is(err, plugin.InvalidFolderErr) {
+		t.Errorf("GetFolder() error = %v, expected %v", err, plugin.InvalidFolderErr)
+	}
+
+	unauthorized, _ := plugin.Find("fake", nil)
+	if _, err := unauthorized.ListFolders(); err == nil {
+		t.Error("ListFolders should return the error reported by the provider")
+	}
+}
+
+func TestProvider_CreateFolder(t *testing.T) {
+	installFakePlugin(t)
+	provider, _ := plugin.Find("fake", map[string]any{"token": "secret-token"})
+
+	folder, err := secrets.CreateFolder(provider, "Team/Service")
+	if err != nil {
+		t.Fatalf("CreateFolder failed: %v", err)
+	}
+	if err := provider.CreateSecret(folder, secrets.SecretData{Key: "API_KEY", Value: "new"}); err != nil {
+		t.Fatalf("CreateSecret failed: %v", err)
+	}
+	actual, err := secrets.GetSecretsByFolder(provider, "Team/Service")
+	if err != nil {
+		t.Fatalf("GetSecretsByFolder failed: %v", err)
+	}
+	expected := []secrets.SecretData{{Key: "API_KEY", Value: "new"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("GetSecretsByFolder() = %v, expected %v", actual, expected)
+	}
+
+	readOnly, _ := plugin.Find("fake", map[string]any{"token": "secret-token", "readOnly": true})
+	if _, err := readOnly.CreateFolder("other"); !errors.Is(err, secrets.ErrCreateFolderNotSupported) {
+		t.Errorf("CreateFolder() error = %v, expected %v", err, secrets.ErrCreateFolderNotSupported)
+	}
+}
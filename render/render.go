@@ -0,0 +1,38 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/chadsmith12/dotsec/secrets"
+)
+
+// Renders the Go text/template with the secrets. A secret is used with {{ secret "KEY" }}, or {{ .KEY }} when the key
+// is a valid template field name. Rendering fails on a key that isn't in the secrets instead of leaving it empty.
+func Render(name, text string, secretsData []secrets.SecretData) ([]byte, error) {
+	values := make(map[string]string, len(secretsData))
+	for _, secret := range secretsData {
+		values[secret.Key] = secret.Value
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(template.FuncMap{
+		"secret": func(key string) (string, error) {
+			value, found := values[key]
+			if !found {
+				return "", fmt.Errorf("secret %s not found", key)
+			}
+			return value, nil
+		},
+	}).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, values); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
@@ -0,0 +1,36 @@
+package render_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/chadsmith12/dotsec/render"
+	"github.com/chadsmith12/dotsec/secrets"
+)
+
+var testSecrets = []secrets.SecretData{
+	{Key: "ConnectionStrings:Default", Value: "Server=db;Password=p@ss"},
+	{Key: "API_KEY", Value: "abc123"},
+}
+
+func TestRender(t *testing.T) {
+	text := `{"ConnectionStrings": {"Default": "{{ secret "ConnectionStrings:Default" }}"}, "ApiKey": "{{ .API_KEY }}"}`
+
+	rendered, err := render.Render("appsettings", text, testSecrets)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	expected := `{"ConnectionStrings": {"Default": "Server=db;Password=p@ss"}, "ApiKey": "abc123"}`
+	if string(rendered) != expected {
+		t.Errorf("Render() = %s, expected %s", rendered, expected)
+	}
+}
+
+func TestRender_MissingKey(t *testing.T) {
+	for _, text := range []string{`{{ secret "MISSING" }}`, `{{ .MISSING }}`} {
+		_, err := render.Render("config", text, testSecrets)
+		if err == nil || !strings.Contains(err.Error(), "MISSING") {
+			t.Errorf("Render(%s) error = %v, expected it to fail on the missing key", text, err)
+		}
+	}
+}
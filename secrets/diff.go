@@ -0,0 +1,57 @@
+package secrets
+
+import "sort"
+
+type ChangeKind string
+
+const (
+	Added     ChangeKind = "added"
+	Removed   ChangeKind = "removed"
+	Changed   ChangeKind = "changed"
+	Unchanged ChangeKind = "unchanged"
+)
+
+// A Change is the difference in a single key between two sets of secrets.
+type Change struct {
+	Key  string     `json:"key"`
+	Kind ChangeKind `json:"kind"`
+	Old  string     `json:"old,omitempty"`
+	New  string     `json:"new,omitempty"`
+}
+
+// Compares the secrets in from to the secrets in to, returning a change for every key in either, sorted by key.
+// Added keys are only in to, and removed keys are only in from.
+func Diff(from, to []SecretData) []Change {
+	fromValues := make(map[string]string, len(from))
+	for _, secret := range from {
+		fromValues[secret.Key] = secret.Value
+	}
+	toValues := make(map[string]string, len(to))
+	for _, secret := range to {
+		toValues[secret.Key] = secret.Value
+	}
+
+	changes := make([]Change, 0, len(fromValues)+len(toValues))
+	for key, newValue := range toValues {
+		oldValue, found := fromValues[key]
+		switch {
+		case !found:
+			changes = append(changes, Change{Key: key, Kind: Added, New: newValue})
+		case oldValue != newValue:
+			changes = append(changes, Change{Key: key, Kind: Changed, Old: oldValue, New: newValue})
+		default:
+			changes = append(changes, Change{Key: key, Kind: Unchanged, Old: oldValue, New: newValue})
+		}
+	}
+	for key, oldValue := range fromValues {
+		if _, found := toValues[key]; !found {
+			changes = append(changes, Change{Key: key, Kind: Removed, Old: oldValue})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+
+	return changes
+}
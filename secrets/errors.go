@@ -0,0 +1,63 @@
+package secrets
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A SecretError is a failure to read or write a single secret, where the other secrets may have still synced.
+type SecretError struct {
+	Key       string
+	Operation string
+	Err       error
+}
+
+func (err SecretError) Error() string {
+	return fmt.Sprintf("%s %s: %v", err.Operation, err.Key, err.Err)
+}
+
+func (err SecretError) Unwrap() error {
+	return err.Err
+}
+
+// SecretErrors is returned alongside the secrets that did sync when some of them failed,
+// so a caller can decide whether a partial result is good enough.
+type SecretErrors []SecretError
+
+func (errs SecretErrors) Error() string {
+	keys := make([]string, 0, len(errs))
+	for _, err := range errs {
+		keys = append(keys, err.Key)
+	}
+
+	return fmt.Sprintf("failed to sync %d secrets: %s", len(errs), strings.Join(keys, ", "))
+}
+
+func (errs SecretErrors) Unwrap() []error {
+	unwrapped := make([]error, 0, len(errs))
+	for _, err := range errs {
+		unwrapped = append(unwrapped, err)
+	}
+
+	return unwrapped
+}
+
+// Returns the errors as an error, or nil when nothing failed.
+func (errs SecretErrors) Err() error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+// Reports whether the secret with the key failed.
+func (errs SecretErrors) Has(key string) bool {
+	for _, err := range errs {
+		if err.Key == key {
+			return true
+		}
+	}
+
+	return false
+}
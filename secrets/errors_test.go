@@ -0,0 +1,34 @@
+package secrets_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/chadsmith12/dotsec/secrets"
+)
+
+func TestSecretErrors(t *testing.T) {
+	var none secrets.SecretErrors
+	if err := none.Err(); err != nil {
+		t.Errorf("Err() = %v, expected nil when nothing failed", err)
+	}
+
+	decryptErr := errors.New("failed to decrypt")
+	errs := secrets.SecretErrors{
+		{Key: "API_KEY", Operation: "read", Err: decryptErr},
+		{Key: "DB_PASSWORD", Operation: "read", Err: errors.New("forbidden")},
+	}
+	wrapped := fmt.Errorf("pulling folder: %w", errs.Err())
+
+	if !errors.Is(wrapped, decryptErr) {
+		t.Errorf("errors.Is(%v, %v) = false, expected the secrets error to be found", wrapped, decryptErr)
+	}
+	var actual secrets.SecretErrors
+	if !errors.As(wrapped, &actual) || len(actual) != 2 {
+		t.Errorf("errors.As() = %v, expected both secrets", actual)
+	}
+	if expected := "failed to sync 2 secrets: API_KEY, DB_PASSWORD"; errs.Error() != expected {
+		t.Errorf("Error() = %s, expected %s", errs.Error(), expected)
+	}
+}
@@ -3,8 +3,8 @@ package secrets
 import "strings"
 
 type SecretData struct {
-	Key   string
-	Value string
+	Key   string `json:"key"`
+	Value string `json:"value"`
 }
 
 func SecretDataFromSlice(values []string) []SecretData {
@@ -32,3 +32,8 @@ type SecretsFetcher interface {
 type SecretsSetter interface {
 	SetSecrets([]SecretData) error
 }
+
+// A SecretsRemover is implemented by the SecretsSetters that can also remove secrets from their underlying source.
+type SecretsRemover interface {
+	RemoveSecrets(keys []string) error
+}
@@ -0,0 +1,121 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrFolderNotFound           = errors.New("failed to find folder")
+	ErrCreateFolderNotSupported = errors.New("creating folders is not supported by this secret store")
+	ErrShareFolderNotSupported  = errors.New("sharing folders is not supported by this secret store")
+)
+
+// A Folder is a container of secrets inside of a SecretStore.
+// The ID is whatever the store uses to uniquely identify the folder, and may be the same as the Name.
+type Folder struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// A SecretRef points at a single secret inside of a folder without holding its value.
+type SecretRef struct {
+	ID  string `json:"id"`
+	Key string `json:"key"`
+}
+
+// A SecretStore is an interface you implement for the different secret managers dotsec can pull from and push to.
+// Providers that aren't built in can be added as dotsec-provider-<name> executables, see the plugin package.
+type SecretStore interface {
+	// ListFolders returns every folder the user has access to in the store.
+	ListFolders() ([]Folder, error)
+	// GetFolder finds a folder by its name. Returns ErrFolderNotFound if the folder does not exist.
+	GetFolder(name string) (Folder, error)
+	// ListSecrets returns a reference to every secret in the folder without reading their values.
+	ListSecrets(folder Folder) ([]SecretRef, error)
+	// GetSecrets reads the key and value of every secret in the folder.
+	GetSecrets(folder Folder) ([]SecretData, error)
+	CreateSecret(folder Folder, secret SecretData) error
+	UpdateSecret(folder Folder, ref SecretRef, secret SecretData) error
+	DeleteSecret(folder Folder, ref SecretRef) error
+}
+
+// A FolderCreator is implemented by the SecretStores that can create top level folders. For the stores whose
+// folders are named by their whole path, such as Vault, it creates the folder at the path.
+type FolderCreator interface {
+	CreateFolder(name string) (Folder, error)
+}
+
+// A FolderSharer is implemented by the SecretStores that can share a folder with groups of users.
+type FolderSharer interface {
+	// ShareFolder lets the groups, by their names, read and update the secrets in the folder.
+	ShareFolder(folder Folder, groups []string) error
+}
+
+// A Flusher is implemented by the SecretStores that hold on to the secrets written to them instead of writing each one
+// right away, such as to write a whole push as a single version. Nothing is written until Flush is called.
+type Flusher interface {
+	// Flush writes every change made since the last Flush.
+	Flush() error
+}
+
+// Writes the changes the store is holding on to. Does nothing for the stores that write every secret right away.
+func Flush(store SecretStore) error {
+	flusher, ok := store.(Flusher)
+	if !ok {
+		return nil
+	}
+
+	return flusher.Flush()
+}
+
+// Creates the folder at path. When the path has a parent, such as Team/Service/Dev, and the store has subfolders,
+// the folder is created inside of the parent, which has to exist already.
+func CreateFolder(store SecretStore, path string) (Folder, error) {
+	trimmed := strings.TrimRight(path, "/")
+	index := strings.LastIndex(trimmed, "/")
+	name := trimmed[index+1:]
+	if name == "" {
+		return Folder{}, fmt.Errorf("invalid folder name: %s", path)
+	}
+	subfolderStore, nested := store.(SubfolderStore)
+	if index <= 0 || !nested {
+		creator, ok := store.(FolderCreator)
+		if !ok {
+			return Folder{}, ErrCreateFolderNotSupported
+		}
+		if index <= 0 {
+			return creator.CreateFolder(name)
+		}
+		return creator.CreateFolder(trimmed)
+	}
+
+	parent, err := store.GetFolder(trimmed[:index])
+	if err != nil {
+		return Folder{}, fmt.Errorf("parent folder %s: %w", trimmed[:index], err)
+	}
+
+	return subfolderStore.CreateSubfolder(parent, name)
+}
+
+// Finds the folder by name in the store and reads all of the secrets in it.
+func GetSecretsByFolder(store SecretStore, folderName string) ([]SecretData, error) {
+	folder, err := store.GetFolder(folderName)
+	if err != nil {
+		return []SecretData{}, err
+	}
+
+	return store.GetSecrets(folder)
+}
+
+// Finds the secret with the matching key in the refs.
+func FindSecretRef(refs []SecretRef, key string) (SecretRef, bool) {
+	for _, ref := range refs {
+		if ref.Key == key {
+			return ref, true
+		}
+	}
+
+	return SecretRef{}, false
+}
@@ -0,0 +1,49 @@
+package secrets_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/chadsmith12/dotsec/secrets"
+)
+
+func TestDiff(t *testing.T) {
+	remote := []secrets.SecretData{{Key: "API_KEY", Value: "old"}, {Key: "DB_PASSWORD", Value: "hunter2"}, {Key: "REMOVED", Value: "gone"}}
+	local := []secrets.SecretData{{Key: "NEW_KEY", Value: "new"}, {Key: "DB_PASSWORD", Value: "hunter2"}, {Key: "API_KEY", Value: "new"}}
+
+	actual := secrets.Diff(remote, local)
+	expected := []secrets.Change{
+		{Key: "API_KEY", Kind: secrets.Changed, Old: "old", New: "new"},
+		{Key: "DB_PASSWORD", Kind: secrets.Unchanged, Old: "hunter2", New: "hunter2"},
+		{Key: "NEW_KEY", Kind: secrets.Added, New: "new"},
+		{Key: "REMOVED", Kind: secrets.Removed, Old: "gone"},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Diff() = %v, expected %v", actual, expected)
+	}
+}
+
+func TestCreateFolder(t *testing.T) {
+	store := newFakeTreeStore()
+
+	folder, err := secrets.CreateFolder(store, "Service/Payments/")
+	if err != nil {
+		t.Fatalf("CreateFolder failed: %v", err)
+	}
+	if folder.Name != "Payments" || store.parents[folder.ID] != "root" {
+		t.Errorf("CreateFolder() = %v, expected Payments inside of Service", folder)
+	}
+
+	folder, err = secrets.CreateFolder(store, "NewService")
+	if err != nil || store.parents[folder.ID] != "" {
+		t.Errorf("CreateFolder() = %v, %v, expected a top level folder", folder, err)
+	}
+
+	if _, err := secrets.CreateFolder(store, "Missing/Dev"); !errors.Is(err, secrets.ErrFolderNotFound) {
+		t.Errorf("CreateFolder() error = %v, expected the missing parent to fail", err)
+	}
+	if _, err := secrets.CreateFolder(struct{ secrets.SecretStore }{store}, "NewService"); !errors.Is(err, secrets.ErrCreateFolderNotSupported) {
+		t.Errorf("CreateFolder() error = %v, expected ErrCreateFolderNotSupported", err)
+	}
+}
@@ -0,0 +1,226 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrSubfoldersNotSupported = errors.New("subfolders are not supported by this secret store")
+
+// A SubfolderStore is implemented by the SecretStores that have nested folders, which recursive syncs use.
+type SubfolderStore interface {
+	// ListSubfolders returns the folders directly inside of the folder.
+	ListSubfolders(folder Folder) ([]Folder, error)
+	// CreateSubfolder creates a folder named name inside of parent.
+	CreateSubfolder(parent Folder, name string) (Folder, error)
+}
+
+// A KeyFormat is how the names of the subfolders a secret is in are joined with its key when syncing recursively.
+type KeyFormat struct {
+	Separator string
+	// upper cases the joined key, which can't be split back into folder names since they may have the separator in them
+	Upper bool
+}
+
+var (
+	// Database/ConnectionString is synced as Database:ConnectionString, the same as nested .NET configuration.
+	DotnetKeys = KeyFormat{Separator: ":"}
+	// Database/ConnectionString is synced as DATABASE_CONNECTIONSTRING.
+	EnvKeys = KeyFormat{Separator: "_", Upper: true}
+)
+
+// Joins the names of the subfolders with the key. A key that isn't in a subfolder is left as it is.
+func (format KeyFormat) Join(path []string, key string) string {
+	if len(path) == 0 {
+		return key
+	}
+
+	joined := strings.Join(append(append([]string{}, path...), key), format.Separator)
+	if format.Upper {
+		joined = strings.ToUpper(strings.ReplaceAll(joined, " ", "_"))
+	}
+
+	return joined
+}
+
+// Where a secret in a FolderTree is.
+type SecretLocation struct {
+	Folder Folder
+	Ref    SecretRef
+}
+
+// A FolderTree is the secrets in a folder, and in all of its subfolders when it is read recursively,
+// using the keys from the KeyFormat so they can be synced as a single folder.
+type FolderTree struct {
+	Root    Folder
+	Secrets []SecretData
+	// where each secret is by its key in Secrets
+	Locations map[string]SecretLocation
+	// called with each subfolder WriteSecret creates, before any secret is written to it
+	FolderCreated func(folder Folder, path []string)
+	format        KeyFormat
+	recursive     bool
+	// the subfolders by their path below the root, lower cased and joined with slashes
+	subfolders map[string]Folder
+}
+
+type treeFolder struct {
+	folder Folder
+	path   []string
+}
+
+// Reads the secrets in root, and in every subfolder below it when recursive is set. Secrets that fail to read are
+// returned as SecretErrors along with the tree. Returns ErrSubfoldersNotSupported when the store has no subfolders.
+func ReadFolderTree(store SecretStore, root Folder, format KeyFormat, recursive bool) (*FolderTree, error) {
+	subfolderStore, ok := store.(SubfolderStore)
+	if recursive && !ok {
+		return nil, ErrSubfoldersNotSupported
+	}
+
+	tree := &FolderTree{
+		Root:       root,
+		Secrets:    []SecretData{},
+		Locations:  map[string]SecretLocation{},
+		format:     format,
+		recursive:  recursive,
+		subfolders: map[string]Folder{},
+	}
+	var failures SecretErrors
+	visited := map[string]bool{}
+	queue := []treeFolder{{folder: root}}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if visited[current.folder.ID] {
+			continue
+		}
+		visited[current.folder.ID] = true
+
+		refs, err := store.ListSecrets(current.folder)
+		if err != nil {
+			return nil, fmt.Errorf("listing secrets in %s: %w", current.folder.Name, err)
+		}
+		for _, ref := range refs {
+			tree.Locations[format.Join(current.path, ref.Key)] = SecretLocation{Folder: current.folder, Ref: ref}
+		}
+
+		secretsData, err := store.GetSecrets(current.folder)
+		var readFailures SecretErrors
+		if err != nil && !errors.As(err, &readFailures) {
+			return nil, fmt.Errorf("reading secrets in %s: %w", current.folder.Name, err)
+		}
+		for _, failure := range readFailures {
+			failure.Key = format.Join(current.path, failure.Key)
+			failures = append(failures, failure)
+		}
+		for _, secret := range secretsData {
+			tree.Secrets = append(tree.Secrets, SecretData{Key: format.Join(current.path, secret.Key), Value: secret.Value})
+		}
+
+		if !recursive {
+			break
+		}
+		children, err := subfolderStore.ListSubfolders(current.folder)
+		if err != nil {
+			return nil, fmt.Errorf("listing subfolders of %s: %w", current.folder.Name, err)
+		}
+		for _, child := range children {
+			path := append(append([]string{}, current.path...), child.Name)
+			tree.subfolders[subfolderKey(path)] = child
+			queue = append(queue, treeFolder{folder: child, path: path})
+		}
+	}
+
+	return tree, failures.Err()
+}
+
+// Writes the secret to where it is in the tree. A new secret is created in the subfolder its key is prefixed with,
+// creating the subfolders that don't exist yet when the KeyFormat can be split, or in the root.
+// Returns the operation that was done, create or update.
+func (tree *FolderTree) WriteSecret(store SecretStore, secret SecretData) (string, error) {
+	if location, found := tree.Locations[secret.Key]; found {
+		return "update", store.UpdateSecret(location.Folder, location.Ref, SecretData{Key: location.Ref.Key, Value: secret.Value})
+	}
+
+	folder, key, err := tree.placeSecret(store, secret.Key)
+	if err != nil {
+		return "create", err
+	}
+
+	return "create", store.CreateSecret(folder, SecretData{Key: key, Value: secret.Value})
+}
+
+// Deletes the secret with the key from where it is in the tree.
+func (tree *FolderTree) DeleteSecret(store SecretStore, key string) error {
+	location, found := tree.Locations[key]
+	if !found {
+		return fmt.Errorf("%s is not in %s", key, tree.Root.Name)
+	}
+
+	return store.DeleteSecret(location.Folder, location.Ref)
+}
+
+// Finds the keys in the tree that aren't in the secrets, which are the secrets that would be left behind by a push.
+func (tree *FolderTree) StaleKeys(secretsData []SecretData) []string {
+	keys := make(map[string]bool, len(secretsData))
+	for _, secret := range secretsData {
+		keys[secret.Key] = true
+	}
+
+	stale := make([]string, 0)
+	for _, secret := range tree.Secrets {
+		if !keys[secret.Key] {
+			stale = append(stale, secret.Key)
+		}
+	}
+
+	return stale
+}
+
+// Finds the folder a new secret goes in and its key inside of that folder.
+func (tree *FolderTree) placeSecret(store SecretStore, key string) (Folder, string, error) {
+	if !tree.recursive {
+		return tree.Root, key, nil
+	}
+	if tree.format.Upper {
+		return tree.existingSubfolder(key)
+	}
+
+	segments := strings.Split(key, tree.format.Separator)
+	folder := tree.Root
+	for i := range segments[:len(segments)-1] {
+		path := segments[:i+1]
+		subfolder, found := tree.subfolders[subfolderKey(path)]
+		if !found {
+			var err error
+			if subfolder, err = store.(SubfolderStore).CreateSubfolder(folder, segments[i]); err != nil {
+				return Folder{}, "", fmt.Errorf("creating folder %s: %w", strings.Join(path, "/"), err)
+			}
+			tree.subfolders[subfolderKey(path)] = subfolder
+			if tree.FolderCreated != nil {
+				tree.FolderCreated(subfolder, path)
+			}
+		}
+		folder = subfolder
+	}
+
+	return folder, segments[len(segments)-1], nil
+}
+
+// Finds the deepest existing subfolder the key is prefixed with, or the root when there isn't one.
+func (tree *FolderTree) existingSubfolder(key string) (Folder, string, error) {
+	folder, name, longest := tree.Root, key, 0
+	for path, subfolder := range tree.subfolders {
+		prefix := tree.format.Join(strings.Split(path, "/"), "")
+		if len(prefix) > longest && len(key) > len(prefix) && strings.EqualFold(key[:len(prefix)], prefix) {
+			folder, name, longest = subfolder, key[len(prefix):], len(prefix)
+		}
+	}
+
+	return folder, name, nil
+}
+
+func subfolderKey(path []string) string {
+	return strings.ToLower(strings.Join(path, "/"))
+}
@@ -0,0 +1,234 @@
+package secrets_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/chadsmith12/dotsec/secrets"
+)
+
+// fakeTreeStore keeps its folders by id, with the secrets of each folder in the values.
+type fakeTreeStore struct {
+	names   map[string]string
+	parents map[string]string
+	values  map[string]map[string]string
+	nextId  int
+}
+
+func newFakeTreeStore() *fakeTreeStore {
+	return &fakeTreeStore{
+		names:   map[string]string{"root": "Service", "db": "Database", "auth": "Auth", "tokens": "Tokens"},
+		parents: map[string]string{"db": "root", "auth": "root", "tokens": "auth"},
+		values: map[string]map[string]string{
+			"root":   {"ApiKey": "root-key"},
+			"db":     {"ConnectionString": "Server=db"},
+			"auth":   {"Authority": "https://login"},
+			"tokens": {"SigningKey": "signing"},
+		},
+	}
+}
+
+func (store *fakeTreeStore) ListFolders() ([]secrets.Folder, error) { return nil, nil }
+
+func (store *fakeTreeStore) GetFolder(name string) (secrets.Folder, error) {
+	for id, folderName := range store.names {
+		if folderName == name {
+			return secrets.Folder{ID: id, Name: name}, nil
+		}
+	}
+
+	return secrets.Folder{}, secrets.ErrFolderNotFound
+}
+
+func (store *fakeTreeStore) CreateFolder(name string) (secrets.Folder, error) {
+	return store.CreateSubfolder(secrets.Folder{}, name)
+}
+
+func (store *fakeTreeStore) ListSecrets(folder secrets.Folder) ([]secrets.SecretRef, error) {
+	refs := []secrets.SecretRef{}
+	for key := range store.values[folder.ID] {
+		refs = append(refs, secrets.SecretRef{ID: folder.ID + "/" + key, Key: key})
+	}
+
+	return refs, nil
+}
+
+func (store *fakeTreeStore) GetSecrets(folder secrets.Folder) ([]secrets.SecretData, error) {
+	secretsData := []secrets.SecretData{}
+	for key, value := range store.values[folder.ID] {
+		secretsData = append(secretsData, secrets.SecretData{Key: key, Value: value})
+	}
+
+	return secretsData, nil
+}
+
+func (store *fakeTreeStore) CreateSecret(folder secrets.Folder, secret secrets.SecretData) error {
+	store.values[folder.ID][secret.Key] = secret.Value
+	return nil
+}
+
+func (store *fakeTreeStore) UpdateSecret(folder secrets.Folder, ref secrets.SecretRef, secret secrets.SecretData) error {
+	if _, found := store.values[folder.ID][ref.Key]; !found {
+		return fmt.Errorf("%s not found", ref.Key)
+	}
+	store.values[folder.ID][ref.Key] = secret.Value
+	return nil
+}
+
+func (store *fakeTreeStore) DeleteSecret(folder secrets.Folder, ref secrets.SecretRef) error {
+	delete(store.values[folder.ID], ref.Key)
+	return nil
+}
+
+func (store *fakeTreeStore) ListSubfolders(folder secrets.Folder) ([]secrets.Folder, error) {
+	subfolders := []secrets.Folder{}
+	for id, parent := range store.parents {
+		if parent == folder.ID {
+			subfolders = append(subfolders, secrets.Folder{ID: id, Name: store.names[id]})
+		}
+	}
+
+	return subfolders, nil
+}
+
+func (store *fakeTreeStore) CreateSubfolder(parent secrets.Folder, name string) (secrets.Folder, error) {
+	store.nextId++
+	id := fmt.Sprintf("new-%d", store.nextId)
+	store.names[id] = name
+	store.parents[id] = parent.ID
+	store.values[id] = map[string]string{}
+
+	return secrets.Folder{ID: id, Name: name}, nil
+}
+
+var rootFolder = secrets.Folder{ID: "root", Name: "Service"}
+
+func treeKeys(tree *secrets.FolderTree) []string {
+	keys := []string{}
+	for _, secret := range tree.Secrets {
+		keys = append(keys, secret.Key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func TestReadFolderTree(t *testing.T) {
+	store := newFakeTreeStore()
+	tests := []struct {
+		format    secrets.KeyFormat
+		recursive bool
+		expected  []string
+	}{
+		{secrets.DotnetKeys, false, []string{"ApiKey"}},
+		{secrets.DotnetKeys, true, []string{"ApiKey", "Auth:Authority", "Auth:Tokens:SigningKey", "Database:ConnectionString"}},
+		{secrets.EnvKeys, true, []string{"ApiKey", "AUTH_AUTHORITY", "AUTH_TOKENS_SIGNINGKEY", "DATABASE_CONNECTIONSTRING"}},
+	}
+	for _, test := range tests {
+		tree, err := secrets.ReadFolderTree(store, rootFolder, test.format, test.recursive)
+		if err != nil {
+			t.Fatalf("ReadFolderTree failed: %v", err)
+		}
+		sort.Strings(test.expected)
+		if keys := treeKeys(tree); !reflect.DeepEqual(keys, test.expected) {
+			t.Errorf("ReadFolderTree() keys = %v, expected %v", keys, test.expected)
+		}
+	}
+
+	if _, err := secrets.ReadFolderTree(struct{ secrets.SecretStore }{store}, rootFolder, secrets.DotnetKeys, true); !errors.Is(err, secrets.ErrSubfoldersNotSupported) {
+		t.Errorf("ReadFolderTree() error = %v, expected ErrSubfoldersNotSupported", err)
+	}
+}
+
+func TestFolderTree_WriteSecret(t *testing.T) {
+	store := newFakeTreeStore()
+	tree, _ := secrets.ReadFolderTree(store, rootFolder, secrets.DotnetKeys, true)
+	created := []string{}
+	tree.FolderCreated = func(folder secrets.Folder, path []string) {
+		if len(store.values[folder.ID]) != 0 {
+			t.Errorf("FolderCreated(%s) called after a secret was written to it", folder.Name)
+		}
+		created = append(created, strings.Join(path, "/"))
+	}
+
+	writes := []secrets.SecretData{
+		{Key: "Database:ConnectionString", Value: "Server=new"},
+		{Key: "Redis:Cache:Host", Value: "redis"},
+		{Key: "Redis:Password", Value: "p@ss"},
+		{Key: "NewKey", Value: "value"},
+	}
+	for _, secret := range writes {
+		if _, err := tree.WriteSecret(store, secret); err != nil {
+			t.Fatalf("WriteSecret(%s) failed: %v", secret.Key, err)
+		}
+	}
+
+	if store.values["db"]["ConnectionString"] != "Server=new" || store.values["root"]["NewKey"] != "value" {
+		t.Errorf("values = %v, expected the existing secret updated and the new one in the root", store.values)
+	}
+	if store.nextId != 2 {
+		t.Errorf("created %d folders, expected Redis and Redis/Cache to be created once", store.nextId)
+	}
+	if expected := []string{"Redis", "Redis/Cache"}; !reflect.DeepEqual(created, expected) {
+		t.Errorf("FolderCreated paths = %v, expected %v", created, expected)
+	}
+	reread, _ := secrets.ReadFolderTree(store, rootFolder, secrets.DotnetKeys, true)
+	for _, key := range []string{"Redis:Cache:Host", "Redis:Password"} {
+		if _, found := reread.Locations[key]; !found {
+			t.Errorf("%s not found after writing it, keys = %v", key, treeKeys(reread))
+		}
+	}
+}
+
+func TestFolderTree_WriteEnvSecret(t *testing.T) {
+	store := newFakeTreeStore()
+	tree, _ := secrets.ReadFolderTree(store, rootFolder, secrets.EnvKeys, true)
+
+	for _, secret := range []secrets.SecretData{
+		{Key: "DATABASE_CONNECTIONSTRING", Value: "Server=new"},
+		{Key: "AUTH_TOKENS_AUDIENCE", Value: "api"},
+		{Key: "REDIS_HOST", Value: "redis"},
+	} {
+		if _, err := tree.WriteSecret(store, secret); err != nil {
+			t.Fatalf("WriteSecret(%s) failed: %v", secret.Key, err)
+		}
+	}
+
+	if store.values["db"]["ConnectionString"] != "Server=new" {
+		t.Errorf("values = %v, expected the existing secret to keep its key", store.values["db"])
+	}
+	if store.values["tokens"]["AUDIENCE"] != "api" {
+		t.Errorf("values = %v, expected the new secret in the deepest matching subfolder", store.values["tokens"])
+	}
+	if store.values["root"]["REDIS_HOST"] != "redis" || store.nextId != 0 {
+		t.Errorf("values = %v, expected a key without a matching subfolder in the root", store.values["root"])
+	}
+}
+
+func TestFolderTree_StaleKeys(t *testing.T) {
+	store := newFakeTreeStore()
+	tree, _ := secrets.ReadFolderTree(store, rootFolder, secrets.DotnetKeys, true)
+
+	stale := tree.StaleKeys([]secrets.SecretData{{Key: "ApiKey"}, {Key: "Auth:Authority"}, {Key: "NewKey"}})
+	sort.Strings(stale)
+	expected := []string{"Auth:Tokens:SigningKey", "Database:ConnectionString"}
+	if !reflect.DeepEqual(stale, expected) {
+		t.Errorf("StaleKeys() = %v, expected %v", stale, expected)
+	}
+
+	for _, key := range stale {
+		if err := tree.DeleteSecret(store, key); err != nil {
+			t.Fatalf("DeleteSecret(%s) failed: %v", key, err)
+		}
+	}
+	if len(store.values["db"]) != 0 || len(store.values["tokens"]) != 0 {
+		t.Errorf("values = %v, expected the stale secrets to be deleted from their subfolders", store.values)
+	}
+	if err := tree.DeleteSecret(store, "Missing"); err == nil || !strings.Contains(err.Error(), "Missing") {
+		t.Errorf("DeleteSecret() error = %v, expected it to fail for a key that isn't in the tree", err)
+	}
+}
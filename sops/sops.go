@@ -0,0 +1,544 @@
+package sops
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/chadsmith12/dotsec/secrets"
+	gosops "github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/aes"
+	sopsage "github.com/getsops/sops/v3/age"
+	"github.com/getsops/sops/v3/cmd/sops/common"
+	"github.com/getsops/sops/v3/config"
+	"github.com/getsops/sops/v3/keys"
+	"github.com/getsops/sops/v3/pgp"
+	"github.com/getsops/sops/v3/shamir"
+	"github.com/getsops/sops/v3/version"
+)
+
+const keySeparator = ":"
+
+var (
+	InvalidFolderErr = secrets.ErrFolderNotFound
+	NoDataKeyErr     = errors.New("could not decrypt the data key with any of the configured age identities or pgp key")
+)
+
+// SopsStore reads and writes SOPS encrypted YAML or JSON files, where each file is a folder of secrets.
+// The files are read and written with the sops library, and the data key is decrypted with the configured
+// age identities or pgp key instead of the ones sops would look up itself.
+type SopsStore struct {
+	root       string
+	identities sopsage.ParsedIdentities
+	pgpKey     *crypto.Key
+}
+
+// The decrypted contents of a SOPS file.
+type sopsFile struct {
+	path    string
+	store   common.Store
+	tree    *gosops.Tree
+	dataKey []byte
+}
+
+// Initializes a SopsStore that resolves files relative to root.
+// ageKeys is the contents of an age key file, and the armored pgp key is optional.
+func NewStore(root, ageKeys, armoredPgpKey, passphrase string) (*SopsStore, error) {
+	store := &SopsStore{root: root}
+	if strings.TrimSpace(ageKeys) != "" {
+		identities, err := age.ParseIdentities(strings.NewReader(ageKeys))
+		if err != nil {
+			return nil, fmt.Errorf("reading age identities: %w", err)
+		}
+		store.identities = identities
+	}
+
+	if armoredPgpKey != "" {
+		key, err := crypto.NewKeyFromArmored(armoredPgpKey)
+		if err != nil {
+			return nil, fmt.Errorf("reading private key: %w", err)
+		}
+		if locked, _ := key.IsLocked(); locked {
+			key, err = key.Unlock([]byte(passphrase))
+			if err != nil {
+				return nil, fmt.Errorf("unlocking private key: %w", err)
+			}
+		}
+		store.pgpKey = key
+	}
+
+	if len(store.identities) == 0 && store.pgpKey == nil {
+		return nil, errors.New("no age identities or pgp key configured")
+	}
+
+	return store, nil
+}
+
+// Lists every SOPS encrypted YAML or JSON file under the root, skipping hidden directories.
+func (store *SopsStore) ListFolders() ([]secrets.Folder, error) {
+	folders := make([]secrets.Folder, 0)
+	err := filepath.WalkDir(store.root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != store.root && (strings.HasPrefix(entry.Name(), ".") || entry.Name() == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isSopsExtension(path) {
+			return nil
+		}
+		if _, _, err := loadTree(path); err != nil {
+			return nil
+		}
+
+		name, err := filepath.Rel(store.root, path)
+		if err != nil {
+			return err
+		}
+		folders = append(folders, secrets.Folder{ID: filepath.ToSlash(name), Name: filepath.ToSlash(name)})
+		return nil
+	})
+
+	return folders, err
+}
+
+// Finds the SOPS file at the path relative to the root. A file that does not exist yet is still a folder
+// when a creation rule in .sops.yaml matches it, and it will be created the first time a secret is pushed.
+func (store *SopsStore) GetFolder(name string) (secrets.Folder, error) {
+	folder := secrets.Folder{ID: filepath.ToSlash(filepath.Clean(name)), Name: name}
+	if !isSopsExtension(folder.ID) {
+		return secrets.Folder{}, fmt.Errorf("%w: %s is not a yaml or json file", InvalidFolderErr, name)
+	}
+
+	path := store.filePath(folder.ID)
+	if _, err := os.Stat(path); err == nil {
+		if _, _, err := loadTree(path); err != nil {
+			return secrets.Folder{}, fmt.Errorf("%s is not encrypted with sops: %w", name, err)
+		}
+		return folder, nil
+	}
+
+	if _, err := creationRule(path); err != nil {
+		return secrets.Folder{}, InvalidFolderErr
+	}
+
+	return folder, nil
+}
+
+// Lists every value in the file. Nested keys are joined with a colon, the same way dotnet user-secrets does.
+func (store *SopsStore) ListSecrets(folder secrets.Folder) ([]secrets.SecretRef, error) {
+	secretData, err := store.GetSecrets(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]secrets.SecretRef, 0, len(secretData))
+	for _, secret := range secretData {
+		refs = append(refs, secrets.SecretRef{ID: secret.Key, Key: secret.Key})
+	}
+
+	return refs, nil
+}
+
+// Decrypts every value in the file.
+func (store *SopsStore) GetSecrets(folder secrets.Folder) ([]secrets.SecretData, error) {
+	file, err := store.open(folder)
+	if err != nil {
+		return []secrets.SecretData{}, err
+	}
+
+	secretData := make([]secrets.SecretData, 0)
+	flatten(file.tree.Branches[0], "", &secretData)
+
+	return secretData, nil
+}
+
+func (store *SopsStore) CreateSecret(folder secrets.Folder, secret secrets.SecretData) error {
+	return store.modify(folder, func(file *sopsFile) error {
+		return file.setValue(strings.Split(secret.Key, keySeparator), secret.Value)
+	})
+}
+
+func (store *SopsStore) UpdateSecret(folder secrets.Folder, ref secrets.SecretRef, secret secrets.SecretData) error {
+	return store.modify(folder, func(file *sopsFile) error {
+		return file.setValue(strings.Split(ref.ID, keySeparator), secret.Value)
+	})
+}
+
+func (store *SopsStore) DeleteSecret(folder secrets.Folder, ref secrets.SecretRef) error {
+	return store.modify(folder, func(file *sopsFile) error {
+		return file.removeValue(strings.Split(ref.ID, keySeparator))
+	})
+}
+
+func (store *SopsStore) filePath(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+
+	return filepath.Join(store.root, filepath.FromSlash(name))
+}
+
+// Opens and decrypts the file, or creates an empty one from the .sops.yaml creation rules when it does not exist.
+func (store *SopsStore) open(folder secrets.Folder) (*sopsFile, error) {
+	path := store.filePath(folder.ID)
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return store.newFile(path)
+	}
+
+	fileStore, tree, err := loadTree(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not encrypted with sops: %w", folder.Name, err)
+	}
+
+	tree.Metadata.DataKey, err = store.decryptDataKey(tree.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	// sops checks the mac of the decrypted values, which catches values that were changed or moved between keys
+	dataKey, err := common.DecryptTree(common.DecryptTreeOpts{Tree: tree, Cipher: aes.NewCipher()})
+	if err != nil {
+		return nil, fmt.Errorf("decrypting %s: %w", folder.Name, err)
+	}
+	if len(tree.Branches) == 0 {
+		tree.Branches = gosops.TreeBranches{gosops.TreeBranch{}}
+	}
+
+	return &sopsFile{path: path, store: fileStore, tree: tree, dataKey: dataKey}, nil
+}
+
+func (store *SopsStore) modify(folder secrets.Folder, change func(*sopsFile) error) error {
+	file, err := store.open(folder)
+	if err != nil {
+		return err
+	}
+
+	if err := change(file); err != nil {
+		return err
+	}
+
+	return file.save()
+}
+
+// Encrypts every value with a fresh iv, updates the mac and writes the file.
+func (file *sopsFile) save() error {
+	if err := common.EncryptTree(common.EncryptTreeOpts{Tree: file.tree, Cipher: aes.NewCipher(), DataKey: file.dataKey}); err != nil {
+		return fmt.Errorf("encrypting %s: %w", file.path, err)
+	}
+
+	data, err := file.store.EmitEncryptedFile(*file.tree)
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", file.path, err)
+	}
+
+	return os.WriteFile(file.path, data, 0600)
+}
+
+// Decrypts the data key with the first master key in each key group that one of our keys can decrypt.
+// Files with more than one key group have the data key split between them, which sops combines again.
+func (store *SopsStore) decryptDataKey(metadata gosops.Metadata) ([]byte, error) {
+	parts := make([][]byte, 0, len(metadata.KeyGroups))
+	for _, group := range metadata.KeyGroups {
+		for _, key := range group {
+			if part, err := store.decryptKey(key); err == nil {
+				parts = append(parts, part)
+				break
+			}
+		}
+	}
+
+	if len(metadata.KeyGroups) == 1 && len(parts) == 1 {
+		return parts[0], nil
+	}
+	if len(metadata.KeyGroups) > 1 && len(parts) >= max(metadata.ShamirThreshold, 1) {
+		dataKey, err := shamir.Combine(parts)
+		if err != nil {
+			return nil, fmt.Errorf("combining the data key from its key groups: %w", err)
+		}
+		return dataKey, nil
+	}
+
+	return nil, NoDataKeyErr
+}
+
+// Decrypts the data key with our age identities or pgp key. Other master keys, such as cloud KMS keys,
+// are decrypted by sops with the credentials it finds itself.
+func (store *SopsStore) decryptKey(key keys.MasterKey) ([]byte, error) {
+	switch key := key.(type) {
+	case *sopsage.MasterKey:
+		if len(store.identities) == 0 {
+			return nil, NoDataKeyErr
+		}
+		store.identities.ApplyToMasterKey(key)
+		return key.Decrypt()
+	case *pgp.MasterKey:
+		if store.pgpKey == nil {
+			return nil, NoDataKeyErr
+		}
+		keyRing, err := crypto.NewKeyRing(store.pgpKey)
+		if err != nil {
+			return nil, err
+		}
+		message, err := crypto.NewPGPMessageFromArmored(key.EncryptedKey)
+		if err != nil {
+			return nil, err
+		}
+		plain, err := keyRing.Decrypt(message, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		return plain.GetBinary(), nil
+	}
+
+	return key.Decrypt()
+}
+
+// Finds the first creation rule in the nearest .sops.yaml that matches the path.
+func creationRule(path string) (*config.Config, error) {
+	absolute, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	configPath, err := config.FindConfigFile(absolute)
+	if err != nil {
+		return nil, fmt.Errorf("no .sops.yaml found for %s", path)
+	}
+	rule, err := config.LoadCreationRuleForFile(configPath, absolute, nil)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil || len(rule.KeyGroups) == 0 {
+		return nil, fmt.Errorf("no creation rule in %s matches %s", configPath, path)
+	}
+
+	return rule, nil
+}
+
+// Creates an empty file with a new data key encrypted for the keys of the matching creation rule,
+// splitting it between the key groups when the rule has more than one, the same as sops does.
+func (store *SopsStore) newFile(path string) (*sopsFile, error) {
+	rule, err := creationRule(path)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := gosops.Metadata{
+		KeyGroups:               rule.KeyGroups,
+		ShamirThreshold:         rule.ShamirThreshold,
+		UnencryptedSuffix:       rule.UnencryptedSuffix,
+		EncryptedSuffix:         rule.EncryptedSuffix,
+		UnencryptedRegex:        rule.UnencryptedRegex,
+		EncryptedRegex:          rule.EncryptedRegex,
+		UnencryptedCommentRegex: rule.UnencryptedCommentRegex,
+		EncryptedCommentRegex:   rule.EncryptedCommentRegex,
+		MACOnlyEncrypted:        rule.MACOnlyEncrypted,
+		Version:                 version.Version,
+	}
+	if metadata.UnencryptedSuffix == "" && metadata.EncryptedSuffix == "" && metadata.UnencryptedRegex == "" &&
+		metadata.EncryptedRegex == "" && metadata.UnencryptedCommentRegex == "" && metadata.EncryptedCommentRegex == "" {
+		metadata.UnencryptedSuffix = gosops.DefaultUnencryptedSuffix
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	parts := [][]byte{dataKey}
+	if len(metadata.KeyGroups) > 1 {
+		if metadata.ShamirThreshold == 0 {
+			metadata.ShamirThreshold = len(metadata.KeyGroups)
+		}
+		if parts, err = shamir.Split(dataKey, len(metadata.KeyGroups), metadata.ShamirThreshold); err != nil {
+			return nil, fmt.Errorf("splitting the data key: %w", err)
+		}
+	}
+	for i, group := range metadata.KeyGroups {
+		for _, key := range group {
+			if err := store.encryptKey(key, parts[i]); err != nil {
+				return nil, fmt.Errorf("encrypting data key for %s: %w", key.ToString(), err)
+			}
+		}
+	}
+
+	tree := &gosops.Tree{Metadata: metadata, Branches: gosops.TreeBranches{gosops.TreeBranch{}}, FilePath: path}
+	fileStore := common.DefaultStoreForPath(config.NewStoresConfig(), path)
+
+	return &sopsFile{path: path, store: fileStore, tree: tree, dataKey: dataKey}, nil
+}
+
+// Encrypts the data key for a pgp fingerprint with our own key, or with the public key from the users gpg keyring.
+// Other master keys encrypt it themselves.
+func (store *SopsStore) encryptKey(key keys.MasterKey, dataKey []byte) error {
+	pgpKey, ok := key.(*pgp.MasterKey)
+	if !ok {
+		return key.Encrypt(dataKey)
+	}
+
+	publicKey, err := store.pgpPublicKey(pgpKey.Fingerprint)
+	if err != nil {
+		return err
+	}
+	keyRing, err := crypto.NewKeyRing(publicKey)
+	if err != nil {
+		return err
+	}
+	message, err := keyRing.Encrypt(crypto.NewPlainMessage(dataKey), nil)
+	if err != nil {
+		return err
+	}
+	armored, err := message.GetArmored()
+	if err != nil {
+		return err
+	}
+	pgpKey.SetEncryptedDataKey([]byte(armored + "\n"))
+
+	return nil
+}
+
+// Uses our own key when the fingerprint matches it, otherwise exports the public key from the users gpg keyring.
+func (store *SopsStore) pgpPublicKey(fingerprint string) (*crypto.Key, error) {
+	if store.pgpKey != nil && strings.EqualFold(store.pgpKey.GetFingerprint(), fingerprint) {
+		return store.pgpKey.ToPublic()
+	}
+
+	exported, err := exec.Command("gpg", "--export", fingerprint).Output()
+	if err != nil || len(exported) == 0 {
+		return nil, fmt.Errorf("no public key found for %s", fingerprint)
+	}
+
+	return crypto.NewKey(exported)
+}
+
+// Reads the encrypted file with the sops store for its extension, without decrypting it.
+func loadTree(path string) (common.Store, *gosops.Tree, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fileStore := common.DefaultStoreForPath(config.NewStoresConfig(), path)
+	tree, err := fileStore.LoadEncryptedFile(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	tree.FilePath = path
+
+	return fileStore, &tree, nil
+}
+
+// Sets the value at the path, creating any missing sections along the way.
+func (file *sopsFile) setValue(path []string, value string) error {
+	treePath, err := treePath(file.tree.Branches[0], path, true)
+	if err != nil {
+		return err
+	}
+
+	file.tree.Branches[0], _ = file.tree.Branches[0].Set(treePath, value)
+	return nil
+}
+
+func (file *sopsFile) removeValue(path []string) error {
+	treePath, err := treePath(file.tree.Branches[0], path, false)
+	if err != nil {
+		return err
+	}
+
+	branch, err := file.tree.Branches[0].Unset(treePath)
+	if err != nil {
+		return fmt.Errorf("%s does not exist", strings.Join(path, keySeparator))
+	}
+	file.tree.Branches[0] = branch
+
+	return nil
+}
+
+// Converts the key to the path sops uses, where the indexes of list items are ints. A missing key is only
+// allowed when setting, and becomes new sections, or a new item at the end of a list.
+func treePath(branch gosops.TreeBranch, path []string, setting bool) ([]interface{}, error) {
+	treePath := make([]interface{}, 0, len(path))
+	var node interface{} = branch
+	for i, key := range path {
+		switch current := node.(type) {
+		case gosops.TreeBranch:
+			treePath = append(treePath, key)
+			node = nil
+			for _, item := range current {
+				if item.Key == key {
+					node = item.Value
+					break
+				}
+			}
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index > len(current) || (index == len(current) && !setting) {
+				return nil, fmt.Errorf("invalid index %s in %s", key, strings.Join(path, keySeparator))
+			}
+			treePath = append(treePath, index)
+			node = nil
+			if index < len(current) {
+				node = current[index]
+			}
+		case nil:
+			if !setting {
+				return nil, fmt.Errorf("%s does not exist", strings.Join(path, keySeparator))
+			}
+			treePath = append(treePath, key)
+		default:
+			return nil, fmt.Errorf("%s is a value, not a section", strings.Join(path[:i], keySeparator))
+		}
+	}
+
+	switch node.(type) {
+	case gosops.TreeBranch, []interface{}:
+		return nil, fmt.Errorf("%s is a section, not a value", strings.Join(path, keySeparator))
+	}
+
+	return treePath, nil
+}
+
+func flatten(node interface{}, prefix string, secretData *[]secrets.SecretData) {
+	switch value := node.(type) {
+	case gosops.TreeBranch:
+		for _, item := range value {
+			if _, comment := item.Key.(gosops.Comment); !comment {
+				flatten(item.Value, joinKey(prefix, fmt.Sprint(item.Key)), secretData)
+			}
+		}
+	case []interface{}:
+		for i, item := range value {
+			flatten(item, joinKey(prefix, strconv.Itoa(i)), secretData)
+		}
+	case nil, gosops.Comment:
+	default:
+		*secretData = append(*secretData, secrets.SecretData{Key: prefix, Value: fmt.Sprint(value)})
+	}
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+
+	return prefix + keySeparator + key
+}
+
+func isSopsExtension(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+
+	return false
+}
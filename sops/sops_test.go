@@ -0,0 +1,248 @@
+package sops_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chadsmith12/dotsec/secrets"
+	"github.com/chadsmith12/dotsec/sops"
+	"github.com/getsops/sops/v3/decrypt"
+)
+
+const (
+	ageIdentity  = "AGE-SECRET-KEY-1GFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPQ4EGAEX"
+	ageRecipient = "age1zvkyg2lqzraa2lnjvqej32nkuu0ues2s82hzrye869xeexvn73equnujwj"
+)
+
+// Creates a directory with a .sops.yaml that encrypts every file for the test age key.
+func createTestRoot(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	config := "creation_rules:\n    - path_regex: secrets/.*\n      age: " + ageRecipient + "\n"
+	if err := os.WriteFile(filepath.Join(root, ".sops.yaml"), []byte(config), 0600); err != nil {
+		t.Fatalf("Failed to write .sops.yaml: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "secrets"), 0700); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+
+	return root
+}
+
+func newTestStore(t *testing.T, root string) *sops.SopsStore {
+	t.Helper()
+	store, err := sops.NewStore(root, "# created: 2024-01-01\n# public key: "+ageRecipient+"\n"+ageIdentity+"\n", "", "")
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+
+	return store
+}
+
+func pushSecrets(t *testing.T, store *sops.SopsStore, folderName string, secretData []secrets.SecretData) {
+	t.Helper()
+	folder, err := store.GetFolder(folderName)
+	if err != nil {
+		t.Fatalf("GetFolder failed: %v", err)
+	}
+	for _, secret := range secretData {
+		if err := store.CreateSecret(folder, secret); err != nil {
+			t.Fatalf("CreateSecret failed: %v", err)
+		}
+	}
+}
+
+func TestPush_CreatesEncryptedFile(t *testing.T) {
+	root := createTestRoot(t)
+	store := newTestStore(t, root)
+
+	pushSecrets(t, store, "secrets/dev.yaml", []secrets.SecretData{
+		{Key: "API_KEY", Value: "secret123"},
+		{Key: "Database:ConnectionString", Value: "Server=localhost"},
+	})
+
+	content, err := os.ReadFile(filepath.Join(root, "secrets", "dev.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if strings.Contains(string(content), "secret123") || !strings.Contains(string(content), "ENC[AES256_GCM,") {
+		t.Errorf("expected every value to be encrypted, got:\n%s", content)
+	}
+	if !strings.Contains(string(content), ageRecipient) {
+		t.Errorf("expected the data key to be encrypted for %s, got:\n%s", ageRecipient, content)
+	}
+
+	actual, err := secrets.GetSecretsByFolder(newTestStore(t, root), "secrets/dev.yaml")
+	if err != nil {
+		t.Fatalf("GetSecretsByFolder failed: %v", err)
+	}
+	expected := []secrets.SecretData{{Key: "API_KEY", Value: "secret123"}, {Key: "Database:ConnectionString", Value: "Server=localhost"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("GetSecretsByFolder() = %v, expected %v", actual, expected)
+	}
+}
+
+func TestGetSecrets_KeepsTypesAndUnencryptedValues(t *testing.T) {
+	root := createTestRoot(t)
+	store := newTestStore(t, root)
+	pushSecrets(t, store, "secrets/app.json", []secrets.SecretData{{Key: "API_KEY", Value: "secret123"}})
+
+	// add typed values the way a user editing the file with sops would
+	path := filepath.Join(root, "secrets", "app.json")
+	content, _ := os.ReadFile(path)
+	if !strings.Contains(string(content), "\"API_KEY\"") {
+		t.Fatalf("expected a json file, got:\n%s", content)
+	}
+	folder, _ := store.GetFolder("secrets/app.json")
+	if err := store.CreateSecret(folder, secrets.SecretData{Key: "Hosts:0", Value: "a.example.com"}); err != nil {
+		t.Fatalf("CreateSecret failed: %v", err)
+	}
+	if err := store.CreateSecret(folder, secrets.SecretData{Key: "region_unencrypted", Value: "us-east-1"}); err != nil {
+		t.Fatalf("CreateSecret failed: %v", err)
+	}
+
+	content, _ = os.ReadFile(path)
+	if !strings.Contains(string(content), "\"region_unencrypted\": \"us-east-1\"") {
+		t.Errorf("expected keys ending in _unencrypted to be left in plain text, got:\n%s", content)
+	}
+
+	actual, err := store.GetSecrets(folder)
+	if err != nil {
+		t.Fatalf("GetSecrets failed: %v", err)
+	}
+	expected := []secrets.SecretData{
+		{Key: "API_KEY", Value: "secret123"},
+		{Key: "Hosts:0", Value: "a.example.com"},
+		{Key: "region_unencrypted", Value: "us-east-1"},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("GetSecrets() = %v, expected %v", actual, expected)
+	}
+}
+
+func TestGetSecrets_DetectsTampering(t *testing.T) {
+	root := createTestRoot(t)
+	store := newTestStore(t, root)
+	pushSecrets(t, store, "secrets/dev.yaml", []secrets.SecretData{{Key: "API_KEY", Value: "secret123"}, {Key: "OTHER", Value: "value"}})
+
+	// swap the encrypted values of two keys, which sops catches through the mac and additional data
+	path := filepath.Join(root, "secrets", "dev.yaml")
+	content, _ := os.ReadFile(path)
+	lines := strings.Split(string(content), "\n")
+	lines[0] = "API_KEY: " + strings.TrimPrefix(lines[1], "OTHER: ")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	if _, err := secrets.GetSecretsByFolder(store, "secrets/dev.yaml"); err == nil {
+		t.Error("GetSecretsByFolder should fail when values have been moved between keys")
+	}
+}
+
+func TestUpdateAndDelete(t *testing.T) {
+	root := createTestRoot(t)
+	store := newTestStore(t, root)
+	pushSecrets(t, store, "secrets/dev.yaml", []secrets.SecretData{{Key: "API_KEY", Value: "old"}, {Key: "STALE", Value: "value"}})
+
+	folder, _ := store.GetFolder("secrets/dev.yaml")
+	refs, err := store.ListSecrets(folder)
+	if err != nil {
+		t.Fatalf("ListSecrets failed: %v", err)
+	}
+	apiKey, _ := secrets.FindSecretRef(refs, "API_KEY")
+	stale, _ := secrets.FindSecretRef(refs, "STALE")
+	if err := store.UpdateSecret(folder, apiKey, secrets.SecretData{Key: "API_KEY", Value: "new"}); err != nil {
+		t.Fatalf("UpdateSecret failed: %v", err)
+	}
+	if err := store.DeleteSecret(folder, stale); err != nil {
+		t.Fatalf("DeleteSecret failed: %v", err)
+	}
+
+	actual, _ := store.GetSecrets(folder)
+	expected := []secrets.SecretData{{Key: "API_KEY", Value: "new"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("GetSecrets() = %v, expected %v", actual, expected)
+	}
+
+	folders, err := store.ListFolders()
+	if err != nil {
+		t.Fatalf("ListFolders failed: %v", err)
+	}
+	if !reflect.DeepEqual(folders, []secrets.Folder{{ID: "secrets/dev.yaml", Name: "secrets/dev.yaml"}}) {
+		t.Errorf("ListFolders() = %v", folders)
+	}
+}
+
+func TestGetFolder_NoCreationRule(t *testing.T) {
+	root := createTestRoot(t)
+	store := newTestStore(t, root)
+
+	if _, err := store.GetFolder("other/dev.yaml"); err == nil {
+		t.Error("GetFolder should fail for a missing file that no creation rule matches")
+	}
+}
+
+// Copies a file encrypted by the sops binary for the test age key into a test root.
+func copyFixture(t *testing.T, root, name string) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "secrets", name), data, 0600); err != nil {
+		t.Fatalf("Failed to write fixture: %v", err)
+	}
+}
+
+func TestSopsFixtures_DecryptAndReencrypt(t *testing.T) {
+	t.Setenv("SOPS_AGE_KEY", ageIdentity)
+	for _, name := range []string{"dev.yaml", "app.json"} {
+		t.Run(name, func(t *testing.T) {
+			root := createTestRoot(t)
+			copyFixture(t, root, name)
+			store := newTestStore(t, root)
+
+			folder, err := store.GetFolder("secrets/" + name)
+			if err != nil {
+				t.Fatalf("GetFolder failed: %v", err)
+			}
+			actual, err := store.GetSecrets(folder)
+			if err != nil {
+				t.Fatalf("GetSecrets failed: %v", err)
+			}
+			expected := []secrets.SecretData{
+				{Key: "API_KEY", Value: "secret123"},
+				{Key: "Database:ConnectionString", Value: "Server=localhost"},
+				{Key: "Database:Port", Value: "5432"},
+				{Key: "Hosts:0", Value: "a.example.com"},
+				{Key: "Hosts:1", Value: "b.example.com"},
+				{Key: "Debug", Value: "true"},
+				{Key: "region_unencrypted", Value: "us-east-1"},
+			}
+			if !reflect.DeepEqual(actual, expected) {
+				t.Errorf("GetSecrets() = %v, expected %v", actual, expected)
+			}
+
+			if err := store.UpdateSecret(folder, secrets.SecretRef{ID: "API_KEY", Key: "API_KEY"}, secrets.SecretData{Key: "API_KEY", Value: "rotated"}); err != nil {
+				t.Fatalf("UpdateSecret failed: %v", err)
+			}
+			if err := store.CreateSecret(folder, secrets.SecretData{Key: "Database:Password", Value: "hunter2"}); err != nil {
+				t.Fatalf("CreateSecret failed: %v", err)
+			}
+
+			// the rewritten file has to be readable by sops itself, which finds the age key in SOPS_AGE_KEY
+			plain, err := decrypt.File(filepath.Join(root, "secrets", name), "")
+			if err != nil {
+				t.Fatalf("sops failed to decrypt the re-encrypted file: %v", err)
+			}
+			for _, value := range []string{"rotated", "hunter2", "Server=localhost", "b.example.com"} {
+				if !strings.Contains(string(plain), value) {
+					t.Errorf("expected %s in the file decrypted by sops, got:\n%s", value, plain)
+				}
+			}
+		})
+	}
+}
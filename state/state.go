@@ -0,0 +1,178 @@
+package state
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// SyncState is what dotsec remembers about the last sync between a folder and a local project or env file.
+// It is kept in the users config directory instead of next to the project, so it never ends up in source control.
+type SyncState struct {
+	Path   string `json:"path"`
+	Folder string `json:"folder"`
+	// the keys dotsec has written to the local file, which are the only keys pull --prune will remove
+	Keys []string `json:"keys"`
+	// a salted hash of the value of each key when it was last the same locally and in the folder.
+	// The values themselves are never saved.
+	Salt   string            `json:"salt,omitempty"`
+	Hashes map[string]string `json:"hashes,omitempty"`
+	file   string
+}
+
+// How a key changed on each side since the last sync.
+type Change int
+
+const (
+	// there is no baseline for the key, so which side changed is unknown
+	NoBaseline Change = iota
+	Unchanged
+	SourceChanged
+	DestinationChanged
+	BothChanged
+)
+
+// Gets the directory the sync state files are kept in.
+func Dir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find config directory: %w", err)
+	}
+
+	return filepath.Join(configDir, "dotsec", "state"), nil
+}
+
+// Loads the state of the local path and folder. A path and folder that haven't been synced yet have an empty state.
+func Load(localPath, folder string) (*SyncState, error) {
+	if localPath == "" {
+		localPath = "."
+	}
+	absPath, err := filepath.Abs(localPath)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256([]byte(absPath + "\n" + folder))
+	state := &SyncState{Path: absPath, Folder: folder, Keys: []string{}, file: filepath.Join(dir, hex.EncodeToString(hash[:16])+".json")}
+	data, err := os.ReadFile(state.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("reading sync state %s: %w", state.file, err)
+	}
+
+	return state, nil
+}
+
+// Checks if dotsec wrote the key to the local file.
+func (state *SyncState) Managed(key string) bool {
+	for _, managed := range state.Keys {
+		if managed == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Checks if the key was synced before, either managed in the local file or with a baseline from the last sync.
+// A key that is in neither was added on one side since the last sync.
+func (state *SyncState) Synced(key string) bool {
+	if _, found := state.Hashes[key]; found {
+		return true
+	}
+
+	return state.Managed(key)
+}
+
+// Replaces the keys dotsec manages in the local file, forgetting the baseline of the keys that aren't managed any more.
+func (state *SyncState) SetKeys(keys []string) {
+	state.Keys = append([]string{}, keys...)
+	sort.Strings(state.Keys)
+	for key := range state.Hashes {
+		if !state.Managed(key) {
+			delete(state.Hashes, key)
+		}
+	}
+}
+
+// Adds keys to the keys dotsec manages in the local file.
+func (state *SyncState) AddKeys(keys []string) {
+	for _, key := range keys {
+		if !state.Managed(key) {
+			state.Keys = append(state.Keys, key)
+		}
+	}
+	sort.Strings(state.Keys)
+}
+
+// Compares the value being synced from the source with the value at the destination, using the baseline
+// from the last sync to tell which of them changed since.
+func (state *SyncState) Compare(key, source, destination string) Change {
+	baseline, found := state.Hashes[key]
+	switch {
+	case source == destination:
+		return Unchanged
+	case !found:
+		return NoBaseline
+	}
+
+	sourceChanged := state.hash(source) != baseline
+	destinationChanged := state.hash(destination) != baseline
+	switch {
+	case sourceChanged && destinationChanged:
+		return BothChanged
+	case destinationChanged:
+		return DestinationChanged
+	default:
+		return SourceChanged
+	}
+}
+
+// Records the value of the key as the baseline for the next sync.
+func (state *SyncState) SetBaseline(key, value string) {
+	if state.Hashes == nil {
+		state.Hashes = map[string]string{}
+	}
+	state.Hashes[key] = state.hash(value)
+}
+
+// Hashes the value with the salt of this state, so the same secret doesn't have the same hash in every state file.
+func (state *SyncState) hash(value string) string {
+	if state.Salt == "" {
+		salt := make([]byte, 16)
+		rand.Read(salt)
+		state.Salt = hex.EncodeToString(salt)
+	}
+	mac := hmac.New(sha256.New, []byte(state.Salt))
+	mac.Write([]byte(value))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (state *SyncState) Save() error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(state.file), 0700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(state.file, data, 0600)
+}
@@ -0,0 +1,116 @@
+package state_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chadsmith12/dotsec/state"
+)
+
+func TestSyncState_SaveAndLoad(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	t.Setenv("APPDATA", configDir)
+	project := t.TempDir()
+
+	syncState, err := state.Load(filepath.Join(project, ".env"), "my-app")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(syncState.Keys) != 0 {
+		t.Fatalf("Keys = %v, expected a new state to be empty", syncState.Keys)
+	}
+
+	syncState.SetKeys([]string{"DB_PASSWORD", "API_KEY"})
+	syncState.AddKeys([]string{"API_KEY", "NEW_KEY"})
+	if err := syncState.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := state.Load(filepath.Join(project, ".env"), "my-app")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	expected := []string{"API_KEY", "DB_PASSWORD", "NEW_KEY"}
+	if !reflect.DeepEqual(loaded.Keys, expected) {
+		t.Errorf("Keys = %v, expected %v", loaded.Keys, expected)
+	}
+	if !loaded.Managed("API_KEY") || loaded.Managed("MANUAL_KEY") {
+		t.Error("Managed() should only be true for the saved keys")
+	}
+	loaded.SetBaseline("PUSHED_KEY", "value")
+	if !loaded.Synced("API_KEY") || !loaded.Synced("PUSHED_KEY") || loaded.Synced("MANUAL_KEY") {
+		t.Error("Synced() should only be true for the managed keys and the keys with a baseline")
+	}
+
+	other, _ := state.Load(filepath.Join(project, ".env"), "other-folder")
+	if len(other.Keys) != 0 {
+		t.Errorf("Keys = %v, expected another folder to have its own state", other.Keys)
+	}
+
+	dir, _ := state.Dir()
+	if !strings.HasPrefix(dir, configDir) {
+		t.Errorf("Dir() = %s, expected it to be in %s", dir, configDir)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Errorf("state files = %d, expected 1", len(files))
+	}
+}
+
+func TestSyncState_Compare(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	t.Setenv("APPDATA", configDir)
+	path := filepath.Join(t.TempDir(), ".env")
+
+	syncState, _ := state.Load(path, "my-app")
+	if change := syncState.Compare("API_KEY", "local", "remote"); change != state.NoBaseline {
+		t.Errorf("Compare() = %v, expected NoBaseline before the first sync", change)
+	}
+
+	syncState.SetKeys([]string{"API_KEY"})
+	syncState.SetBaseline("API_KEY", "synced")
+	if err := syncState.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(configDir, "dotsec", "state", firstFile(t, configDir)))
+	if strings.Contains(string(data), "synced") {
+		t.Errorf("state file = %s, expected it to not have the value", data)
+	}
+
+	loaded, _ := state.Load(path, "my-app")
+	tests := []struct {
+		source      string
+		destination string
+		expected    state.Change
+	}{
+		{"synced", "synced", state.Unchanged},
+		{"new", "new", state.Unchanged},
+		{"new", "synced", state.SourceChanged},
+		{"synced", "new", state.DestinationChanged},
+		{"local", "remote", state.BothChanged},
+	}
+	for _, test := range tests {
+		if change := loaded.Compare("API_KEY", test.source, test.destination); change != test.expected {
+			t.Errorf("Compare(%s, %s) = %v, expected %v", test.source, test.destination, change, test.expected)
+		}
+	}
+
+	loaded.SetKeys([]string{})
+	if change := loaded.Compare("API_KEY", "new", "synced"); change != state.NoBaseline {
+		t.Errorf("Compare() = %v, expected the baseline to be forgotten with the key", change)
+	}
+}
+
+func firstFile(t *testing.T, configDir string) string {
+	t.Helper()
+	files, err := os.ReadDir(filepath.Join(configDir, "dotsec", "state"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no state files in %s: %v", configDir, err)
+	}
+
+	return files[0].Name()
+}
@@ -0,0 +1,423 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/chadsmith12/dotsec/secrets"
+)
+
+var (
+	InvalidFolderErr = secrets.ErrFolderNotFound
+)
+
+// VaultApi talks to a HashiCorp Vault KV v2 secrets engine.
+// Each KV path is a folder, and each key in the secret at that path is a SecretData.
+type VaultApi struct {
+	address    string
+	mount      string
+	namespace  string
+	token      string
+	httpClient *http.Client
+	context    context.Context
+	// the changed secrets by their path, written as a single version of each path by Flush
+	pending map[string]*pendingSecret
+}
+
+// The data of a path with the changes made to it, and the version the changes were made to.
+type pendingSecret struct {
+	data    map[string]any
+	version int
+}
+
+type vaultResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Auth   *vaultAuth      `json:"auth"`
+	Errors []string        `json:"errors"`
+}
+
+type vaultAuth struct {
+	ClientToken string `json:"client_token"`
+}
+
+type kvData struct {
+	Data     map[string]any `json:"data"`
+	Metadata struct {
+		Version int `json:"version"`
+	} `json:"metadata"`
+}
+
+type kvMetadata struct {
+	CurrentVersion int `json:"current_version"`
+}
+
+type statusError struct {
+	status int
+	errors []string
+}
+
+func (err statusError) Error() string {
+	if len(err.errors) == 0 {
+		return fmt.Sprintf("vault returned status %d", err.status)
+	}
+
+	return fmt.Sprintf("vault returned status %d: %s", err.status, strings.Join(err.errors, ", "))
+}
+
+// Initializes a new Vault Api for the KV v2 engine mounted at mount on the server at address.
+// The client needs to be logged in with LoginWithToken or LoginWithAppRole before it is used.
+func NewClient(ctx context.Context, address, mount, namespace string) (*VaultApi, error) {
+	if address == "" {
+		return nil, errors.New("vault address is required")
+	}
+	if _, err := url.Parse(address); err != nil {
+		return nil, fmt.Errorf("invalid vault address: %w", err)
+	}
+	if mount == "" {
+		mount = "secret"
+	}
+
+	return &VaultApi{
+		address:    strings.TrimRight(address, "/"),
+		mount:      strings.Trim(mount, "/"),
+		namespace:  namespace,
+		httpClient: &http.Client{},
+		context:    ctx,
+		pending:    map[string]*pendingSecret{},
+	}, nil
+}
+
+// Uses an existing Vault token for every request, and checks that the token is valid.
+func (client *VaultApi) LoginWithToken(token string) error {
+	client.token = token
+	_, err := client.do(http.MethodGet, "auth/token/lookup-self", nil)
+	if err != nil {
+		return fmt.Errorf("looking up token: %w", err)
+	}
+
+	return nil
+}
+
+// Logs in with the AppRole auth method mounted at approleMount and uses the returned token.
+func (client *VaultApi) LoginWithAppRole(approleMount, roleId, secretId string) error {
+	if approleMount == "" {
+		approleMount = "approle"
+	}
+	response, err := client.do(http.MethodPost, fmt.Sprintf("auth/%s/login", strings.Trim(approleMount, "/")), map[string]string{
+		"role_id":   roleId,
+		"secret_id": secretId,
+	})
+	if err != nil {
+		return fmt.Errorf("logging in with approle: %w", err)
+	}
+
+	if response.Auth == nil || response.Auth.ClientToken == "" {
+		return errors.New("logging in with approle: vault did not return a token")
+	}
+	client.token = response.Auth.ClientToken
+
+	return nil
+}
+
+// Lists every secret path in the KV mount.
+func (client *VaultApi) ListFolders() ([]secrets.Folder, error) {
+	folders := make([]secrets.Folder, 0)
+	if err := client.listPaths("", &folders); err != nil {
+		return nil, err
+	}
+
+	return folders, nil
+}
+
+// Finds the secret at the KV path. The folder name is the path.
+func (client *VaultApi) GetFolder(name string) (secrets.Folder, error) {
+	path := strings.Trim(name, "/")
+	_, err := client.do(http.MethodGet, client.metadataPath(path), nil)
+	if err != nil {
+		var statusErr statusError
+		if errors.As(err, &statusErr) && statusErr.status == http.StatusNotFound {
+			return secrets.Folder{}, InvalidFolderErr
+		}
+		return secrets.Folder{}, err
+	}
+
+	return secrets.Folder{ID: path, Name: path}, nil
+}
+
+// KV v2 creates a path when the first key is written to it, so there is nothing to create before pushing to it.
+func (client *VaultApi) CreateFolder(name string) (secrets.Folder, error) {
+	path := strings.Trim(name, "/")
+
+	return secrets.Folder{ID: path, Name: path}, nil
+}
+
+// Lists the keys of the secret at the folders path.
+func (client *VaultApi) ListSecrets(folder secrets.Folder) ([]secrets.SecretRef, error) {
+	data, err := client.currentData(folder.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := sortedKeys(data)
+	refs := make([]secrets.SecretRef, 0, len(keys))
+	for _, key := range keys {
+		refs = append(refs, secrets.SecretRef{ID: key, Key: key})
+	}
+
+	return refs, nil
+}
+
+// Reads every key and value of the secret at the folders path.
+func (client *VaultApi) GetSecrets(folder secrets.Folder) ([]secrets.SecretData, error) {
+	data, err := client.currentData(folder.ID)
+	if err != nil {
+		return []secrets.SecretData{}, err
+	}
+
+	secretData := make([]secrets.SecretData, 0, len(data))
+	for _, key := range sortedKeys(data) {
+		secretData = append(secretData, secrets.SecretData{Key: key, Value: stringValue(data[key])})
+	}
+
+	return secretData, nil
+}
+
+// Sets the key in the folders secret. It is written along with the other changes to the path by Flush.
+func (client *VaultApi) CreateSecret(folder secrets.Folder, secret secrets.SecretData) error {
+	return client.modifySecret(folder.ID, func(data map[string]any) {
+		data[secret.Key] = secret.Value
+	})
+}
+
+// Sets the key in the folders secret. It is written along with the other changes to the path by Flush.
+func (client *VaultApi) UpdateSecret(folder secrets.Folder, ref secrets.SecretRef, secret secrets.SecretData) error {
+	return client.modifySecret(folder.ID, func(data map[string]any) {
+		data[ref.ID] = secret.Value
+	})
+}
+
+// Removes the key from the folders secret. It is written along with the other changes to the path by Flush.
+func (client *VaultApi) DeleteSecret(folder secrets.Folder, ref secrets.SecretRef) error {
+	return client.modifySecret(folder.ID, func(data map[string]any) {
+		delete(data, ref.ID)
+	})
+}
+
+// Writes a single new version of every path that changed since the last Flush, so a push only adds one version to
+// the history KV v2 keeps. Uses check-and-set with the version the changes were made to, so a write made by someone
+// else in the meantime is not overwritten.
+func (client *VaultApi) Flush() error {
+	paths := make([]string, 0, len(client.pending))
+	for path := range client.pending {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		pending := client.pending[path]
+		_, err := client.do(http.MethodPost, client.dataPath(path), map[string]any{
+			"options": map[string]any{"cas": pending.version},
+			"data":    pending.data,
+		})
+		if err != nil {
+			return fmt.Errorf("writing %s: %w", path, err)
+		}
+		delete(client.pending, path)
+	}
+
+	return nil
+}
+
+// Applies the change to the data of the path, reading it the first time the path is changed.
+func (client *VaultApi) modifySecret(path string, change func(map[string]any)) error {
+	pending, found := client.pending[path]
+	if !found {
+		current, err := client.readSecret(path)
+		if err != nil {
+			return err
+		}
+		pending = &pendingSecret{data: current.Data, version: current.Metadata.Version}
+		if pending.data == nil {
+			pending.data = map[string]any{}
+		}
+		client.pending[path] = pending
+	}
+	change(pending.data)
+
+	return nil
+}
+
+// The data of the path, including the changes that haven't been flushed yet.
+func (client *VaultApi) currentData(path string) (map[string]any, error) {
+	if pending, found := client.pending[path]; found {
+		return pending.data, nil
+	}
+
+	current, err := client.readSecret(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return current.Data, nil
+}
+
+// Reads the latest version of the secret at path. A path that hasn't been written yet is empty, at version 0,
+// which is the version check-and-set expects for the first write. A path whose latest version is deleted or destroyed
+// is empty too, at that version, so the next write replaces it.
+func (client *VaultApi) readSecret(path string) (kvData, error) {
+	response, err := client.do(http.MethodGet, client.dataPath(path), nil)
+	if err != nil {
+		var statusErr statusError
+		if errors.As(err, &statusErr) && statusErr.status == http.StatusNotFound {
+			data := kvData{}
+			data.Metadata.Version, err = client.currentVersion(path)
+			return data, err
+		}
+		return kvData{}, err
+	}
+
+	data := kvData{}
+	if err := json.Unmarshal(response.Data, &data); err != nil {
+		return kvData{}, fmt.Errorf("parsing secret: %w", err)
+	}
+
+	return data, nil
+}
+
+// The latest version of the path from its metadata, which is kept when that version is deleted, or 0 for a new path.
+func (client *VaultApi) currentVersion(path string) (int, error) {
+	response, err := client.do(http.MethodGet, client.metadataPath(path), nil)
+	if err != nil {
+		var statusErr statusError
+		if errors.As(err, &statusErr) && statusErr.status == http.StatusNotFound {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	metadata := kvMetadata{}
+	if err := json.Unmarshal(response.Data, &metadata); err != nil {
+		return 0, fmt.Errorf("parsing metadata: %w", err)
+	}
+
+	return metadata.CurrentVersion, nil
+}
+
+func (client *VaultApi) listPaths(prefix string, folders *[]secrets.Folder) error {
+	response, err := client.do("LIST", client.metadataPath(prefix), nil)
+	if err != nil {
+		var statusErr statusError
+		if errors.As(err, &statusErr) && statusErr.status == http.StatusNotFound {
+			return nil
+		}
+		return err
+	}
+
+	var list struct {
+		Keys []string `json:"keys"`
+	}
+	if err := json.Unmarshal(response.Data, &list); err != nil {
+		return fmt.Errorf("parsing list: %w", err)
+	}
+
+	for _, key := range list.Keys {
+		if strings.HasSuffix(key, "/") {
+			if err := client.listPaths(prefix+key, folders); err != nil {
+				return err
+			}
+			continue
+		}
+		*folders = append(*folders, secrets.Folder{ID: prefix + key, Name: prefix + key})
+	}
+
+	return nil
+}
+
+func (client *VaultApi) dataPath(path string) string {
+	return fmt.Sprintf("%s/data/%s", client.mount, path)
+}
+
+func (client *VaultApi) metadataPath(path string) string {
+	return fmt.Sprintf("%s/metadata/%s", client.mount, path)
+}
+
+func (client *VaultApi) do(method, path string, body any) (vaultResponse, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return vaultResponse{}, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	request, err := http.NewRequestWithContext(client.context, method, fmt.Sprintf("%s/v1/%s", client.address, path), reader)
+	if err != nil {
+		return vaultResponse{}, err
+	}
+	if client.token != "" {
+		request.Header.Set("X-Vault-Token", client.token)
+	}
+	if client.namespace != "" {
+		request.Header.Set("X-Vault-Namespace", client.namespace)
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := client.httpClient.Do(request)
+	if err != nil {
+		return vaultResponse{}, err
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return vaultResponse{}, err
+	}
+
+	result := vaultResponse{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &result); err != nil {
+			return vaultResponse{}, fmt.Errorf("parsing vault response: %w", err)
+		}
+	}
+
+	if response.StatusCode >= 300 {
+		return result, statusError{status: response.StatusCode, errors: result.Errors}
+	}
+
+	return result, nil
+}
+
+func sortedKeys(data map[string]any) []string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Values written by other tools may not be strings, so anything else is stored as its json.
+func stringValue(value any) string {
+	if str, ok := value.(string); ok {
+		return str
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(data)
+}
@@ -0,0 +1,298 @@
+package vault_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/chadsmith12/dotsec/secrets"
+	"github.com/chadsmith12/dotsec/vault"
+)
+
+const rootToken = "root"
+
+type kvSecret struct {
+	data    map[string]any
+	version int
+	// the latest version is soft deleted
+	deleted bool
+}
+
+// fakeVault is a stand in for `vault server -dev` with a KV v2 engine mounted at secret/ and AppRole enabled.
+type fakeVault struct {
+	mu      sync.Mutex
+	secrets map[string]*kvSecret
+}
+
+func newFakeVault(t *testing.T) (*fakeVault, *httptest.Server) {
+	t.Helper()
+	fake := &fakeVault{secrets: map[string]*kvSecret{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	return fake, server
+}
+
+func (fake *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if r.URL.Path == "/v1/auth/approle/login" {
+		var login map[string]string
+		json.NewDecoder(r.Body).Decode(&login)
+		if login["role_id"] != "role" || login["secret_id"] != "secret" {
+			writeJson(w, http.StatusBadRequest, map[string]any{"errors": []string{"invalid role or secret ID"}})
+			return
+		}
+		writeJson(w, http.StatusOK, map[string]any{"auth": map[string]string{"client_token": rootToken}})
+		return
+	}
+
+	if r.Header.Get("X-Vault-Token") != rootToken {
+		writeJson(w, http.StatusForbidden, map[string]any{"errors": []string{"permission denied"}})
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/v1/auth/token/lookup-self":
+		writeJson(w, http.StatusOK, map[string]any{"data": map[string]any{"id": rootToken}})
+	case strings.HasPrefix(r.URL.Path, "/v1/secret/data/"):
+		fake.serveData(w, r, strings.TrimPrefix(r.URL.Path, "/v1/secret/data/"))
+	case strings.HasPrefix(r.URL.Path, "/v1/secret/metadata/"):
+		fake.serveMetadata(w, r, strings.TrimPrefix(r.URL.Path, "/v1/secret/metadata/"))
+	default:
+		writeJson(w, http.StatusNotFound, map[string]any{"errors": []string{}})
+	}
+}
+
+func (fake *fakeVault) serveData(w http.ResponseWriter, r *http.Request, path string) {
+	secret, found := fake.secrets[path]
+	switch r.Method {
+	case http.MethodGet:
+		if !found || secret.deleted {
+			writeJson(w, http.StatusNotFound, map[string]any{"errors": []string{}})
+			return
+		}
+		writeJson(w, http.StatusOK, map[string]any{"data": map[string]any{
+			"data":     secret.data,
+			"metadata": map[string]any{"version": secret.version},
+		}})
+	case http.MethodPost:
+		var body struct {
+			Options map[string]int `json:"options"`
+			Data    map[string]any `json:"data"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		version := 0
+		if found {
+			version = secret.version
+		}
+		if cas, ok := body.Options["cas"]; ok && cas != version {
+			writeJson(w, http.StatusBadRequest, map[string]any{"errors": []string{"check-and-set parameter did not match the current version"}})
+			return
+		}
+		fake.secrets[path] = &kvSecret{data: body.Data, version: version + 1}
+		writeJson(w, http.StatusOK, map[string]any{"data": map[string]any{"version": version + 1}})
+	}
+}
+
+func (fake *fakeVault) serveMetadata(w http.ResponseWriter, r *http.Request, path string) {
+	if r.Method == "LIST" {
+		keys := map[string]bool{}
+		for secretPath := range fake.secrets {
+			if !strings.HasPrefix(secretPath, path) {
+				continue
+			}
+			rest := strings.TrimPrefix(secretPath, path)
+			if index := strings.Index(rest, "/"); index >= 0 {
+				rest = rest[:index+1]
+			}
+			keys[rest] = true
+		}
+		if len(keys) == 0 {
+			writeJson(w, http.StatusNotFound, map[string]any{"errors": []string{}})
+			return
+		}
+		list := make([]string, 0, len(keys))
+		for key := range keys {
+			list = append(list, key)
+		}
+		sort.Strings(list)
+		writeJson(w, http.StatusOK, map[string]any{"data": map[string]any{"keys": list}})
+		return
+	}
+
+	secret, found := fake.secrets[path]
+	if !found {
+		writeJson(w, http.StatusNotFound, map[string]any{"errors": []string{}})
+		return
+	}
+	writeJson(w, http.StatusOK, map[string]any{"data": map[string]any{"current_version": secret.version}})
+}
+
+func writeJson(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func newLoggedInClient(t *testing.T, address string) *vault.VaultApi {
+	t.Helper()
+	client, err := vault.NewClient(context.Background(), address, "secret", "")
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if err := client.LoginWithToken(rootToken); err != nil {
+		t.Fatalf("LoginWithToken failed: %v", err)
+	}
+
+	return client
+}
+
+func TestGetSecrets_ReadsEveryKeyAtPath(t *testing.T) {
+	fake, server := newFakeVault(t)
+	fake.secrets["myapp/dev"] = &kvSecret{data: map[string]any{"API_KEY": "secret123", "PORT": 8080}, version: 1}
+	client := newLoggedInClient(t, server.URL)
+
+	actual, err := secrets.GetSecretsByFolder(client, "myapp/dev")
+	if err != nil {
+		t.Fatalf("GetSecretsByFolder failed: %v", err)
+	}
+
+	expected := []secrets.SecretData{{Key: "API_KEY", Value: "secret123"}, {Key: "PORT", Value: "8080"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("GetSecretsByFolder() = %v, expected %v", actual, expected)
+	}
+}
+
+func TestGetFolder_MissingPath(t *testing.T) {
+	_, server := newFakeVault(t)
+	client := newLoggedInClient(t, server.URL)
+
+	_, err := client.GetFolder("missing")
+	if !errors.Is(err, vault.InvalidFolderErr) {
+		t.Errorf("GetFolder() error = %v, expected %v", err, vault.InvalidFolderErr)
+	}
+}
+
+func TestCreateFolder_PushesToNewPath(t *testing.T) {
+	fake, server := newFakeVault(t)
+	client := newLoggedInClient(t, server.URL)
+
+	folder, err := secrets.CreateFolder(client, "myapp/new/")
+	if err != nil {
+		t.Fatalf("CreateFolder failed: %v", err)
+	}
+	if refs, err := client.ListSecrets(folder); err != nil || len(refs) != 0 {
+		t.Fatalf("ListSecrets() = %v, %v, expected the new path to be empty", refs, err)
+	}
+	if err := client.CreateSecret(folder, secrets.SecretData{Key: "API_KEY", Value: "abc"}); err != nil {
+		t.Fatalf("CreateSecret failed: %v", err)
+	}
+	if err := client.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	if secret := fake.secrets["myapp/new"]; secret == nil || secret.data["API_KEY"] != "abc" || secret.version != 1 {
+		t.Errorf("secret = %v, expected API_KEY at version 1", secret)
+	}
+}
+
+func TestPush_CreatesUpdatesAndDeletesKeys(t *testing.T) {
+	fake, server := newFakeVault(t)
+	fake.secrets["myapp/dev"] = &kvSecret{data: map[string]any{"API_KEY": "old", "STALE": "value"}, version: 3}
+	client := newLoggedInClient(t, server.URL)
+
+	folder, err := client.GetFolder("myapp/dev")
+	if err != nil {
+		t.Fatalf("GetFolder failed: %v", err)
+	}
+	refs, err := client.ListSecrets(folder)
+	if err != nil {
+		t.Fatalf("ListSecrets failed: %v", err)
+	}
+
+	apiKey, _ := secrets.FindSecretRef(refs, "API_KEY")
+	stale, _ := secrets.FindSecretRef(refs, "STALE")
+	if err := client.UpdateSecret(folder, apiKey, secrets.SecretData{Key: "API_KEY", Value: "new"}); err != nil {
+		t.Fatalf("UpdateSecret failed: %v", err)
+	}
+	if err := client.CreateSecret(folder, secrets.SecretData{Key: "DB_PASSWORD", Value: "hunter2"}); err != nil {
+		t.Fatalf("CreateSecret failed: %v", err)
+	}
+	if err := client.DeleteSecret(folder, stale); err != nil {
+		t.Fatalf("DeleteSecret failed: %v", err)
+	}
+	if version := fake.secrets["myapp/dev"].version; version != 3 {
+		t.Errorf("version = %d before Flush, expected nothing to be written yet", version)
+	}
+	if refs, _ := client.ListSecrets(folder); len(refs) != 2 || refs[0].Key != "API_KEY" || refs[1].Key != "DB_PASSWORD" {
+		t.Errorf("ListSecrets() = %v, expected the changes that haven't been flushed", refs)
+	}
+	if err := client.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	expected := map[string]any{"API_KEY": "new", "DB_PASSWORD": "hunter2"}
+	if secret := fake.secrets["myapp/dev"]; !reflect.DeepEqual(secret.data, expected) || secret.version != 4 {
+		t.Errorf("secret = %v at version %d, expected %v as a single new version 4", secret.data, secret.version, expected)
+	}
+}
+
+func TestPush_ReplacesSoftDeletedVersion(t *testing.T) {
+	fake, server := newFakeVault(t)
+	fake.secrets["myapp/dev"] = &kvSecret{data: map[string]any{"OLD": "value"}, version: 2, deleted: true}
+	client := newLoggedInClient(t, server.URL)
+
+	folder, err := client.GetFolder("myapp/dev")
+	if err != nil {
+		t.Fatalf("GetFolder failed: %v", err)
+	}
+	if secretsData, err := client.GetSecrets(folder); err != nil || len(secretsData) != 0 {
+		t.Fatalf("GetSecrets() = %v, %v, expected a deleted version to be empty", secretsData, err)
+	}
+	if err := client.CreateSecret(folder, secrets.SecretData{Key: "API_KEY", Value: "abc"}); err != nil {
+		t.Fatalf("CreateSecret failed: %v", err)
+	}
+	if err := client.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	expected := map[string]any{"API_KEY": "abc"}
+	if secret := fake.secrets["myapp/dev"]; !reflect.DeepEqual(secret.data, expected) || secret.version != 3 {
+		t.Errorf("secret = %v at version %d, expected %v at version 3", secret.data, secret.version, expected)
+	}
+}
+
+func TestLoginWithAppRole(t *testing.T) {
+	fake, server := newFakeVault(t)
+	fake.secrets["team/api/dev"] = &kvSecret{data: map[string]any{}, version: 1}
+	fake.secrets["team/web/dev"] = &kvSecret{data: map[string]any{}, version: 1}
+	client, err := vault.NewClient(context.Background(), server.URL, "", "")
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	if err := client.LoginWithAppRole("", "role", "wrong"); err == nil {
+		t.Error("LoginWithAppRole should fail with the wrong secret id")
+	}
+	if err := client.LoginWithAppRole("", "role", "secret"); err != nil {
+		t.Fatalf("LoginWithAppRole failed: %v", err)
+	}
+
+	folders, err := client.ListFolders()
+	if err != nil {
+		t.Fatalf("ListFolders failed: %v", err)
+	}
+	expected := []secrets.Folder{{ID: "team/api/dev", Name: "team/api/dev"}, {ID: "team/web/dev", Name: "team/web/dev"}}
+	if !reflect.DeepEqual(folders, expected) {
+		t.Errorf("ListFolders() = %v, expected %v", folders, expected)
+	}
+}